@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"logstream/internal/agent"
+	"logstream/internal/cli"
+	"logstream/internal/config"
+	pb "logstream/pkg/api/logstream"
+)
+
+func main() {
+	configPath := flag.String("config", envOr("LOGSTREAM_AGENT_CONFIG", "config/agent.yml"), "agent config path")
+	flag.Parse()
+
+	cfg, err := config.LoadAgent(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	conn, err := cli.Dial(&cli.ConnConfig{
+		Addr:               cfg.Server.Addr,
+		TLS:                cfg.Server.TLS,
+		CACert:             cfg.Server.CACert,
+		Cert:               cfg.Server.Cert,
+		Key:                cfg.Server.Key,
+		ServerName:         cfg.Server.ServerName,
+		InsecureSkipVerify: cfg.Server.InsecureSkipVerify,
+		APIKey:             cfg.Server.APIKey,
+		Tenant:             cfg.Server.Tenant,
+	})
+	if err != nil {
+		log.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	a, err := agent.New(cfg, pb.NewLogsServiceClient(conn))
+	if err != nil {
+		log.Fatalf("failed to init agent: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Agent is shipping logs to %s", cfg.Server.Addr)
+
+	if err := a.Run(ctx); err != nil {
+		log.Fatalf("agent failed: %v", err)
+	}
+}
+
+// envOr returns value of environment variable key, def if it is not set.
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/cli"
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+func runSave(args []string) error {
+	fs := newFlagSet("save", "save [flags] <message>")
+	var (
+		co      connOptions
+		source  string
+		level   string
+		message string
+		ts      string
+	)
+	co.register(fs)
+	fs.StringVar(&source, "source", "", "log source (required)")
+	fs.StringVar(&level, "level", "info", "log level: info, warn, error")
+	fs.StringVar(&message, "message", "", "log message (defaults to positional arguments)")
+	fs.StringVar(&ts, "time", "now", "log time: now, -15m, RFC3339 or unix seconds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if message == "" {
+		message = strings.Join(fs.Args(), " ")
+	}
+	if source == "" {
+		return fmt.Errorf("-source is required")
+	}
+	if message == "" {
+		return fmt.Errorf("message is required")
+	}
+
+	lvl, err := loglevel.Parse(level)
+	if err != nil {
+		return err
+	}
+	t, err := cli.ParseTime(ts, time.Now())
+	if err != nil {
+		return fmt.Errorf("-time: %v", err)
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	resp, err := client.SaveLog(ctx, &pb.SaveLogRequest{
+		Log: &pb.Log{
+			Source:    source,
+			Level:     lvl,
+			Message:   message,
+			Timestamp: t.Unix(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save log: %v", err)
+	}
+
+	fmt.Println(resp.GetId())
+	return nil
+}
+
+func runGet(args []string) error {
+	fs := newFlagSet("get", "get [flags] <id> [id...]")
+	var (
+		co connOptions
+		oo outputOptions
+	)
+	co.register(fs)
+	oo.register(fs, cli.FormatPretty)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("at least one id is required")
+	}
+	ids := make([]int32, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid id %q", arg)
+		}
+		ids[i] = int32(id)
+	}
+
+	out, err := oo.formatter()
+	if err != nil {
+		return err
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	if len(ids) == 1 {
+		resp, err := client.ListLog(ctx, &pb.ListLogRequest{Id: ids[0]})
+		if err != nil {
+			return fmt.Errorf("failed to get log %d: %v", ids[0], err)
+		}
+		if err := out.Write(resp.GetLog()); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	stream, err := client.ListLogStream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %v", err)
+	}
+	for _, id := range ids {
+		if err := stream.Send(&pb.ListLogRequest{Id: id}); err != nil {
+			return fmt.Errorf("failed to send request: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("failed to get log %d: %v", id, err)
+		}
+		if err := out.Write(resp.GetLog()); err != nil {
+			return err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to close stream: %v", err)
+	}
+
+	return out.Flush()
+}
+
+func runList(args []string) error {
+	fs := newFlagSet("list", "list [flags]")
+	var (
+		co     connOptions
+		oo     outputOptions
+		fo     filterOptions
+		newest bool
+		limit  int
+	)
+	co.register(fs)
+	oo.register(fs, cli.FormatTable)
+	fo.register(fs, "-1h")
+	fs.BoolVar(&newest, "newest", false, "list newest logs first")
+	fs.IntVar(&limit, "limit", 0, "max logs to list, 0 for the server cap")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := fo.parse(time.Now())
+	if err != nil {
+		return err
+	}
+	if limit < 0 || limit > math.MaxInt32 {
+		return fmt.Errorf("-limit must be between 0 and %d", math.MaxInt32)
+	}
+	f.newest, f.limit = newest, int32(limit)
+	out, err := oo.formatter()
+	if err != nil {
+		return err
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	logs, err := fetchLogs(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		if err := out.Write(log); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+func runSearch(args []string) error {
+	fs := newFlagSet("search", "search [flags] <pattern>")
+	var (
+		co         connOptions
+		oo         outputOptions
+		fo         filterOptions
+		isRegex    bool
+		ignoreCase bool
+	)
+	co.register(fs)
+	oo.register(fs, cli.FormatPretty)
+	fo.register(fs, "-24h")
+	fs.BoolVar(&isRegex, "regex", false, "treat pattern as regular expression")
+	fs.BoolVar(&ignoreCase, "i", false, "ignore case")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("pattern is required")
+	}
+	pattern := strings.Join(fs.Args(), " ")
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	f, err := fo.parse(time.Now())
+	if err != nil {
+		return err
+	}
+	out, err := oo.formatter()
+	if err != nil {
+		return err
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	logs, err := fetchLogs(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		if !re.MatchString(log.GetMessage()) {
+			continue
+		}
+		if err := out.Write(log); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+func runTail(args []string) error {
+	fs := newFlagSet("tail", "tail [flags]")
+	var (
+		co       connOptions
+		oo       outputOptions
+		fo       filterOptions
+		interval time.Duration
+	)
+	co.register(fs)
+	oo.register(fs, cli.FormatPretty)
+	fo.register(fs, "-1m")
+	fs.DurationVar(&interval, "interval", 2*time.Second, "poll interval")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := fo.parse(time.Now())
+	if err != nil {
+		return err
+	}
+	out, err := oo.formatter()
+	if err != nil {
+		return err
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Timestamps have second precision, so the last second is queried
+	// again on every poll and already printed ids are skipped.
+	seen := make(map[int32]int64)
+	cursor := f.start.Unix()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		f.start = time.Unix(cursor, 0)
+		f.end = time.Now()
+
+		reqCtx, cancel := context.WithTimeout(ctx, co.timeout)
+		logs, err := fetchLogs(reqCtx, client, f)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "logstream-client tail: %v\n", err)
+		}
+
+		for _, log := range logs {
+			if log.Id != nil {
+				if _, ok := seen[*log.Id]; ok {
+					continue
+				}
+				seen[*log.Id] = log.GetTimestamp()
+			}
+			if log.GetTimestamp() > cursor {
+				cursor = log.GetTimestamp()
+			}
+			if err := out.Write(log); err != nil {
+				return err
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		for id, ts := range seen {
+			if ts < cursor {
+				delete(seen, id)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func runStats(args []string) error {
+	fs := newFlagSet("stats", "stats [flags]")
+	var (
+		co     connOptions
+		fo     filterOptions
+		format string
+		bucket time.Duration
+	)
+	co.register(fs)
+	fo.register(fs, "-24h")
+	fs.StringVar(&format, "o", cli.FormatTable, "output format: table, json")
+	fs.DurationVar(&bucket, "bucket", 0, "group counts into time buckets of this size, e.g. 1h")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := fo.parse(time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	logs, err := fetchLogs(ctx, client, f)
+	if err != nil {
+		return err
+	}
+
+	stats := cli.NewStats(f.source, f.start, f.end, bucket)
+	for _, log := range logs {
+		stats.Add(log)
+	}
+
+	switch format {
+	case cli.FormatTable:
+		return stats.WriteTable(os.Stdout)
+	case cli.FormatJSON:
+		return stats.WriteJSON(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format %q: should be table or json", format)
+	}
+}
+
+// fetchLogs lists logs for every requested level and merges them by time,
+// keeping the first f.limit logs in the requested order.
+func fetchLogs(ctx context.Context, client pb.LogsServiceClient, f *filter) ([]*pb.Log, error) {
+	var logs []*pb.Log
+
+	order := pb.SortOrder_SORT_ORDER_OLDEST_FIRST
+	if f.newest {
+		order = pb.SortOrder_SORT_ORDER_NEWEST_FIRST
+	}
+	for _, level := range f.levels {
+		stream, err := client.ListLogsStream(ctx, &pb.ListLogsStreamRequest{
+			Source:    f.source,
+			Level:     level,
+			StartTime: f.start.Unix(),
+			EndTime:   f.end.Unix(),
+			Query:     f.query,
+			Order:     order,
+			Limit:     f.limit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list logs: %v", err)
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if status.Code(err) == codes.NotFound {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list logs: %v", err)
+			}
+			logs = append(logs, resp.GetLog())
+		}
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		a, b := logs[i], logs[j]
+		if f.newest {
+			a, b = b, a
+		}
+		if a.GetTimestamp() != b.GetTimestamp() {
+			return a.GetTimestamp() < b.GetTimestamp()
+		}
+		return a.GetId() < b.GetId()
+	})
+	if f.limit > 0 && len(logs) > int(f.limit) {
+		logs = logs[:f.limit]
+	}
+
+	return logs, nil
+}
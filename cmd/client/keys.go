@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"logstream/internal/cli"
+	pb "logstream/pkg/api/logstream"
+)
+
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("subcommand is required: create, list, revoke")
+	}
+
+	switch args[0] {
+	case "create":
+		return runKeysCreate(args[1:])
+	case "list":
+		return runKeysList(args[1:])
+	case "revoke":
+		return runKeysRevoke(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q: should be create, list or revoke", args[0])
+	}
+}
+
+func runKeysCreate(args []string) error {
+	fs := newFlagSet("keys create", "keys create [flags] -name <name> -role <role>")
+	var (
+		co      connOptions
+		name    string
+		role    string
+		expires string
+	)
+	co.register(fs)
+	fs.StringVar(&name, "name", "", "key name (required)")
+	fs.StringVar(&role, "role", "", "key role: admin, reader, writer, readwrite or configured one (required)")
+	fs.StringVar(&expires, "expires", "", "expiration time: +720h, +30d, RFC3339 or unix seconds (default never)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if role == "" {
+		return fmt.Errorf("-role is required")
+	}
+
+	var expiresAt int64
+	if expires != "" {
+		t, err := cli.ParseTime(expires, time.Now())
+		if err != nil {
+			return fmt.Errorf("-expires: %v", err)
+		}
+		expiresAt = t.Unix()
+	}
+
+	client, conn, err := co.dialAdmin()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	resp, err := client.CreateAPIKey(ctx, &pb.CreateAPIKeyRequest{
+		Name:      name,
+		Role:      role,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Created key %d. Store it now, it cannot be shown again.\n", resp.GetApiKey().GetId())
+	fmt.Println(resp.GetKey())
+	return nil
+}
+
+func runKeysList(args []string) error {
+	fs := newFlagSet("keys list", "keys list [flags]")
+	var (
+		co     connOptions
+		format string
+	)
+	co.register(fs)
+	fs.StringVar(&format, "o", cli.FormatTable, "output format: table, json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if format != cli.FormatTable && format != cli.FormatJSON {
+		return fmt.Errorf("unsupported format %q: should be table or json", format)
+	}
+
+	client, conn, err := co.dialAdmin()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	resp, err := client.ListAPIKeys(ctx, &pb.ListAPIKeysRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list api keys: %v", err)
+	}
+
+	if format == cli.FormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetApiKeys())
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tROLE\tCREATED\tEXPIRES\tSTATUS")
+	now := time.Now().Unix()
+	for _, key := range resp.GetApiKeys() {
+		state := "active"
+		switch {
+		case key.GetRevokedAt() != 0:
+			state = "revoked"
+		case key.GetExpiresAt() != 0 && key.GetExpiresAt() <= now:
+			state = "expired"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.GetId(), key.GetName(), key.GetPrefix(), key.GetRole(),
+			formatUnix(key.GetCreatedAt()), formatUnix(key.GetExpiresAt()), state)
+	}
+	return tw.Flush()
+}
+
+func runKeysRevoke(args []string) error {
+	fs := newFlagSet("keys revoke", "keys revoke [flags] <id>")
+	var co connOptions
+	co.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("exactly one key id is required")
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 32)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("invalid key id %q", fs.Arg(0))
+	}
+
+	client, conn, err := co.dialAdmin()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	if _, err := client.RevokeAPIKey(ctx, &pb.RevokeAPIKeyRequest{Id: int32(id)}); err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	return nil
+}
+
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
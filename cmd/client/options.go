@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"logstream/internal/cli"
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+type connOptions struct {
+	conn    cli.ConnConfig
+	timeout time.Duration
+}
+
+func (o *connOptions) register(fs *flag.FlagSet) {
+	o.conn.RegisterFlags(fs)
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "request timeout")
+}
+
+func (o *connOptions) dial() (pb.LogsServiceClient, *grpc.ClientConn, error) {
+	conn, err := cli.Dial(&o.conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewLogsServiceClient(conn), conn, nil
+}
+
+func (o *connOptions) dialAdmin() (pb.AdminServiceClient, *grpc.ClientConn, error) {
+	conn, err := cli.Dial(&o.conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewAdminServiceClient(conn), conn, nil
+}
+
+type outputOptions struct {
+	format  string
+	noColor bool
+}
+
+func (o *outputOptions) register(fs *flag.FlagSet, def string) {
+	fs.StringVar(&o.format, "o", def, "output format: "+strings.Join(cli.Formats, ", "))
+	fs.BoolVar(&o.noColor, "no-color", false, "disable colored output")
+}
+
+func (o *outputOptions) formatter() (cli.Formatter, error) {
+	return cli.NewFormatter(o.format, os.Stdout, o.color())
+}
+
+func (o *outputOptions) color() bool {
+	if o.noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+type filterOptions struct {
+	source string
+	levels string
+	since  string
+	until  string
+	query  string
+}
+
+func (o *filterOptions) register(fs *flag.FlagSet, since string) {
+	fs.StringVar(&o.source, "source", "", "log source (required)")
+	fs.StringVar(&o.levels, "level", "", "comma-separated levels: info, warn, error (default all)")
+	fs.StringVar(&o.since, "since", since, "start of time range: now, -15m, -2d, RFC3339 or unix seconds")
+	fs.StringVar(&o.until, "until", "now", "end of time range")
+	fs.StringVar(&o.query, "query", "", "words the message must contain, matched by the server")
+}
+
+type filter struct {
+	source string
+	levels []pb.Level
+	start  time.Time
+	end    time.Time
+	query  string
+	// newest - newest logs first
+	newest bool
+	// limit - max logs, 0 means the server cap
+	limit int32
+}
+
+func (o *filterOptions) parse(now time.Time) (*filter, error) {
+	if o.source == "" {
+		return nil, fmt.Errorf("-source is required")
+	}
+
+	f := &filter{source: o.source, query: o.query}
+
+	levels, err := parseLevels(o.levels)
+	if err != nil {
+		return nil, err
+	}
+	f.levels = levels
+
+	if f.start, err = cli.ParseTime(o.since, now); err != nil {
+		return nil, fmt.Errorf("-since: %v", err)
+	}
+	if f.end, err = cli.ParseTime(o.until, now); err != nil {
+		return nil, fmt.Errorf("-until: %v", err)
+	}
+	if f.end.Before(f.start) {
+		return nil, fmt.Errorf("-until is before -since")
+	}
+
+	return f, nil
+}
+
+func parseLevels(s string) ([]pb.Level, error) {
+	if strings.TrimSpace(s) == "" {
+		return []pb.Level{pb.Level_LEVEL_INFO, pb.Level_LEVEL_WARN, pb.Level_LEVEL_ERROR}, nil
+	}
+
+	var levels []pb.Level
+	for _, name := range strings.Split(s, ",") {
+		level, err := loglevel.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
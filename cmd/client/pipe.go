@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"logstream/internal/ingest"
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+type levelRules []ingest.LevelRule
+
+func (r *levelRules) String() string {
+	return fmt.Sprintf("%d rules", len(*r))
+}
+
+func (r *levelRules) Set(s string) error {
+	rule, err := ingest.ParseLevelRule(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+func runPipe(args []string) error {
+	fs := newFlagSet("pipe", "pipe [flags] < input")
+	var (
+		co           connOptions
+		source       string
+		defLevel     string
+		rules        levelRules
+		noDetect     bool
+		format       string
+		multiline    string
+		multiTimeout time.Duration
+		maxLines     int
+		maxLineSize  int
+		passthrough  bool
+		drainTimeout time.Duration
+	)
+	co.register(fs)
+	fs.StringVar(&source, "source", "", "log source (required)")
+	fs.StringVar(&defLevel, "level", "info", "level of lines matching no level pattern")
+	fs.Var(&rules, "level-pattern", "level=regexp rule checked before default patterns, repeatable")
+	fs.BoolVar(&noDetect, "no-detect", false, "disable default level patterns")
+	fs.StringVar(&format, "parse", ingest.FormatText, "line format: text, json, logfmt, auto")
+	fs.StringVar(&multiline, "multiline", ingest.DefaultContinuationPattern, "regexp of continuation lines joined with the previous line; empty disables joining")
+	fs.DurationVar(&multiTimeout, "multiline-timeout", 500*time.Millisecond, "flush joined record after this idle time")
+	fs.IntVar(&maxLines, "max-lines", 500, "max lines joined into one record")
+	fs.IntVar(&maxLineSize, "max-line-size", 1<<20, "max line size in bytes")
+	fs.BoolVar(&passthrough, "passthrough", true, "copy input to stdout")
+	fs.DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "time to wait for pending logs after input ends")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if source == "" {
+		return fmt.Errorf("-source is required")
+	}
+	switch format {
+	case ingest.FormatText, ingest.FormatJSON, ingest.FormatLogfmt, ingest.FormatAuto:
+	default:
+		return fmt.Errorf("invalid -parse %q: should be text, json, logfmt or auto", format)
+	}
+
+	level, err := loglevel.Parse(defLevel)
+	if err != nil {
+		return err
+	}
+	if !noDetect {
+		rules = append(rules, ingest.DefaultLevelDetector().Rules()...)
+	}
+
+	var continuation *regexp.Regexp
+	if multiline != "" {
+		if continuation, err = regexp.Compile(multiline); err != nil {
+			return fmt.Errorf("invalid -multiline: %v", err)
+		}
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var sent, rejected atomic.Int64
+	shipper := ingest.NewShipper(client, ingest.ShipperOptions{
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "logstream-client pipe: %v, reconnecting\n", err)
+		},
+		OnReject: func(log *pb.Log, err error) {
+			rejected.Add(1)
+			fmt.Fprintf(os.Stderr, "logstream-client pipe: log rejected: %v\n", err)
+		},
+	})
+
+	shipCtx, cancelShip := context.WithCancel(context.Background())
+	defer cancelShip()
+	shipDone := make(chan error, 1)
+	go func() {
+		shipDone <- shipper.Run(shipCtx)
+	}()
+
+	parser := &ingest.LineParser{
+		Source: source,
+		Format: format,
+		Levels: ingest.NewLevelDetector(level, rules...),
+	}
+	joiner := ingest.NewJoiner(continuation, maxLines)
+
+	ship := func(record string) error {
+		log := parser.Parse(record, time.Now())
+		return shipper.Send(ctx, log, "", func(id int32) {
+			if id != 0 {
+				sent.Add(1)
+			}
+		})
+	}
+
+	lines := readLines(os.Stdin, maxLineSize)
+	timer := time.NewTimer(multiTimeout)
+	timer.Stop()
+
+	var readErr error
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break loop
+			}
+			if line.err != nil {
+				readErr = line.err
+				break loop
+			}
+			if passthrough {
+				fmt.Fprintln(os.Stdout, line.text)
+			}
+			if strings.TrimSpace(line.text) == "" {
+				continue
+			}
+
+			if record, ok := joiner.Add(line.text); ok {
+				if err := ship(record); err != nil {
+					break loop
+				}
+			}
+			if joiner.Pending() {
+				timer.Reset(multiTimeout)
+			}
+		case <-timer.C:
+			if record, ok := joiner.Flush(); ok {
+				if err := ship(record); err != nil {
+					break loop
+				}
+			}
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	if record, ok := joiner.Flush(); ok && ctx.Err() == nil {
+		_ = ship(record)
+	}
+	shipper.Close()
+
+	select {
+	case err := <-shipDone:
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+	case <-time.After(drainTimeout):
+		cancelShip()
+		<-shipDone
+		return fmt.Errorf("timed out waiting for %d pending logs", shipper.Pending())
+	case <-ctx.Done():
+		cancelShip()
+		<-shipDone
+	}
+
+	if readErr != nil {
+		return fmt.Errorf("failed to read input: %v", readErr)
+	}
+	if n := rejected.Load(); n > 0 {
+		return fmt.Errorf("%d logs rejected, %d saved", n, sent.Load())
+	}
+	return nil
+}
+
+type line struct {
+	text string
+	err  error
+}
+
+func readLines(f *os.File, maxSize int) <-chan line {
+	lines := make(chan line, 256)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxSize)
+		for scanner.Scan() {
+			lines <- line{text: strings.TrimRight(scanner.Text(), "\r")}
+		}
+		if err := scanner.Err(); err != nil {
+			lines <- line{err: err}
+		}
+	}()
+
+	return lines
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	pb "logstream/pkg/api/logstream"
+)
+
+func runReload(args []string) error {
+	fs := newFlagSet("reload", "reload [flags]")
+	var co connOptions
+	co.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, conn, err := co.dialAdmin()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	resp, err := client.ReloadConfig(ctx, &pb.ReloadConfigRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %v", err)
+	}
+
+	if sections := resp.GetRestartRequired(); len(sections) > 0 {
+		fmt.Fprintf(os.Stderr, "Config reloaded, changes of %s apply after restart.\n", strings.Join(sections, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"logstream/internal/cli"
+	pb "logstream/pkg/api/logstream"
+)
+
+func runSources(args []string) error {
+	fs := newFlagSet("sources", "sources [flags]")
+	var (
+		co     connOptions
+		prefix string
+		format string
+	)
+	co.register(fs)
+	fs.StringVar(&prefix, "prefix", "", "list only sources starting with prefix")
+	fs.StringVar(&format, "o", cli.FormatTable, "output format: table, json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if format != cli.FormatTable && format != cli.FormatJSON {
+		return fmt.Errorf("unsupported format %q: should be table or json", format)
+	}
+
+	client, conn, err := co.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), co.timeout)
+	defer cancel()
+
+	resp, err := client.ListSources(ctx, &pb.ListSourcesRequest{Prefix: prefix})
+	if err != nil {
+		return fmt.Errorf("failed to list sources: %v", err)
+	}
+
+	if format == cli.FormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetSources())
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tFIRST SEEN\tLAST SEEN\tINFO\tWARN\tERROR")
+	for _, s := range resp.GetSources() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n",
+			s.GetName(), formatUnix(s.GetFirstSeen()), formatUnix(s.GetLastSeen()),
+			s.GetInfoLogs(), s.GetWarnLogs(), s.GetErrorLogs())
+	}
+	return tw.Flush()
+}
@@ -1,52 +1,360 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"logstream/internal/auth"
 	"logstream/internal/config"
-	"logstream/internal/database"
+	"logstream/internal/health"
+	"logstream/internal/logging"
+	"logstream/internal/metrics"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/redact"
+	"logstream/internal/retention"
 	"logstream/internal/server"
+	"logstream/internal/spool"
+	"logstream/internal/storage"
+	"logstream/internal/tenant"
 	pb "logstream/pkg/api/logstream"
 )
 
-const (
-	configPath = "config/local.yaml"
-)
-
 func main() {
-	cfg, err := config.Load(configPath)
+	configPath := flag.String("config", envOr("LOGSTREAM_CONFIG", "config/local.yml"), "server config path")
+	migrateOnStart := flag.Bool("auto-migrate", false, "apply database migrations on start")
+	storageFlag := flag.String("storage", "", "storage backend: postgres, memory, sqlite:/path/to/file.db or segment:/path/to/dir; overrides storage section")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: server [flags]\n       server migrate up|down|status\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var overrides map[string]any
+	if *storageFlag != "" {
+		overrides = config.ParseStorage(*storageFlag)
+	}
+
+	cfg, err := config.Load(*configPath, overrides)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
-	db, err := database.NewDB(cfg.DBConfig)
+	l, err := logging.New(os.Stderr, cfg.LogConfig)
+	if err != nil {
+		log.Fatalf("failed to init logging: %v", err)
+	}
+	slog.SetDefault(l)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	st, err := storage.Open(ctx, cfg)
+	if err != nil {
+		fatal("failed to init storage", err)
+	}
+	// sqlite storage applies its migrations on open
+	pg, _ := st.(*storage.Postgres)
+
+	if flag.Arg(0) == "migrate" {
+		if pg == nil {
+			fatal("failed to migrate", errors.New("migrations are applied only to postgres storage"))
+		}
+		err := migrate(ctx, pg.DB(), flag.Args()[1:])
+		st.Close()
+		if err != nil {
+			fatal("failed to migrate", err)
+		}
+		return
+	}
+	if pg != nil && (*migrateOnStart || cfg.DBConfig.AutoMigrate) {
+		if err := autoMigrate(ctx, pg.DB()); err != nil {
+			fatal("failed to migrate", err)
+		}
+	}
+	slog.Info("storage is opened", slog.String("type", cfg.StorageConfig.Type))
+
+	var sp *spool.Spool
+	if cfg.SpoolConfig != nil && cfg.SpoolConfig.Path != "" {
+		sp, err = spool.Open(cfg.SpoolConfig.Path, spool.Options{
+			MaxSize:        cfg.SpoolConfig.MaxSize,
+			BatchSize:      cfg.SpoolConfig.BatchSize,
+			ReplayInterval: cfg.SpoolConfig.ReplayInterval,
+		})
+		if err != nil {
+			fatal("failed to open spool", err)
+		}
+		slog.Info("spool is opened", slog.String("path", cfg.SpoolConfig.Path), slog.Int("logs", sp.Len()))
+	}
+
+	p, err := pipeline.FromConfig(cfg.PipelineConfig)
 	if err != nil {
-		log.Fatalf("failed to init db: %v", err)
+		fatal("failed to init pipeline", err)
 	}
 
+	redactor, err := redact.FromConfig(cfg.RedactionConfig)
+	if err != nil {
+		fatal("failed to init redaction", err)
+	}
+
+	limiter, err := ratelimit.FromConfig(cfg.LimitsConfig)
+	if err != nil {
+		fatal("failed to init limits", err)
+	}
+
+	// retention is started without config too, it may be enabled by reload
+	retentionWorker, err := retention.New(st.Repo(), cfg.RetentionConfig)
+	if err != nil {
+		fatal("failed to init retention", err)
+	}
+	go retentionWorker.Run(ctx)
+
 	addr := fmt.Sprintf("%s:%d", cfg.ServerConfig.Host, cfg.ServerConfig.Port)
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		fatal("failed to listen", err)
+	}
+
+	slog.Info("server is listening", slog.String("addr", addr))
+
+	var (
+		opts   []grpc.ServerOption
+		unary  = []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(l)}
+		stream = []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(l)}
+	)
+	if cfg.ServerConfig.TLS != nil {
+		creds, err := server.NewTLSCredentials(cfg.ServerConfig.TLS)
+		if err != nil {
+			fatal("failed to init tls", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	var (
+		m             *metrics.Metrics
+		metricsServer *http.Server
+	)
+	if cfg.MetricsConfig != nil && cfg.MetricsConfig.Addr != "" {
+		m = metrics.New()
+		if db, ok := st.(interface{ DB() *sql.DB }); ok {
+			m.RegisterDB(db.DB(), "logstream")
+		}
+		unary = append(unary, m.UnaryServerInterceptor())
+		stream = append(stream, m.StreamServerInterceptor())
+		metricsServer = serveMetrics(cfg.MetricsConfig, m)
+	}
+
+	var authenticator *auth.Authenticator
+	if cfg.AuthConfig != nil && cfg.AuthConfig.Enabled {
+		authenticator, err = auth.New(st.KeysRepo(), cfg.AuthConfig)
+		if err != nil {
+			fatal("failed to init auth", err)
+		}
+		unary = append(unary, authenticator.UnaryServerInterceptor())
+		stream = append(stream, authenticator.StreamServerInterceptor())
+	} else {
+		slog.Warn("authentication is disabled, anyone can read and write logs")
+		unary = append(unary, tenant.UnaryServerInterceptor())
+		stream = append(stream, tenant.StreamServerInterceptor())
+	}
+	// recovery is the innermost, so panics are logged and counted as errors
+	unary = append(unary, logging.RecoveryUnaryServerInterceptor())
+	stream = append(stream, logging.RecoveryStreamServerInterceptor())
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	s := grpc.NewServer(opts...)
+	serverOpts := []server.Option{
+		server.WithPipeline(p),
+		server.WithRedactor(redactor),
+		server.WithLimiter(limiter),
+		server.WithMaxListLogs(cfg.ServerConfig.MaxListLogs),
+	}
+	if m != nil {
+		serverOpts = append(serverOpts, server.WithMetrics(m))
+	}
+	if sp != nil {
+		serverOpts = append(serverOpts, server.WithSpool(sp))
+	}
+	logsServer := server.NewServer(st.Repo(), serverOpts...)
+	pb.RegisterLogsServiceServer(s, logsServer)
+
+	if m != nil {
+		m.RegisterRedactions(logsServer.RedactionCounts)
+	}
+
+	ready := st.Check
+	spoolDone := make(chan struct{})
+	if sp != nil {
+		if m != nil {
+			m.RegisterSpool(sp.Stats)
+		}
+		go func() {
+			sp.Run(ctx, st.Repo(), st.Check)
+			close(spoolDone)
+		}()
+		// logs are accepted while the spool has room, reads may fail
+		ready = func(ctx context.Context) error {
+			if err := st.Check(ctx); err != nil {
+				if spoolErr := sp.Check(); spoolErr != nil {
+					return errors.Join(err, spoolErr)
+				}
+			}
+			return nil
+		}
+	} else {
+		close(spoolDone)
+	}
+	if cfg.RedactionConfig != nil && cfg.RedactionConfig.AuditInterval > 0 {
+		go auditRedactions(logsServer.RedactionCounts, cfg.RedactionConfig.AuditInterval)
+	}
+
+	r := &reloader{
+		path:      *configPath,
+		overrides: overrides,
+		server:    logsServer,
+		retention: retentionWorker,
+		cfg:       cfg,
 	}
+	go r.watch(ctx)
 
-	log.Printf("Server is listening on %v", addr)
+	// API keys are managed only when they are checked
+	if authenticator != nil {
+		pb.RegisterAdminServiceServer(s, server.NewAdminServer(st.KeysRepo(), authenticator.Roles(), r.Reload))
+	}
 
-	s := grpc.NewServer()
-	pb.RegisterLogsServiceServer(s, server.NewServer(db))
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	checker := health.New(ready, healthServer, cfg.HealthConfig, logsServer.Backlog, "", pb.LogsService_ServiceDesc.ServiceName)
+	go checker.Run(ctx)
 
 	reflection.Register(s)
 
-	if err := s.Serve(listener); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		fatal("failed to serve", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down", slog.Duration("timeout", cfg.ServerConfig.ShutdownTimeout))
+	// load balancers stop sending new calls before the server stops accepting them
+	healthServer.Shutdown()
+	time.Sleep(cfg.ServerConfig.DrainDelay)
+
+	logsServer.Drain()
+	gracefulStop(s, cfg.ServerConfig.ShutdownTimeout)
+
+	if metricsServer != nil {
+		if err := metricsServer.Close(); err != nil {
+			slog.Error("failed to stop metrics server", slog.Any("error", err))
+		}
+	}
+	<-spoolDone
+	if sp != nil {
+		if err := sp.Close(); err != nil {
+			slog.Error("failed to close spool", slog.Any("error", err))
+		}
+	}
+	if err := st.Close(); err != nil {
+		slog.Error("failed to close storage", slog.Any("error", err))
 	}
+	slog.Info("server stopped")
+}
+
+// gracefulStop waits for running calls until timeout and then cancels them.
+func gracefulStop(s *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		slog.Warn("shutdown timeout exceeded, cancelling running calls")
+		s.Stop()
+		<-stopped
+	}
+}
+
+// auditRedactions periodically logs number of redactions per rule.
+func auditRedactions(redactionCounts func() map[string]uint64, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		counts := redactionCounts()
+		if counts == nil {
+			continue
+		}
+		rules := make([]string, 0, len(counts))
+		for rule := range counts {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+
+		attrs := make([]any, 0, len(rules))
+		for _, rule := range rules {
+			attrs = append(attrs, slog.Uint64(rule, counts[rule]))
+		}
+		slog.Info("redactions", attrs...)
+	}
+}
+
+func serveMetrics(cfg *config.MetricsConfig, m *metrics.Metrics) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, m.Handler())
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("metrics are served", slog.String("addr", cfg.Addr), slog.String("path", cfg.Path))
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("failed to serve metrics", slog.Any("error", err))
+		}
+	}()
+	return srv
+}
+
+// envOr returns value of environment variable key, def if it is not set.
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
 
-	s.GracefulStop()
-	s.Stop()
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"logstream/internal/database"
+)
+
+const migrateUsage = "usage: server migrate up|down|status"
+
+// migrate runs migrate subcommand.
+func migrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			fmt.Printf("OK   %s (%s)\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("no migrations to apply")
+		}
+	case "down":
+		r, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("OK   %s (%s)\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+		for _, st := range statuses {
+			appliedAt := "Pending"
+			if !st.AppliedAt.IsZero() {
+				appliedAt = st.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", appliedAt, st.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+// autoMigrate applies pending migrations on start.
+func autoMigrate(ctx context.Context, db *sql.DB) error {
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	results, err := m.Up(ctx)
+	for _, r := range results {
+		slog.Info("migration applied", slog.String("migration", r.Source.Path), slog.Duration("duration", r.Duration))
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"logstream/internal/config"
+	"logstream/internal/logging"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/redact"
+	"logstream/internal/retention"
+	"logstream/internal/server"
+)
+
+// reloader applies config changes that are safe at runtime: pipeline,
+// redaction rules, limits, retention and log level. Invalid config is
+// rejected as a whole and the current one is kept.
+type reloader struct {
+	path      string
+	overrides map[string]any
+	server    *server.Server
+	retention *retention.Worker
+
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+// Reload loads config file and applies it. It returns changed sections
+// applied only after restart.
+func (r *reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.path, r.overrides)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pipeline.FromConfig(cfg.PipelineConfig)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := redact.FromConfig(cfg.RedactionConfig)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := ratelimit.FromConfig(cfg.LimitsConfig)
+	if err != nil {
+		return nil, err
+	}
+	w, err := retention.New(nil, cfg.RetentionConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := logging.SetLevel(cfg.LogConfig.Level); err != nil {
+		return nil, err
+	}
+
+	r.server.Reload(p, redactor, limiter)
+	r.retention.Update(w)
+
+	restartRequired := restartRequired(r.cfg, cfg)
+	r.cfg = cfg
+
+	slog.Info("config reloaded", slog.Any("restart_required", restartRequired))
+	return restartRequired, nil
+}
+
+// restartRequired returns sections changed between old and cfg that are
+// not reloaded.
+func restartRequired(old, cfg *config.Config) []string {
+	var sections []string
+	check := func(section string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			sections = append(sections, section)
+		}
+	}
+
+	check("server", old.ServerConfig, cfg.ServerConfig)
+	check("db", old.DBConfig, cfg.DBConfig)
+	check("storage", old.StorageConfig, cfg.StorageConfig)
+	check("spool", old.SpoolConfig, cfg.SpoolConfig)
+	check("auth", old.AuthConfig, cfg.AuthConfig)
+	check("metrics", old.MetricsConfig, cfg.MetricsConfig)
+	check("health", old.HealthConfig, cfg.HealthConfig)
+	check("log.format", old.LogConfig.Format, cfg.LogConfig.Format)
+	return sections
+}
+
+// watch reloads config on SIGHUP and changes of the config file until ctx
+// is done.
+func (r *reloader) watch(ctx context.Context) {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	if err := config.Watch(ctx, r.path, notify); err != nil {
+		slog.Error("failed to watch config file, reload with SIGHUP", slog.Any("error", err))
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-changes:
+		}
+
+		if _, err := r.Reload(); err != nil {
+			slog.Error("failed to reload config, keeping the current one", slog.Any("error", err))
+		}
+	}
+}
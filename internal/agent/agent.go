@@ -0,0 +1,454 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	logger "log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"logstream/internal/config"
+	"logstream/internal/ingest"
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+const (
+	startAtBeginning = "beginning"
+	startAtEnd       = "end"
+
+	defaultMaxLineSize = 1 << 20
+)
+
+// input - files matched by one files entry of the config
+type input struct {
+	patterns         []string
+	source           string
+	parser           ingest.LineParser
+	continuation     *regexp.Regexp
+	multilineTimeout time.Duration
+	maxLines         int
+	maxLineSize      int
+	startAt          string
+}
+
+func newInput(cfg *config.AgentFileConfig) (*input, error) {
+	if len(cfg.Paths) == 0 {
+		return nil, fmt.Errorf("paths are empty")
+	}
+	for _, pattern := range cfg.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+
+	in := &input{
+		patterns:         cfg.Paths,
+		source:           cfg.Source,
+		multilineTimeout: cfg.MultilineTimeout,
+		maxLines:         cfg.MaxLines,
+		maxLineSize:      defaultMaxLineSize,
+		startAt:          cfg.StartAt,
+	}
+	if in.multilineTimeout <= 0 {
+		in.multilineTimeout = 500 * time.Millisecond
+	}
+	if in.maxLines <= 0 {
+		in.maxLines = 500
+	}
+	switch in.startAt {
+	case "":
+		in.startAt = startAtEnd
+	case startAtBeginning, startAtEnd:
+	default:
+		return nil, fmt.Errorf("invalid start_at %q: should be beginning or end", cfg.StartAt)
+	}
+
+	format := cfg.Parse
+	if format == "" {
+		format = ingest.FormatText
+	}
+	switch format {
+	case ingest.FormatText, ingest.FormatJSON, ingest.FormatLogfmt, ingest.FormatAuto:
+	default:
+		return nil, fmt.Errorf("invalid parse %q: should be text, json, logfmt or auto", cfg.Parse)
+	}
+
+	level := pb.Level_LEVEL_INFO
+	if cfg.Level != "" {
+		var err error
+		if level, err = loglevel.Parse(cfg.Level); err != nil {
+			return nil, err
+		}
+	}
+	var rules []ingest.LevelRule
+	for _, s := range cfg.LevelPatterns {
+		rule, err := ingest.ParseLevelRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	rules = append(rules, ingest.DefaultLevelDetector().Rules()...)
+
+	in.parser = ingest.LineParser{
+		Format: format,
+		Levels: ingest.NewLevelDetector(level, rules...),
+	}
+
+	multiline := ingest.DefaultContinuationPattern
+	if cfg.Multiline != nil {
+		multiline = *cfg.Multiline
+	}
+	if multiline != "" {
+		re, err := regexp.Compile(multiline)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline: %v", err)
+		}
+		in.continuation = re
+	}
+
+	return in, nil
+}
+
+func (in *input) newJoiner() *ingest.Joiner {
+	return ingest.NewJoiner(in.continuation, in.maxLines)
+}
+
+func (in *input) sourceFor(path string) string {
+	if in.source != "" {
+		return in.source
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Agent tails files and ships their lines to logstream server.
+type Agent struct {
+	cfg         *config.AgentConfig
+	inputs      []*input
+	shipper     *ingest.Shipper
+	checkpoints *Checkpoints
+	tailers     map[FileID]*tailer
+}
+
+// New creates agent.
+func New(cfg *config.AgentConfig, client pb.LogsServiceClient) (*Agent, error) {
+	if len(cfg.Files) == 0 {
+		return nil, fmt.Errorf("no files configured")
+	}
+
+	a := &Agent{
+		cfg:     cfg,
+		tailers: make(map[FileID]*tailer),
+	}
+
+	for i, fileCfg := range cfg.Files {
+		in, err := newInput(fileCfg)
+		if err != nil {
+			return nil, fmt.Errorf("files[%d]: %v", i, err)
+		}
+		a.inputs = append(a.inputs, in)
+	}
+
+	checkpoints, err := LoadCheckpoints(cfg.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+	// agent id is part of idempotency keys, so it is kept before anything
+	// is shipped
+	if err := checkpoints.Save(); err != nil {
+		return nil, err
+	}
+	a.checkpoints = checkpoints
+
+	a.shipper = ingest.NewShipper(client, ingest.ShipperOptions{
+		OnError: func(err error) {
+			logger.Printf("agent: stream failed, reconnecting: %v", err)
+		},
+		OnReject: func(log *pb.Log, err error) {
+			logger.Printf("agent: log from %s rejected: %v", log.GetSource(), err)
+		},
+	})
+
+	return a, nil
+}
+
+// Run tails files until ctx is done, then waits for pending logs and
+// saves checkpoints.
+func (a *Agent) Run(ctx context.Context) error {
+	shipCtx, cancelShip := context.WithCancel(context.Background())
+	defer cancelShip()
+
+	shipDone := make(chan error, 1)
+	go func() {
+		shipDone <- a.shipper.Run(shipCtx)
+	}()
+
+	a.recoverRotated()
+	a.scan(ctx, true)
+	a.forgetMissing()
+
+	pollTicker := time.NewTicker(a.cfg.PollInterval)
+	defer pollTicker.Stop()
+	checkpointTicker := time.NewTicker(a.cfg.CheckpointInterval)
+	defer checkpointTicker.Stop()
+
+loop:
+	for {
+		if err := a.poll(ctx, time.Now()); err != nil {
+			break loop
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-pollTicker.C:
+			a.scan(ctx, false)
+		case <-checkpointTicker.C:
+			a.saveCheckpoints()
+		}
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), a.cfg.DrainTimeout)
+	defer cancelDrain()
+
+	// Ship joined lines which wait for multiline timeout.
+	for _, t := range a.tailers {
+		if err := a.ship(drainCtx, t, t.flush(nil)); err != nil {
+			break
+		}
+	}
+	a.shipper.Close()
+
+	select {
+	case err := <-shipDone:
+		if err != nil {
+			logger.Printf("agent: shipper stopped: %v", err)
+		}
+	case <-drainCtx.Done():
+		logger.Printf("agent: %d logs are not acknowledged, they will be read again on restart", a.shipper.Pending())
+		cancelShip()
+		<-shipDone
+	}
+
+	a.saveCheckpoints()
+	for _, t := range a.tailers {
+		t.close()
+	}
+
+	return nil
+}
+
+// scan discovers files matching configured patterns. Files found on
+// startup without checkpoint start according to start_at; files appearing
+// later (e.g. after rotation) are read from the beginning.
+func (a *Agent) scan(ctx context.Context, startup bool) {
+	for _, t := range a.tailers {
+		t.seen = false
+	}
+
+	for _, in := range a.inputs {
+		for _, pattern := range in.patterns {
+			paths, _ := filepath.Glob(pattern)
+			for _, path := range paths {
+				a.discover(in, path, startup)
+			}
+		}
+	}
+
+	for _, t := range a.tailers {
+		if t.seen || t.file == nil {
+			continue
+		}
+		// File was removed or renamed to a name matching no pattern.
+		// It is still open, so the rest is read before closing.
+		records, err := t.poll(time.Now())
+		if err != nil {
+			logger.Printf("agent: %v", err)
+		}
+		records = t.flush(records)
+		if err := a.ship(ctx, t, records); err != nil {
+			return
+		}
+		t.close()
+		logger.Printf("agent: stopped tailing %s", t.path)
+	}
+
+	for id, t := range a.tailers {
+		if t.file == nil && t.drained() {
+			delete(a.tailers, id)
+			a.checkpoints.Delete(id)
+		}
+	}
+}
+
+func (a *Agent) discover(in *input, path string, startup bool) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return
+	}
+	id := fileID(path, fi)
+
+	if t, ok := a.tailers[id]; ok {
+		if t.path != path {
+			logger.Printf("agent: %s was renamed to %s", t.path, path)
+			t.path = path
+		}
+		t.seen = true
+		return
+	}
+
+	var (
+		gen    int
+		offset int64
+	)
+	if cp, ok := a.checkpoints.Get(id); ok {
+		gen = cp.Gen
+		// checkpoint beyond the end means the file was truncated
+		if cp.Offset <= fi.Size() {
+			offset = cp.Offset
+		} else {
+			gen++
+		}
+	} else if startup && in.startAt == startAtEnd {
+		offset = fi.Size()
+	}
+
+	t, err := openTailer(path, id, in, gen, offset)
+	if err != nil {
+		logger.Printf("agent: %v", err)
+		return
+	}
+	t.seen = true
+	a.tailers[id] = t
+
+	logger.Printf("agent: tailing %s from offset %d", path, offset)
+}
+
+// recoverRotated finds files rotated while the agent was stopped: when
+// checkpointed path has a different inode now, the file with the
+// checkpointed inode is looked up in the same directory and drained.
+func (a *Agent) recoverRotated() {
+	a.checkpoints.mu.Lock()
+	cps := make([]Checkpoint, 0, len(a.checkpoints.files))
+	for _, cp := range a.checkpoints.files {
+		cps = append(cps, *cp)
+	}
+	a.checkpoints.mu.Unlock()
+
+	for _, cp := range cps {
+		if fi, err := os.Stat(cp.Path); err == nil && fileID(cp.Path, fi) == cp.FileID {
+			continue
+		}
+
+		in := a.inputFor(cp.Path)
+		if in == nil {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Dir(cp.Path))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(filepath.Dir(cp.Path), entry.Name())
+			fi, err := os.Stat(path)
+			if err != nil || !fi.Mode().IsRegular() || fileID(path, fi) != cp.FileID || cp.Offset > fi.Size() {
+				continue
+			}
+
+			t, err := openTailer(path, cp.FileID, in, cp.Gen, cp.Offset)
+			if err != nil {
+				logger.Printf("agent: %v", err)
+				break
+			}
+			// keep source of the original path
+			t.source = in.sourceFor(cp.Path)
+			a.tailers[cp.FileID] = t
+
+			logger.Printf("agent: %s was rotated to %s, reading rest from offset %d", cp.Path, path, cp.Offset)
+			break
+		}
+	}
+}
+
+func (a *Agent) inputFor(path string) *input {
+	for _, in := range a.inputs {
+		for _, pattern := range in.patterns {
+			if ok, _ := filepath.Match(pattern, path); ok {
+				return in
+			}
+		}
+	}
+	return nil
+}
+
+// forgetMissing drops checkpoints of files which no longer exist.
+func (a *Agent) forgetMissing() {
+	a.checkpoints.mu.Lock()
+	var missing []FileID
+	for id := range a.checkpoints.files {
+		if _, ok := a.tailers[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	a.checkpoints.mu.Unlock()
+
+	for _, id := range missing {
+		a.checkpoints.Delete(id)
+	}
+}
+
+func (a *Agent) poll(ctx context.Context, now time.Time) error {
+	for _, t := range a.tailers {
+		records, err := t.poll(now)
+		if err != nil {
+			logger.Printf("agent: %v", err)
+		}
+		if err := a.ship(ctx, t, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Agent) ship(ctx context.Context, t *tailer, records []record) error {
+	for _, rec := range records {
+		log := t.input.parser.Parse(rec.text, time.Now())
+		log.Source = t.source
+
+		gen, end := rec.gen, rec.end
+		if err := a.shipper.Send(ctx, log, a.key(t, rec), func(id int32) {
+			t.commit(gen, end)
+		}); err != nil {
+			return err
+		}
+		t.sent = end
+	}
+	return nil
+}
+
+// key returns idempotency key of record, the same when the record is read
+// again after a restart: agent id, file id, generation and end offset. Hash
+// of the text tells apart records of a file reusing the inode of a deleted
+// one.
+func (a *Agent) key(t *tailer, rec record) string {
+	h := fnv.New64a()
+	h.Write([]byte(rec.text))
+	return fmt.Sprintf("%s-%d-%d-%d-%d-%x", a.checkpoints.ID(), t.id.Dev, t.id.Ino, rec.gen, rec.end, h.Sum64())
+}
+
+func (a *Agent) saveCheckpoints() {
+	for id, t := range a.tailers {
+		gen, offset := t.checkpoint()
+		a.checkpoints.Set(id, t.path, gen, offset)
+	}
+	if err := a.checkpoints.Save(); err != nil {
+		logger.Printf("agent: %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"logstream/internal/config"
+	pb "logstream/pkg/api/logstream"
+)
+
+// fakeServer saves a log once per idempotency key, like the server.
+type fakeServer struct {
+	pb.UnimplementedLogsServiceServer
+
+	mu       sync.Mutex
+	messages []string
+	keys     map[string]int32
+}
+
+func (s *fakeServer) SaveLogStream(stream pb.LogsService_SaveLogStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if s.keys == nil {
+			s.keys = make(map[string]int32)
+		}
+		id, ok := s.keys[req.GetIdempotencyKey()]
+		if !ok {
+			s.messages = append(s.messages, req.GetLog().GetMessage())
+			id = int32(len(s.messages))
+			s.keys[req.GetIdempotencyKey()] = id
+		}
+		s.mu.Unlock()
+
+		if err := stream.Send(&pb.SaveLogResponse{Id: id}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *fakeServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func newClient(t *testing.T, srv pb.LogsServiceServer) pb.LogsServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterLogsServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewLogsServiceClient(conn)
+}
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	defer f.Close()
+	for _, line := range lines {
+		_, err := f.WriteString(line + "\n")
+		require.NoError(t, err)
+	}
+}
+
+func runAgent(t *testing.T, cfg *config.AgentConfig, client pb.LogsServiceClient, until func() bool) {
+	a, err := New(cfg, client)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	require.Eventually(t, until, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestAgentCheckpointsAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	empty := ""
+	cfg := &config.AgentConfig{
+		CheckpointPath:     filepath.Join(dir, "checkpoint.json"),
+		PollInterval:       10 * time.Millisecond,
+		CheckpointInterval: 10 * time.Millisecond,
+		DrainTimeout:       time.Second,
+		Files: []*config.AgentFileConfig{
+			{Paths: []string{filepath.Join(dir, "*.log")}, Multiline: &empty, StartAt: startAtBeginning},
+		},
+	}
+	srv := &fakeServer{}
+	client := newClient(t, srv)
+
+	appendLines(t, logPath, "one", "two")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 2 })
+
+	cps, err := LoadCheckpoints(cfg.CheckpointPath)
+	require.NoError(t, err)
+	fi, err := os.Stat(logPath)
+	require.NoError(t, err)
+	cp, ok := cps.Get(fileID(logPath, fi))
+	require.True(t, ok)
+	assert.Equal(t, fi.Size(), cp.Offset)
+
+	// Restart after more lines and a rotation: lines are neither lost
+	// nor shipped twice.
+	appendLines(t, logPath, "three")
+	require.NoError(t, os.Rename(logPath, logPath+".1"))
+	appendLines(t, logPath, "four")
+
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 4 })
+	assert.ElementsMatch(t, []string{"one", "two", "three", "four"}, srv.received())
+}
+
+func TestAgentKilledBeforeCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	empty := ""
+	cfg := &config.AgentConfig{
+		CheckpointPath:     filepath.Join(dir, "checkpoint.json"),
+		PollInterval:       10 * time.Millisecond,
+		CheckpointInterval: time.Hour,
+		DrainTimeout:       time.Second,
+		Files: []*config.AgentFileConfig{
+			{Paths: []string{filepath.Join(dir, "*.log")}, Multiline: &empty, StartAt: startAtBeginning},
+		},
+	}
+	srv := &fakeServer{}
+	client := newClient(t, srv)
+
+	appendLines(t, logPath, "one", "two")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 2 })
+	saved, err := os.ReadFile(cfg.CheckpointPath)
+	require.NoError(t, err)
+
+	// The agent is killed after "three" is acknowledged, but before its
+	// checkpoint is saved: the checkpoint on disk is the previous one.
+	appendLines(t, logPath, "three")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 3 })
+	require.NoError(t, os.WriteFile(cfg.CheckpointPath, saved, 0o644))
+
+	// "three" is read again and sent with the same key.
+	appendLines(t, logPath, "four")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 4 })
+	assert.Equal(t, []string{"one", "two", "three", "four"}, srv.received())
+	assert.Len(t, srv.keys, 4)
+}
+
+func TestAgentTruncatedWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	empty := ""
+	cfg := &config.AgentConfig{
+		CheckpointPath:     filepath.Join(dir, "checkpoint.json"),
+		PollInterval:       10 * time.Millisecond,
+		CheckpointInterval: 10 * time.Millisecond,
+		DrainTimeout:       time.Second,
+		Files: []*config.AgentFileConfig{
+			{Paths: []string{filepath.Join(dir, "*.log")}, Multiline: &empty, StartAt: startAtBeginning},
+		},
+	}
+	srv := &fakeServer{}
+	client := newClient(t, srv)
+
+	appendLines(t, logPath, "aaa", "bbb")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 2 })
+
+	// same offsets after truncation are a new generation, not resent lines
+	require.NoError(t, os.Truncate(logPath, 0))
+	appendLines(t, logPath, "aaa")
+	runAgent(t, cfg, client, func() bool { return len(srv.received()) == 3 })
+	assert.Equal(t, []string{"aaa", "bbb", "aaa"}, srv.received())
+}
+
+func TestTailerTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "first", "second")
+
+	in, err := newInput(&config.AgentFileConfig{Paths: []string{path}})
+	require.NoError(t, err)
+	tl, err := openTailer(path, FileID{}, in, 0, 0)
+	require.NoError(t, err)
+	defer tl.close()
+
+	now := time.Now()
+	records, err := tl.poll(now)
+	require.NoError(t, err)
+	records = append(records, tl.flush(nil)...)
+	require.Len(t, records, 2)
+	assert.Equal(t, "first", records[0].text)
+	assert.Equal(t, int64(len("first\n")), records[0].end)
+	assert.Equal(t, int64(len("first\nsecond\n")), records[1].end)
+
+	require.NoError(t, os.Truncate(path, 0))
+	appendLines(t, path, "new")
+
+	records, err = tl.poll(now)
+	require.NoError(t, err)
+	records = append(records, tl.flush(nil)...)
+	require.Len(t, records, 1)
+	assert.Equal(t, "new", records[0].text)
+	assert.Equal(t, 1, records[0].gen)
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileID identifies file independently of its path, so renamed files
+// keep their checkpoints.
+type FileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+// Checkpoint - committed read offset of a file
+type Checkpoint struct {
+	FileID
+	Path string `json:"path"`
+	// Gen - truncations of the file seen by the agent
+	Gen    int   `json:"gen"`
+	Offset int64 `json:"offset"`
+}
+
+// Checkpoints persists committed offsets in a local JSON file.
+type Checkpoints struct {
+	path string
+	// id - random id of the agent, kept with checkpoints
+	id string
+
+	mu    sync.Mutex
+	files map[FileID]*Checkpoint
+}
+
+type checkpointFile struct {
+	ID    string        `json:"id"`
+	Files []*Checkpoint `json:"files"`
+}
+
+// LoadCheckpoints loads checkpoints from path. Missing file is not an
+// error. Agent id is generated if the file has none.
+func LoadCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:  path,
+		files: make(map[FileID]*Checkpoint),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read checkpoints: %v", err)
+	}
+	if err == nil {
+		var f checkpointFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoints %s: %v", path, err)
+		}
+		c.id = f.ID
+		for _, cp := range f.Files {
+			c.files[cp.FileID] = cp
+		}
+	}
+
+	if c.id == "" {
+		id := make([]byte, 8)
+		rand.Read(id)
+		c.id = hex.EncodeToString(id)
+	}
+	return c, nil
+}
+
+// ID returns id of the agent.
+func (c *Checkpoints) ID() string {
+	return c.id
+}
+
+// Get returns checkpoint of file.
+func (c *Checkpoints) Get(id FileID) (Checkpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, ok := c.files[id]
+	if !ok {
+		return Checkpoint{}, false
+	}
+	return *cp, true
+}
+
+// Set updates checkpoint of file.
+func (c *Checkpoints) Set(id FileID, path string, gen int, offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files[id] = &Checkpoint{FileID: id, Path: path, Gen: gen, Offset: offset}
+}
+
+// Delete removes checkpoint of file.
+func (c *Checkpoints) Delete(id FileID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.files, id)
+}
+
+// Save atomically writes checkpoints to disk.
+func (c *Checkpoints) Save() error {
+	c.mu.Lock()
+	f := checkpointFile{ID: c.id, Files: make([]*Checkpoint, 0, len(c.files))}
+	for _, cp := range c.files {
+		f.Files = append(f.Files, cp)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(f.Files, func(i, j int) bool {
+		return f.Files[i].Path < f.Files[j].Path
+	})
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoints: %v", err)
+	}
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint dir: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoints: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoints: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoints: %v", err)
+	}
+	return nil
+}
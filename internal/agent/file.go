@@ -0,0 +1,9 @@
+package agent
+
+import "hash/fnv"
+
+func pathID(path string) FileID {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return FileID{Ino: h.Sum64()}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package agent
+
+import "os"
+
+// Inodes are not available, so files are identified by path and renames
+// are treated as new files.
+func fileID(path string, _ os.FileInfo) FileID {
+	return pathID(path)
+}
@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"logstream/internal/ingest"
+)
+
+const readChunkSize = 64 * 1024
+
+// record - joined lines ready to be shipped
+type record struct {
+	text string
+	// end - file offset right after the last line of record
+	end int64
+	// gen - file generation, incremented on truncation
+	gen int
+}
+
+// tailer reads complete lines of a single file starting at offset.
+type tailer struct {
+	id     FileID
+	path   string
+	source string
+	input  *input
+
+	file    *os.File
+	readPos int64
+	partial []byte
+
+	joiner   *ingest.Joiner
+	lastEnd  int64
+	lastLine time.Time
+
+	// sent - end offset of the last shipped record
+	sent int64
+	// seen - file matched a pattern during the last scan
+	seen bool
+
+	mu        sync.Mutex
+	gen       int
+	committed int64
+}
+
+func openTailer(path string, id FileID, in *input, gen int, offset int64) (*tailer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	return &tailer{
+		id:        id,
+		path:      path,
+		source:    in.sourceFor(path),
+		input:     in,
+		file:      f,
+		readPos:   offset,
+		joiner:    in.newJoiner(),
+		lastEnd:   offset,
+		sent:      offset,
+		gen:       gen,
+		committed: offset,
+	}, nil
+}
+
+// offset returns position right after the last complete line read.
+func (t *tailer) offset() int64 {
+	return t.readPos - int64(len(t.partial))
+}
+
+// poll reads lines appended since the previous poll and returns complete
+// records. Truncated files are read again from the beginning.
+func (t *tailer) poll(now time.Time) ([]record, error) {
+	if t.file == nil {
+		return nil, nil
+	}
+
+	fi, err := t.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", t.path, err)
+	}
+
+	var records []record
+	if fi.Size() < t.offset() {
+		// copytruncate: ship what is joined so far and restart
+		records = t.flush(records)
+		t.readPos = 0
+		t.partial = nil
+		t.lastEnd = 0
+		t.sent = 0
+		t.truncate()
+	}
+
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := t.file.ReadAt(buf, t.readPos)
+		if n > 0 {
+			records = t.consume(buf[:n], records, now)
+			t.readPos += int64(n)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return records, fmt.Errorf("failed to read %s: %v", t.path, err)
+		}
+	}
+
+	if len(t.partial) >= t.input.maxLineSize {
+		records = t.addLine(string(t.partial), t.readPos, records, now)
+		t.partial = nil
+	}
+
+	if t.joiner.Pending() && now.Sub(t.lastLine) >= t.input.multilineTimeout {
+		records = t.flush(records)
+	}
+
+	return records, nil
+}
+
+func (t *tailer) consume(chunk []byte, records []record, now time.Time) []record {
+	pos := t.readPos
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			t.partial = append(t.partial, chunk...)
+			return records
+		}
+
+		line := chunk[:i]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		pos += int64(i + 1)
+		chunk = chunk[i+1:]
+
+		records = t.addLine(string(bytes.TrimRight(line, "\r")), pos, records, now)
+	}
+	return records
+}
+
+func (t *tailer) addLine(line string, end int64, records []record, now time.Time) []record {
+	if len(bytes.TrimSpace([]byte(line))) == 0 {
+		if !t.joiner.Pending() {
+			// nothing waits for this offset, so it can be committed
+			t.lastEnd = end
+		}
+		return records
+	}
+
+	if text, ok := t.joiner.Add(line); ok {
+		records = append(records, record{text: text, end: t.lastEnd, gen: t.gen})
+	}
+	t.lastEnd = end
+	t.lastLine = now
+
+	if !t.joiner.Pending() {
+		// joining disabled: line is already returned as a record
+		records[len(records)-1].end = end
+	}
+	return records
+}
+
+// flush returns joined record regardless of multiline timeout.
+func (t *tailer) flush(records []record) []record {
+	if text, ok := t.joiner.Flush(); ok {
+		records = append(records, record{text: text, end: t.lastEnd, gen: t.gen})
+	}
+	return records
+}
+
+// commit marks offset as saved by the server.
+func (t *tailer) commit(gen int, end int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if gen == t.gen && end > t.committed {
+		t.committed = end
+	}
+}
+
+// truncate starts new generation, so acks of records read before
+// truncation no longer move committed offset.
+func (t *tailer) truncate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.gen++
+	t.committed = 0
+}
+
+// checkpoint returns generation and committed offset.
+func (t *tailer) checkpoint() (int, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.gen, t.committed
+}
+
+// drained reports whether every shipped record is committed.
+func (t *tailer) drained() bool {
+	_, committed := t.checkpoint()
+	return !t.joiner.Pending() && committed >= t.sent
+}
+
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/config"
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+	pb "logstream/pkg/api/logstream"
+)
+
+const defaultCacheTTL = 30 * time.Second
+
+// Principal - authenticated caller
+type Principal struct {
+	// Name - API key name or client certificate common name
+	Name string
+	// KeyID - API key id, zero for certificates and bootstrap keys
+	KeyID int32
+	Role  *Role
+	// Tenant - tenant of the caller, empty for bootstrap keys which may
+	// act in any tenant selected by metadata
+	Tenant string
+}
+
+type contextKey string
+
+const principalKey = contextKey("principal")
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns principal of authenticated request.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok
+}
+
+type cacheEntry struct {
+	key     *repo.APIKey
+	expires time.Time
+}
+
+// Authenticator authenticates requests by API key from "authorization:
+// Bearer <key>" or "x-api-key" metadata, or by verified client certificate.
+type Authenticator struct {
+	keys           repo.KeysRepo
+	roles          Roles
+	adminKeyHashes map[string]bool
+	subjects       map[string]*config.SubjectConfig
+	cacheTTL       time.Duration
+
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func New(keys repo.KeysRepo, cfg *config.AuthConfig) (*Authenticator, error) {
+	roles, err := NewRoles(cfg.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{
+		keys:           keys,
+		roles:          roles,
+		adminKeyHashes: make(map[string]bool, len(cfg.AdminKeyHashes)),
+		subjects:       make(map[string]*config.SubjectConfig, len(cfg.Subjects)),
+		cacheTTL:       cfg.CacheTTL,
+		now:            time.Now,
+		cache:          make(map[string]cacheEntry),
+	}
+	if a.cacheTTL <= 0 {
+		a.cacheTTL = defaultCacheTTL
+	}
+	for _, hash := range cfg.AdminKeyHashes {
+		a.adminKeyHashes[strings.ToLower(hash)] = true
+	}
+	for i, subject := range cfg.Subjects {
+		if _, ok := roles[subject.Role]; !ok {
+			return nil, fmt.Errorf("auth.subjects[%d]: unknown role %q", i, subject.Role)
+		}
+		if subject.Tenant != "" {
+			if err := tenant.Validate(subject.Tenant); err != nil {
+				return nil, fmt.Errorf("auth.subjects[%d]: %v", i, err)
+			}
+		}
+		a.subjects[subject.Name] = subject
+	}
+	return a, nil
+}
+
+// Roles returns known roles.
+func (a *Authenticator) Roles() Roles {
+	return a.roles
+}
+
+// Authenticate returns principal of request in ctx.
+func (a *Authenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	if key := keyFromMetadata(ctx); key != "" {
+		return a.authenticateKey(ctx, key)
+	}
+
+	if subject := verifiedSubject(ctx); subject != "" {
+		cfg, ok := a.subjects[subject]
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "no role for certificate subject %q", subject)
+		}
+		tenantID := cfg.Tenant
+		if tenantID == "" {
+			tenantID = tenant.Default
+		}
+		return &Principal{
+			Name:   subject,
+			Role:   a.roles[cfg.Role],
+			Tenant: tenantID,
+		}, nil
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "api key or client certificate is required")
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, key string) (*Principal, error) {
+	hash := HashKey(key)
+	if a.adminKeyHashes[hash] {
+		return &Principal{
+			Name: "bootstrap",
+			Role: a.roles[RoleAdmin],
+		}, nil
+	}
+
+	apiKey, err := a.lookup(ctx, hash)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	now := a.now().Unix()
+	switch {
+	case apiKey == nil:
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	case apiKey.RevokedAt != 0:
+		return nil, status.Error(codes.Unauthenticated, "api key is revoked")
+	case apiKey.ExpiresAt != 0 && apiKey.ExpiresAt <= now:
+		return nil, status.Error(codes.Unauthenticated, "api key is expired")
+	}
+
+	role, ok := a.roles[apiKey.Role]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "api key has unknown role %q", apiKey.Role)
+	}
+
+	return &Principal{
+		Name:   apiKey.Name,
+		KeyID:  apiKey.Id,
+		Role:   role,
+		Tenant: apiKey.Tenant,
+	}, nil
+}
+
+// lookup returns key by hash, nil if there is no such key. Both found and
+// missing keys are cached.
+func (a *Authenticator) lookup(ctx context.Context, hash string) (*repo.APIKey, error) {
+	now := a.now()
+
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.key, nil
+	}
+
+	key, err := a.keys.GetAPIKeyByHash(ctx, hash)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	for h, e := range a.cache {
+		if !now.Before(e.expires) {
+			delete(a.cache, h)
+		}
+	}
+	a.cache[hash] = cacheEntry{
+		key:     key,
+		expires: now.Add(a.cacheTTL),
+	}
+	a.mu.Unlock()
+
+	return key, nil
+}
+
+func keyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, token, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func verifiedSubject(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// methodPermission returns permission required to call method, empty if
+// the method is public. Source scopes are checked by the handlers.
+func methodPermission(method string) string {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	switch service {
+	case healthpb.Health_ServiceDesc.ServiceName:
+		// load balancers check health without credentials
+		return ""
+	case pb.AdminService_ServiceDesc.ServiceName:
+		return PermissionAdmin
+	case pb.LogsService_ServiceDesc.ServiceName:
+		if strings.HasPrefix(name, "Save") {
+			return PermissionWrite
+		}
+		return PermissionRead
+	default:
+		return PermissionRead
+	}
+}
+
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	permission := methodPermission(method)
+	if permission == "" {
+		return ctx, nil
+	}
+
+	p, err := a.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Role.Has(permission) {
+		return nil, status.Errorf(codes.PermissionDenied, "role %s has no %s permission", p.Role.Name, permission)
+	}
+
+	tenantID, err := resolveTenant(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return tenant.WithTenant(WithPrincipal(ctx, p), tenantID), nil
+}
+
+// resolveTenant returns tenant of the caller. Tenant requested in metadata
+// must match the credentials unless they are not bound to a tenant.
+func resolveTenant(ctx context.Context, p *Principal) (string, error) {
+	requested, err := tenant.FromMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case p.Tenant == "" && requested == "":
+		return tenant.Default, nil
+	case p.Tenant == "":
+		return requested, nil
+	case requested != "" && requested != p.Tenant:
+		return "", status.Errorf(codes.PermissionDenied, "no access to tenant %q", requested)
+	default:
+		return p.Tenant, nil
+	}
+}
+
+// UnaryServerInterceptor authenticates unary calls.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
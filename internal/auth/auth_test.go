@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/config"
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type keysRepo struct {
+	repo.KeysRepo
+
+	keys    map[string]*repo.APIKey
+	lookups int
+}
+
+func (r *keysRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*repo.APIKey, error) {
+	r.lookups++
+	if key, ok := r.keys[hash]; ok {
+		return key, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func TestAuthenticator(t *testing.T) {
+	now := time.Unix(1749108957, 0)
+
+	keys := &keysRepo{
+		keys: map[string]*repo.APIKey{
+			HashKey("agent-key"):     {Id: 1, Name: "agent", Role: RoleWriter},
+			HashKey("admin-key"):     {Id: 2, Name: "ops", Role: RoleAdmin},
+			HashKey("revoked-key"):   {Id: 3, Name: "old", Role: RoleWriter, RevokedAt: now.Unix() - 1},
+			HashKey("expired-key"):   {Id: 4, Name: "tmp", Role: RoleWriter, ExpiresAt: now.Unix()},
+			HashKey("dashboard-key"): {Id: 5, Name: "grafana", Role: "dashboard"},
+			HashKey("unknown-role"):  {Id: 6, Name: "stale", Role: "removed"},
+		},
+	}
+	a, err := New(keys, &config.AuthConfig{
+		AdminKeyHashes: []string{HashKey("bootstrap-key")},
+		Roles: []*config.RoleConfig{
+			{Name: "dashboard", Permissions: []string{PermissionRead}, Sources: []string{"billing-*"}},
+		},
+	})
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+	roles := a.Roles()
+
+	testCases := []struct {
+		name              string
+		md                metadata.MD
+		method            string
+		expectedPrincipal *Principal
+		expectedCode      codes.Code
+	}{
+		{
+			name:              "bearer key",
+			md:                metadata.Pairs("authorization", "Bearer agent-key"),
+			method:            "/logstream.LogsService/SaveLog",
+			expectedPrincipal: &Principal{Name: "agent", KeyID: 1, Role: roles[RoleWriter]},
+		},
+		{
+			name:              "x-api-key",
+			md:                metadata.Pairs("x-api-key", "agent-key"),
+			method:            "/logstream.LogsService/SaveLog",
+			expectedPrincipal: &Principal{Name: "agent", KeyID: 1, Role: roles[RoleWriter]},
+		},
+		{
+			name:              "admin key calls admin service",
+			md:                metadata.Pairs("authorization", "bearer admin-key"),
+			method:            "/logstream.AdminService/ListAPIKeys",
+			expectedPrincipal: &Principal{Name: "ops", KeyID: 2, Role: roles[RoleAdmin]},
+		},
+		{
+			name:              "bootstrap key",
+			md:                metadata.Pairs("authorization", "Bearer bootstrap-key"),
+			method:            "/logstream.AdminService/CreateAPIKey",
+			expectedPrincipal: &Principal{Name: "bootstrap", Role: roles[RoleAdmin]},
+		},
+		{
+			name:         "non admin key calls admin service",
+			md:           metadata.Pairs("authorization", "Bearer agent-key"),
+			method:       "/logstream.AdminService/CreateAPIKey",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:              "reader key reads",
+			md:                metadata.Pairs("authorization", "Bearer dashboard-key"),
+			method:            "/logstream.LogsService/ListLogs",
+			expectedPrincipal: &Principal{Name: "grafana", KeyID: 5, Role: roles["dashboard"]},
+		},
+		{
+			name:         "reader key writes",
+			md:           metadata.Pairs("authorization", "Bearer dashboard-key"),
+			method:       "/logstream.LogsService/SaveLogStream",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "writer key reads",
+			md:           metadata.Pairs("authorization", "Bearer agent-key"),
+			method:       "/logstream.LogsService/ListLog",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "key with unknown role",
+			md:           metadata.Pairs("authorization", "Bearer unknown-role"),
+			method:       "/logstream.LogsService/ListLog",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "revoked key",
+			md:           metadata.Pairs("authorization", "Bearer revoked-key"),
+			method:       "/logstream.LogsService/SaveLog",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "expired key",
+			md:           metadata.Pairs("authorization", "Bearer expired-key"),
+			method:       "/logstream.LogsService/SaveLog",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "unknown key",
+			md:           metadata.Pairs("authorization", "Bearer unknown-key"),
+			method:       "/logstream.LogsService/SaveLog",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "no credentials",
+			md:           metadata.MD{},
+			method:       "/logstream.LogsService/ListLogs",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:   "health check without credentials",
+			md:     metadata.MD{},
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+
+			var principal *Principal
+			_, err := a.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method},
+				func(ctx context.Context, req any) (any, error) {
+					principal, _ = FromContext(ctx)
+					return nil, nil
+				})
+
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedPrincipal, principal)
+			} else {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+				assert.Nil(t, principal)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorCache(t *testing.T) {
+	now := time.Unix(1749108957, 0)
+
+	keys := &keysRepo{
+		keys: map[string]*repo.APIKey{
+			HashKey("agent-key"): {Id: 1, Name: "agent", Role: RoleWriter},
+		},
+	}
+	a, err := New(keys, &config.AuthConfig{CacheTTL: time.Minute})
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "agent-key"))
+	for range 3 {
+		_, err := a.Authenticate(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, keys.lookups)
+
+	keys.keys[HashKey("agent-key")].RevokedAt = now.Unix()
+	now = now.Add(time.Minute)
+	_, err = a.Authenticate(ctx)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, 2, keys.lookups)
+}
+
+func TestGenerateKey(t *testing.T) {
+	key, prefix, hash, err := GenerateKey()
+	require.NoError(t, err)
+
+	assert.Len(t, key, 47)
+	assert.Equal(t, key[:12], prefix)
+	assert.Equal(t, HashKey(key), hash)
+}
+
+func TestRoles(t *testing.T) {
+	roles, err := NewRoles([]*config.RoleConfig{
+		{Name: "billing", Permissions: []string{PermissionRead, PermissionWrite}, Sources: []string{"billing-*", "payments"}},
+		{Name: "k8s", Permissions: []string{PermissionRead}, Sources: []string{"k8s/payments/*"}},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		role        string
+		source      string
+		expectRead  bool
+		expectWrite bool
+	}{
+		{name: "admin", role: RoleAdmin, source: "api", expectRead: true, expectWrite: true},
+		{name: "reader", role: RoleReader, source: "api", expectRead: true},
+		{name: "writer", role: RoleWriter, source: "api", expectWrite: true},
+		{name: "scoped by glob", role: "billing", source: "billing-worker", expectRead: true, expectWrite: true},
+		{name: "scoped exact", role: "billing", source: "payments", expectRead: true, expectWrite: true},
+		{name: "out of scope", role: "billing", source: "api"},
+		{name: "admin source with slash", role: RoleAdmin, source: "k8s/ns/pod", expectRead: true, expectWrite: true},
+		{name: "readwrite source with slash", role: RoleReadWrite, source: "k8s/ns/pod", expectRead: true, expectWrite: true},
+		{name: "glob spans slash", role: "billing", source: "billing-eu/worker", expectRead: true, expectWrite: true},
+		{name: "scoped with slash", role: "k8s", source: "k8s/payments/api/7f9c", expectRead: true},
+		{name: "out of scope with slash", role: "k8s", source: "k8s/billing/api"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := WithPrincipal(context.Background(), &Principal{Name: "test", Role: roles[tc.role]})
+
+			assert.Equal(t, tc.expectRead, CanRead(ctx, tc.source))
+			assert.Equal(t, tc.expectWrite, CanWrite(ctx, tc.source))
+		})
+	}
+
+	_, err = NewRoles([]*config.RoleConfig{{Name: "bad", Permissions: []string{"delete"}}})
+	assert.EqualError(t, err, `auth.roles[0]: invalid permission "delete": should be read, write or admin`)
+}
+
+func TestAuthenticatorTenant(t *testing.T) {
+	keys := &keysRepo{
+		keys: map[string]*repo.APIKey{
+			HashKey("acme-key"): {Id: 1, Name: "agent", Role: RoleReadWrite, Tenant: "acme"},
+		},
+	}
+	a, err := New(keys, &config.AuthConfig{
+		AdminKeyHashes: []string{HashKey("bootstrap-key")},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		md             metadata.MD
+		expectedTenant string
+		expectedCode   codes.Code
+	}{
+		{
+			name:           "tenant of key",
+			md:             metadata.Pairs("x-api-key", "acme-key"),
+			expectedTenant: "acme",
+		},
+		{
+			name:           "same tenant requested",
+			md:             metadata.Pairs("x-api-key", "acme-key", "x-tenant-id", "acme"),
+			expectedTenant: "acme",
+		},
+		{
+			name:         "other tenant requested",
+			md:           metadata.Pairs("x-api-key", "acme-key", "x-tenant-id", "globex"),
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:           "bootstrap key selects tenant",
+			md:             metadata.Pairs("x-api-key", "bootstrap-key", "x-tenant-id", "globex"),
+			expectedTenant: "globex",
+		},
+		{
+			name:           "bootstrap key without tenant",
+			md:             metadata.Pairs("x-api-key", "bootstrap-key"),
+			expectedTenant: tenant.Default,
+		},
+		{
+			name:         "invalid tenant",
+			md:           metadata.Pairs("x-api-key", "bootstrap-key", "x-tenant-id", "Globex Inc"),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+
+			var tenantID string
+			_, err := a.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/logstream.LogsService/ListLogs"},
+				func(ctx context.Context, req any) (any, error) {
+					tenantID = tenant.FromContext(ctx)
+					return nil, nil
+				})
+
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedTenant, tenantID)
+			} else {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestRoleCovers(t *testing.T) {
+	roles, err := NewRoles([]*config.RoleConfig{
+		{Name: "billing-admin", Permissions: []string{PermissionRead, PermissionWrite, PermissionAdmin}, Sources: []string{"billing-*", "payments"}},
+		{Name: "billing", Permissions: []string{PermissionRead}, Sources: []string{"billing-*"}},
+		{Name: "billing-eu", Permissions: []string{PermissionRead}, Sources: []string{"billing-eu-*", "payments"}},
+		{Name: "billing-any", Permissions: []string{PermissionRead}, Sources: []string{"*-billing"}},
+		{Name: "k8s", Permissions: []string{PermissionRead}, Sources: []string{"k8s/payments/*"}},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		role     string
+		other    string
+		expected bool
+	}{
+		{name: "admin covers every role", role: RoleAdmin, other: "billing-admin", expected: true},
+		{name: "same role", role: "billing-admin", other: "billing-admin", expected: true},
+		{name: "narrower role", role: "billing-admin", other: "billing", expected: true},
+		{name: "narrower pattern", role: "billing-admin", other: "billing-eu", expected: true},
+		{name: "built-in admin", role: "billing-admin", other: RoleAdmin},
+		{name: "built-in reader", role: "billing-admin", other: RoleReader},
+		{name: "more permissions", role: "billing", other: "billing-admin"},
+		{name: "other sources", role: "billing-admin", other: "k8s"},
+		{name: "pattern not compared", role: "billing-admin", other: "billing-any"},
+		{name: "glob spans slash", role: RoleReader, other: "k8s", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, roles[tc.role].Covers(roles[tc.other]))
+		})
+	}
+}
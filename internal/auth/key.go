@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	keyPrefix    = "lsk_"
+	keyPrefixLen = 12
+)
+
+// GenerateKey generates new API key. Only hash and prefix of the key are
+// stored.
+func GenerateKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:keyPrefixLen], HashKey(key), nil
+}
+
+// HashKey returns SHA-256 hex hash of key.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/config"
+)
+
+// Permissions
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+// Built-in roles
+const (
+	RoleAdmin     = "admin"
+	RoleReader    = "reader"
+	RoleWriter    = "writer"
+	RoleReadWrite = "readwrite"
+)
+
+// Role - permitted RPCs and sources
+type Role struct {
+	Name        string
+	Permissions []string
+	// Sources - glob patterns of sources the role may read and write, '*'
+	// matches '/' too
+	Sources []string
+}
+
+// Roles - roles by name
+type Roles map[string]*Role
+
+var builtinRoles = []*Role{
+	{Name: RoleAdmin, Permissions: []string{PermissionRead, PermissionWrite, PermissionAdmin}, Sources: []string{"*"}},
+	{Name: RoleReader, Permissions: []string{PermissionRead}, Sources: []string{"*"}},
+	{Name: RoleWriter, Permissions: []string{PermissionWrite}, Sources: []string{"*"}},
+	{Name: RoleReadWrite, Permissions: []string{PermissionRead, PermissionWrite}, Sources: []string{"*"}},
+}
+
+// NewRoles creates built-in roles extended or overridden by configured ones.
+func NewRoles(cfgs []*config.RoleConfig) (Roles, error) {
+	roles := make(Roles, len(builtinRoles)+len(cfgs))
+	for _, role := range builtinRoles {
+		roles[role.Name] = role
+	}
+
+	for i, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("auth.roles[%d]: name is empty", i)
+		}
+		for _, permission := range cfg.Permissions {
+			switch permission {
+			case PermissionRead, PermissionWrite, PermissionAdmin:
+			default:
+				return nil, fmt.Errorf("auth.roles[%d]: invalid permission %q: should be read, write or admin", i, permission)
+			}
+		}
+		for _, pattern := range cfg.Sources {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("auth.roles[%d]: invalid source pattern %q: %v", i, pattern, err)
+			}
+		}
+		roles[cfg.Name] = &Role{
+			Name:        cfg.Name,
+			Permissions: cfg.Permissions,
+			Sources:     cfg.Sources,
+		}
+	}
+
+	return roles, nil
+}
+
+// Has reports whether role has permission.
+func (r *Role) Has(permission string) bool {
+	return slices.Contains(r.Permissions, permission)
+}
+
+// Matches reports whether source matches one of role source patterns.
+func (r *Role) Matches(source string) bool {
+	for _, pattern := range r.Sources {
+		if matchSource(pattern, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether role grants everything other does: each of its
+// permissions and every source its patterns match.
+func (r *Role) Covers(other *Role) bool {
+	for _, permission := range other.Permissions {
+		if !r.Has(permission) {
+			return false
+		}
+	}
+	for _, pattern := range other.Sources {
+		if !slices.ContainsFunc(r.Sources, func(p string) bool { return coversPattern(p, pattern) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// coversPattern reports whether every source matching other matches
+// pattern. It is conservative: besides equal patterns, only a literal
+// other or a pattern of a literal prefix and '*' are compared.
+func coversPattern(pattern, other string) bool {
+	if pattern == other {
+		return true
+	}
+	if !strings.ContainsAny(other, `*?[\`) {
+		return matchSource(pattern, other)
+	}
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return ok && !strings.ContainsAny(prefix, `*?[\`) && strings.HasPrefix(other, prefix)
+}
+
+// matchSource matches source against glob pattern of path.Match, except
+// that sources are not paths: '*' and '?' match '/' too. Slashes are
+// swapped for a character path.Match does not treat as a separator.
+func matchSource(pattern, source string) bool {
+	ok, _ := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(source, "/", "\x00"))
+	return ok
+}
+
+// CanRead reports whether caller in ctx may read logs of source.
+// Requests without principal (authentication disabled) may read everything.
+func CanRead(ctx context.Context, source string) bool {
+	return can(ctx, PermissionRead, source)
+}
+
+// CanWrite reports whether caller in ctx may write logs of source.
+func CanWrite(ctx context.Context, source string) bool {
+	return can(ctx, PermissionWrite, source)
+}
+
+func can(ctx context.Context, permission, source string) bool {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return true
+	}
+	return p.Role.Has(permission) && p.Role.Matches(source)
+}
+
+// CheckRead returns PermissionDenied if caller may not read logs of source.
+func CheckRead(ctx context.Context, source string) error {
+	if !CanRead(ctx, source) {
+		return status.Errorf(codes.PermissionDenied, "no read access to source %q", source)
+	}
+	return nil
+}
+
+// CheckWrite returns PermissionDenied if caller may not write logs of source.
+func CheckWrite(ctx context.Context, source string) error {
+	if !CanWrite(ctx, source) {
+		return status.Errorf(codes.PermissionDenied, "no write access to source %q", source)
+	}
+	return nil
+}
+
+// CheckGrant returns PermissionDenied if caller in ctx may not give role to
+// an API key: the role must not grant more than the caller's one.
+func CheckGrant(ctx context.Context, role *Role) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if p.Role == nil || !p.Role.Covers(role) {
+		return status.Errorf(codes.PermissionDenied, "role %q grants more than role of the caller", role.Name)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ConnConfig describes how to connect to logstream server.
+type ConnConfig struct {
+	Addr               string
+	TLS                bool
+	CACert             string
+	Cert               string
+	Key                string
+	ServerName         string
+	InsecureSkipVerify bool
+	// APIKey - sent as bearer token with every call
+	APIKey string
+	// Tenant - sent as x-tenant-id with every call
+	Tenant string
+}
+
+// RegisterFlags registers connection flags in fs.
+func (c *ConnConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", "localhost:8080", "server address")
+	fs.BoolVar(&c.TLS, "tls", false, "use TLS")
+	fs.StringVar(&c.CACert, "ca-cert", "", "CA certificate to verify server (implies -tls)")
+	fs.StringVar(&c.Cert, "cert", "", "client certificate for mutual TLS (implies -tls)")
+	fs.StringVar(&c.Key, "key", "", "client private key for mutual TLS")
+	fs.StringVar(&c.ServerName, "server-name", "", "override TLS server name")
+	fs.BoolVar(&c.InsecureSkipVerify, "insecure-skip-verify", false, "do not verify server certificate")
+	fs.StringVar(&c.APIKey, "api-key", os.Getenv("LOGSTREAM_API_KEY"), "API key (default $LOGSTREAM_API_KEY)")
+	fs.StringVar(&c.Tenant, "tenant", os.Getenv("LOGSTREAM_TENANT"), "tenant (default $LOGSTREAM_TENANT or tenant of the API key)")
+}
+
+// Dial creates client connection to logstream server.
+func Dial(cfg *ConnConfig, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts...)
+	if cfg.APIKey != "" || cfg.Tenant != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&callCredentials{
+			apiKey: cfg.APIKey,
+			tenant: cfg.Tenant,
+		}))
+	}
+
+	conn, err := grpc.NewClient(cfg.Addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init connection: %v", err)
+	}
+	return conn, nil
+}
+
+func transportCredentials(cfg *ConnConfig) (credentials.TransportCredentials, error) {
+	if !cfg.TLS && cfg.CACert == "" && cfg.Cert == "" && !cfg.InsecureSkipVerify {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", cfg.CACert)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.Cert != "" || cfg.Key != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// callCredentials implements credentials.PerRPCCredentials
+type callCredentials struct {
+	apiKey string
+	tenant string
+}
+
+func (c *callCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := make(map[string]string, 2)
+	if c.apiKey != "" {
+		md["authorization"] = "Bearer " + c.apiKey
+	}
+	if c.tenant != "" {
+		md["x-tenant-id"] = c.tenant
+	}
+	return md, nil
+}
+
+// RequireTransportSecurity allows plaintext connections for local development.
+func (c *callCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+// Output formats supported by NewFormatter.
+const (
+	FormatTable  = "table"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatLogfmt = "logfmt"
+	FormatPretty = "pretty"
+)
+
+// Formats lists supported output formats.
+var Formats = []string{FormatTable, FormatJSON, FormatNDJSON, FormatLogfmt, FormatPretty}
+
+const (
+	humanTimeLayout = "2006-01-02 15:04:05"
+
+	colorReset  = "\033[0m"
+	colorDim    = "\033[2m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// Formatter writes logs in a particular output format.
+// Flush must be called to output buffered logs.
+type Formatter interface {
+	// Write - write log
+	Write(log *pb.Log) error
+
+	// Flush - flush buffered logs
+	Flush() error
+}
+
+// NewFormatter creates formatter by format name. Color is only used by
+// the pretty format.
+func NewFormatter(format string, w io.Writer, color bool) (Formatter, error) {
+	switch format {
+	case FormatTable:
+		return newTableFormatter(w), nil
+	case FormatJSON:
+		return &jsonFormatter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonFormatter{enc: json.NewEncoder(w)}, nil
+	case FormatLogfmt:
+		return &logfmtFormatter{w: w}, nil
+	case FormatPretty:
+		return &prettyFormatter{w: w, color: color}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q: should be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+type jsonLog struct {
+	Id        *int32 `json:"id,omitempty"`
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+func toJSONLog(log *pb.Log) *jsonLog {
+	return &jsonLog{
+		Id:        log.Id,
+		Timestamp: formatTimestamp(log.GetTimestamp()),
+		Level:     loglevel.Name(log.GetLevel()),
+		Source:    log.GetSource(),
+		Message:   log.GetMessage(),
+
+		Attributes: log.GetAttributes(),
+	}
+}
+
+func formatTimestamp(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+func formatID(id *int32) string {
+	if id == nil {
+		return "-"
+	}
+	return strconv.Itoa(int(*id))
+}
+
+type tableFormatter struct {
+	tw     *tabwriter.Writer
+	header bool
+}
+
+func newTableFormatter(w io.Writer) *tableFormatter {
+	return &tableFormatter{
+		tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0),
+	}
+}
+
+func (f *tableFormatter) Write(log *pb.Log) error {
+	if !f.header {
+		if _, err := fmt.Fprintln(f.tw, "ID\tTIME\tLEVEL\tSOURCE\tMESSAGE"); err != nil {
+			return err
+		}
+		f.header = true
+	}
+
+	message := strings.ReplaceAll(log.GetMessage(), "\n", `\n`)
+	_, err := fmt.Fprintf(f.tw, "%s\t%s\t%s\t%s\t%s\n",
+		formatID(log.Id),
+		time.Unix(log.GetTimestamp(), 0).Format(humanTimeLayout),
+		loglevel.Name(log.GetLevel()),
+		log.GetSource(),
+		message,
+	)
+	return err
+}
+
+func (f *tableFormatter) Flush() error {
+	return f.tw.Flush()
+}
+
+type jsonFormatter struct {
+	w    io.Writer
+	logs []*jsonLog
+}
+
+func (f *jsonFormatter) Write(log *pb.Log) error {
+	f.logs = append(f.logs, toJSONLog(log))
+	return nil
+}
+
+func (f *jsonFormatter) Flush() error {
+	logs := f.logs
+	if logs == nil {
+		logs = []*jsonLog{}
+	}
+	f.logs = nil
+
+	data, err := json.MarshalIndent(logs, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f.w, string(data))
+	return err
+}
+
+type ndjsonFormatter struct {
+	enc *json.Encoder
+}
+
+func (f *ndjsonFormatter) Write(log *pb.Log) error {
+	return f.enc.Encode(toJSONLog(log))
+}
+
+func (f *ndjsonFormatter) Flush() error {
+	return nil
+}
+
+type logfmtFormatter struct {
+	w io.Writer
+}
+
+func (f *logfmtFormatter) Write(log *pb.Log) error {
+	pairs := [][2]string{
+		{"time", formatTimestamp(log.GetTimestamp())},
+		{"level", strings.ToLower(loglevel.Name(log.GetLevel()))},
+		{"source", log.GetSource()},
+		{"msg", log.GetMessage()},
+	}
+	if log.Id != nil {
+		pairs = append([][2]string{{"id", formatID(log.Id)}}, pairs...)
+	}
+	for _, key := range sortedKeys(log.GetAttributes()) {
+		pairs = append(pairs, [2]string{key, log.GetAttributes()[key]})
+	}
+
+	var b strings.Builder
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pair[0])
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(pair[1]))
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+func (f *logfmtFormatter) Flush() error {
+	return nil
+}
+
+func logfmtValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	if strings.ContainsAny(v, " =\"\t\r\n\\") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+type prettyFormatter struct {
+	w     io.Writer
+	color bool
+}
+
+func (f *prettyFormatter) Write(log *pb.Log) error {
+	ts := time.Unix(log.GetTimestamp(), 0).Format(humanTimeLayout)
+	level := fmt.Sprintf("%-5s", loglevel.Name(log.GetLevel()))
+	source := log.GetSource()
+
+	if f.color {
+		ts = colorDim + ts + colorReset
+		level = levelColor(log.GetLevel()) + level + colorReset
+		source = colorCyan + source + colorReset
+	}
+
+	var attrs strings.Builder
+	for _, key := range sortedKeys(log.GetAttributes()) {
+		attrs.WriteByte(' ')
+		if f.color {
+			attrs.WriteString(colorDim)
+		}
+		attrs.WriteString(key)
+		attrs.WriteByte('=')
+		attrs.WriteString(logfmtValue(log.GetAttributes()[key]))
+		if f.color {
+			attrs.WriteString(colorReset)
+		}
+	}
+
+	_, err := fmt.Fprintf(f.w, "%s %s %s: %s%s\n", ts, level, source, log.GetMessage(), attrs.String())
+	return err
+}
+
+func (f *prettyFormatter) Flush() error {
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func levelColor(level pb.Level) string {
+	switch level {
+	case pb.Level_LEVEL_WARN:
+		return colorYellow
+	case pb.Level_LEVEL_ERROR:
+		return colorRed
+	default:
+		return colorGreen
+	}
+}
@@ -0,0 +1,63 @@
+package cli_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/cli"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestFormatter(t *testing.T) {
+	id := int32(7)
+	log := &pb.Log{
+		Id:        &id,
+		Source:    "api",
+		Level:     pb.Level_LEVEL_ERROR,
+		Message:   "request failed: timeout",
+		Timestamp: 1749108957,
+	}
+
+	testCases := []struct {
+		name           string
+		format         string
+		expectedOutput string
+		expectedErr    string
+	}{
+		{
+			name:           "ndjson",
+			format:         cli.FormatNDJSON,
+			expectedOutput: `{"id":7,"timestamp":"2025-06-05T07:35:57Z","level":"ERROR","source":"api","message":"request failed: timeout"}` + "\n",
+		},
+		{
+			name:           "logfmt",
+			format:         cli.FormatLogfmt,
+			expectedOutput: `id=7 time=2025-06-05T07:35:57Z level=error source=api msg="request failed: timeout"` + "\n",
+		},
+		{
+			name:        "unknown format",
+			format:      "xml",
+			expectedErr: "unknown output format",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			f, err := cli.NewFormatter(tc.format, &buf, false)
+			if tc.expectedErr != "" {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.NoError(t, f.Write(log))
+			require.NoError(t, f.Flush())
+			assert.Equal(t, tc.expectedOutput, buf.String())
+		})
+	}
+}
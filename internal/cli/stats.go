@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+// Stats aggregates log counts per level, optionally grouped by time buckets.
+type Stats struct {
+	Source  string         `json:"source"`
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Total   int            `json:"total"`
+	Levels  map[string]int `json:"levels"`
+	First   *time.Time     `json:"first,omitempty"`
+	Last    *time.Time     `json:"last,omitempty"`
+	Buckets []*StatsBucket `json:"buckets,omitempty"`
+
+	bucket time.Duration
+	index  map[int64]*StatsBucket
+}
+
+// StatsBucket holds counts of a single time bucket.
+type StatsBucket struct {
+	Start  time.Time      `json:"start"`
+	Total  int            `json:"total"`
+	Levels map[string]int `json:"levels"`
+}
+
+// NewStats creates empty stats. Zero bucket disables time grouping.
+func NewStats(source string, start, end time.Time, bucket time.Duration) *Stats {
+	return &Stats{
+		Source: source,
+		Start:  start,
+		End:    end,
+		Levels: newLevelCounts(),
+		bucket: bucket,
+		index:  make(map[int64]*StatsBucket),
+	}
+}
+
+func newLevelCounts() map[string]int {
+	counts := make(map[string]int, len(pb.Level_name))
+	for v := range pb.Level_name {
+		counts[loglevel.Name(pb.Level(v))] = 0
+	}
+	return counts
+}
+
+// Add counts log.
+func (s *Stats) Add(log *pb.Log) {
+	ts := time.Unix(log.GetTimestamp(), 0)
+	level := loglevel.Name(log.GetLevel())
+
+	s.Total++
+	s.Levels[level]++
+	if s.First == nil || ts.Before(*s.First) {
+		s.First = &ts
+	}
+	if s.Last == nil || ts.After(*s.Last) {
+		s.Last = &ts
+	}
+
+	if s.bucket <= 0 {
+		return
+	}
+
+	start := ts.Truncate(s.bucket)
+	b, ok := s.index[start.Unix()]
+	if !ok {
+		b = &StatsBucket{Start: start, Levels: newLevelCounts()}
+		s.index[start.Unix()] = b
+		s.insertBucket(b)
+	}
+	b.Total++
+	b.Levels[level]++
+}
+
+func (s *Stats) insertBucket(b *StatsBucket) {
+	i := len(s.Buckets)
+	for i > 0 && s.Buckets[i-1].Start.After(b.Start) {
+		i--
+	}
+	s.Buckets = append(s.Buckets, nil)
+	copy(s.Buckets[i+1:], s.Buckets[i:])
+	s.Buckets[i] = b
+}
+
+// WriteTable writes stats as human-readable table.
+func (s *Stats) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "SOURCE\t%s\n", s.Source)
+	fmt.Fprintf(tw, "RANGE\t%s - %s\n", s.Start.Format(humanTimeLayout), s.End.Format(humanTimeLayout))
+	if s.First != nil {
+		fmt.Fprintf(tw, "FIRST\t%s\n", s.First.Format(humanTimeLayout))
+		fmt.Fprintf(tw, "LAST\t%s\n", s.Last.Format(humanTimeLayout))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\n", s.Total)
+	for _, level := range levelNames() {
+		fmt.Fprintf(tw, "%s\t%d\n", level, s.Levels[level])
+	}
+
+	if len(s.Buckets) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprint(tw, "BUCKET\tTOTAL")
+		for _, level := range levelNames() {
+			fmt.Fprintf(tw, "\t%s", level)
+		}
+		fmt.Fprintln(tw)
+		for _, b := range s.Buckets {
+			fmt.Fprintf(tw, "%s\t%d", b.Start.Format(humanTimeLayout), b.Total)
+			for _, level := range levelNames() {
+				fmt.Fprintf(tw, "\t%d", b.Levels[level])
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes stats as JSON document.
+func (s *Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func levelNames() []string {
+	names := make([]string, 0, len(pb.Level_name))
+	for v := int32(0); v < int32(len(pb.Level_name)); v++ {
+		names = append(names, loglevel.Name(pb.Level(v)))
+	}
+	return names
+}
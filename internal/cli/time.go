@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTime parses human-friendly time expressions relative to now.
+//
+// Supported forms:
+//   - "now"
+//   - relative durations: "-15m", "15m" (both mean 15 minutes ago), "+1h"
+//   - day durations: "-2d", "7d"
+//   - RFC3339 timestamps: "2025-06-05T07:35:57Z"
+//   - dates and date-times: "2025-06-05", "2025-06-05 07:35:57" (local time)
+//   - unix seconds: "1749108957"
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+
+	if strings.EqualFold(s, "now") {
+		return now, nil
+	}
+
+	if d, ok := parseRelative(s); ok {
+		return now.Add(d), nil
+	}
+
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q: expected now, a duration like -15m, RFC3339 or unix seconds", s)
+}
+
+// parseRelative parses durations with an optional sign. Unsigned durations
+// point to the past, so "15m" and "-15m" are equivalent.
+func parseRelative(s string) (time.Duration, bool) {
+	sign := time.Duration(-1)
+	switch s[0] {
+	case '-':
+		s = s[1:]
+	case '+':
+		sign = 1
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, false
+	}
+
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, false
+		}
+		return sign * time.Duration(days*float64(24*time.Hour)), true
+	}
+
+	// bare numbers are unix timestamps, not durations
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return sign * d, true
+}
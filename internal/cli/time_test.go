@@ -0,0 +1,86 @@
+package cli_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/cli"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 6, 5, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		input        string
+		expectedTime time.Time
+		expectedErr  string
+	}{
+		{
+			name:         "now",
+			input:        "now",
+			expectedTime: now,
+		},
+		{
+			name:         "negative duration",
+			input:        "-15m",
+			expectedTime: now.Add(-15 * time.Minute),
+		},
+		{
+			name:         "unsigned duration",
+			input:        "2h",
+			expectedTime: now.Add(-2 * time.Hour),
+		},
+		{
+			name:         "positive duration",
+			input:        "+1h",
+			expectedTime: now.Add(time.Hour),
+		},
+		{
+			name:         "days",
+			input:        "-2d",
+			expectedTime: now.Add(-48 * time.Hour),
+		},
+		{
+			name:         "rfc3339",
+			input:        "2025-06-01T10:00:00Z",
+			expectedTime: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "date",
+			input:        "2025-06-01",
+			expectedTime: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "unix seconds",
+			input:        "1749108957",
+			expectedTime: time.Unix(1749108957, 0),
+		},
+		{
+			name:        "empty",
+			input:       "",
+			expectedErr: "empty time",
+		},
+		{
+			name:        "garbage",
+			input:       "yesterday-ish",
+			expectedErr: "invalid time",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actualTime, err := cli.ParseTime(tc.input, now)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.True(t, tc.expectedTime.Equal(actualTime), "expected %v, got %v", tc.expectedTime, actualTime)
+			} else {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import "time"
+
+type AgentConfig struct {
+	Server             *AgentServerConfig `json:"server"`
+	CheckpointPath     string             `json:"checkpoint_path"`
+	PollInterval       time.Duration      `json:"poll_interval"`
+	CheckpointInterval time.Duration      `json:"checkpoint_interval"`
+	DrainTimeout       time.Duration      `json:"drain_timeout"`
+	Files              []*AgentFileConfig `json:"files"`
+}
+
+type AgentServerConfig struct {
+	Addr               string `json:"addr"`
+	TLS                bool   `json:"tls"`
+	CACert             string `json:"ca_cert"`
+	Cert               string `json:"cert"`
+	Key                string `json:"key"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	APIKey             string `json:"api_key"`
+	Tenant             string `json:"tenant"`
+}
+
+type AgentFileConfig struct {
+	// Paths - file paths or glob patterns
+	Paths []string `json:"paths"`
+	// Source - log source, defaults to file name without extension
+	Source string `json:"source"`
+	// Level - level of lines matching no level pattern
+	Level string `json:"level"`
+	// LevelPatterns - "level=regexp" rules checked before default patterns
+	LevelPatterns []string `json:"level_patterns"`
+	// Parse - line format: text, json, logfmt, auto
+	Parse string `json:"parse"`
+	// Multiline - regexp of continuation lines, empty disables joining
+	Multiline *string `json:"multiline"`
+	// MultilineTimeout - flush joined record after this idle time
+	MultilineTimeout time.Duration `json:"multiline_timeout"`
+	// MaxLines - max lines joined into one record
+	MaxLines int `json:"max_lines"`
+	// StartAt - where to start files without checkpoint found on startup: beginning, end
+	StartAt string `json:"start_at"`
+}
+
+// AgentEnvPrefix - prefix of environment variables overriding agent config,
+// e.g. LOGSTREAM_AGENT_SERVER_API_KEY overrides server.api_key
+const AgentEnvPrefix = "LOGSTREAM_AGENT_"
+
+func LoadAgent(configPath string) (*AgentConfig, error) {
+	var cfg AgentConfig
+	if err := load(&cfg, defaultAgentConfig, configPath, AgentEnvPrefix, nil); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
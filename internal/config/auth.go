@@ -0,0 +1,40 @@
+package config
+
+import "time"
+
+type TLSConfig struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	// ClientCA - CA verifying client certificates, enables mutual TLS
+	ClientCA string `json:"client_ca"`
+	// RequireClientCert - reject clients without certificate signed by ClientCA
+	RequireClientCert bool `json:"require_client_cert"`
+}
+
+type AuthConfig struct {
+	Enabled bool `json:"enabled"`
+	// AdminKeyHashes - SHA-256 hex hashes of bootstrap admin keys
+	AdminKeyHashes []string `json:"admin_key_hashes"`
+	// Subjects - roles of client certificates by common name
+	Subjects []*SubjectConfig `json:"subjects"`
+	// Roles - custom roles, built-in ones are admin, reader, writer, readwrite
+	Roles []*RoleConfig `json:"roles"`
+	// CacheTTL - how long looked up keys are cached, revocation takes effect after it
+	CacheTTL time.Duration `json:"cache_ttl"`
+}
+
+type SubjectConfig struct {
+	// Name - client certificate common name
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// Tenant - tenant of the certificate, defaults to "default"
+	Tenant string `json:"tenant"`
+}
+
+type RoleConfig struct {
+	Name string `json:"name"`
+	// Permissions - read, write, admin
+	Permissions []string `json:"permissions"`
+	// Sources - glob patterns of sources the role may read and write
+	Sources []string `json:"sources"`
+}
@@ -3,21 +3,42 @@ package config
 import (
 	"log"
 	"path/filepath"
+	"time"
 
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/mitchellh/mapstructure"
 )
 
 type Config struct {
-	ServerConfig *ServerConfig `json:"server"`
-	DBConfig     *DBConfig     `json:"db"`
+	ServerConfig    *ServerConfig    `json:"server"`
+	DBConfig        *DBConfig        `json:"db"`
+	StorageConfig   *StorageConfig   `json:"storage"`
+	SpoolConfig     *SpoolConfig     `json:"spool"`
+	PipelineConfig  *PipelineConfig  `json:"pipeline"`
+	RedactionConfig *RedactionConfig `json:"redaction"`
+	LimitsConfig    *LimitsConfig    `json:"limits"`
+	AuthConfig      *AuthConfig      `json:"auth"`
+	RetentionConfig *RetentionConfig `json:"retention"`
+	MetricsConfig   *MetricsConfig   `json:"metrics"`
+	LogConfig       *LogConfig       `json:"log"`
+	HealthConfig    *HealthConfig    `json:"health"`
 }
 
 type ServerConfig struct {
-	Host string `json:"Host"`
-	Port int    `json:"port"`
+	Host string     `json:"host"`
+	Port int        `json:"port"`
+	TLS  *TLSConfig `json:"tls"`
+	// ShutdownTimeout - how long running calls may finish on shutdown
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	// DrainDelay - how long to report NOT_SERVING before draining, so
+	// load balancers stop sending new calls
+	DrainDelay time.Duration `json:"drain_delay"`
+	// MaxListLogs - hard cap of logs returned by ListLogs and
+	// ListLogsStream, also used when a request sets no limit; 0 means no cap
+	MaxListLogs int `json:"max_list_logs"`
 }
 
 type DBConfig struct {
@@ -26,35 +47,81 @@ type DBConfig struct {
 	Password string `json:"password"`
 	Name     string `json:"name"`
 	Port     int    `json:"port"`
+	// AutoMigrate - apply embedded migrations on start
+	AutoMigrate bool `json:"auto_migrate"`
 }
 
-func Load(configPath string) (*Config, error) {
+// EnvPrefix - prefix of environment variables overriding server config,
+// e.g. LOGSTREAM_DB_PASSWORD overrides db.password
+const EnvPrefix = "LOGSTREAM_"
+
+// Load loads server config from defaults, config file, environment and
+// overrides, e.g. set by flags, and validates it. Overrides are keyed by
+// config path like "storage.type".
+func Load(configPath string, overrides map[string]any) (*Config, error) {
+	var cfg Config
+	if err := load(&cfg, defaultConfig, configPath, EnvPrefix, overrides); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Printf("invalid config; err: %v", err)
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func load(cfg any, defaults map[string]interface{}, configPath, envPrefix string, overrides map[string]any) error {
 	k := koanf.New(".")
 
-	err := k.Load(confmap.Provider(defaultConfig, "."), nil)
+	err := k.Load(confmap.Provider(defaults, "."), nil)
 	if err != nil {
 		log.Printf("failed to load default config; err: %v", err)
-		return nil, err
+		return err
 	}
 
 	if configPath != "" {
 		path, err := filepath.Abs(configPath)
 		if err != nil {
 			log.Printf("failed to get absolute config path; configPath: %s, err: %v", configPath, err)
-			return nil, err
+			return err
 		}
 		log.Printf("Load config file from %s", path)
 		if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
 			log.Printf("failed to load config from file; err: %v", err)
-			return nil, err
+			return err
 		}
 	}
 
-	var cfg Config
-	if err := k.UnmarshalWithConf("", &cfg, koanf.UnmarshalConf{Tag: "json", FlatPaths: false}); err != nil {
+	if err := loadEnv(k, envPrefix, cfg); err != nil {
+		log.Printf("failed to load config from environment; err: %v", err)
+		return err
+	}
+
+	if len(overrides) > 0 {
+		if err := k.Load(confmap.Provider(overrides, "."), nil); err != nil {
+			log.Printf("failed to load config overrides; err: %v", err)
+			return err
+		}
+	}
+
+	// unknown keys are reported, so typos do not silently fall back to defaults
+	if err := k.UnmarshalWithConf("", cfg, koanf.UnmarshalConf{
+		Tag: "json",
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc()),
+			Result:           cfg,
+			WeaklyTypedInput: true,
+			ErrorUnused:      true,
+		},
+	}); err != nil {
 		log.Printf("failed to unmarshal with conf; err: %v", err)
-		return nil, err
+		return err
 	}
 
-	return &cfg, err
+	return nil
 }
@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadShippedConfigs(t *testing.T) {
+	cfg, err := config.Load("../../config/local.yml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "logstream", cfg.DBConfig.Name)
+
+	agentCfg, err := config.LoadAgent("../../config/agent.yml")
+	require.NoError(t, err)
+	assert.NotEmpty(t, agentCfg.Server.Addr)
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name        string
+		file        string
+		env         map[string]string
+		overrides   map[string]any
+		check       func(t *testing.T, cfg *config.Config)
+		expectedErr []string
+	}{
+		{
+			name: "env overrides file",
+			file: "db:\n  name: logstream\n  password: from-file\n",
+			env: map[string]string{
+				"LOGSTREAM_DB_PASSWORD":             "from-env",
+				"LOGSTREAM_SERVER_PORT":             "9000",
+				"LOGSTREAM_SERVER_SHUTDOWN_TIMEOUT": "5s",
+				"LOGSTREAM_AUTH_ADMIN_KEY_HASHES":   "a,b",
+				"LOGSTREAM_UNKNOWN":                 "ignored",
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, "from-env", cfg.DBConfig.Password)
+				assert.Equal(t, 9000, cfg.ServerConfig.Port)
+				assert.Equal(t, 5*time.Second, cfg.ServerConfig.ShutdownTimeout)
+				assert.Equal(t, []string{"a", "b"}, cfg.AuthConfig.AdminKeyHashes)
+			},
+		},
+		{
+			name: "secret from file",
+			file: "db:\n  name: logstream\n",
+			env: map[string]string{
+				"LOGSTREAM_DB_PASSWORD_FILE": writeFile(t, "password", "s3cret\n"),
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, "s3cret", cfg.DBConfig.Password)
+			},
+		},
+		{
+			name: "missing secret file",
+			file: "db:\n  name: logstream\n",
+			env: map[string]string{
+				"LOGSTREAM_DB_PASSWORD_FILE": "/nonexistent/password",
+			},
+			expectedErr: []string{"LOGSTREAM_DB_PASSWORD_FILE: failed to read secret"},
+		},
+		{
+			name:        "unknown key",
+			file:        "db:\n  name: logstream\n  passwrd: typo\n",
+			expectedErr: []string{"passwrd"},
+		},
+		{
+			name:      "memory storage does not need database",
+			file:      "storage:\n  type: postgres\n",
+			overrides: map[string]any{"storage.type": "memory"},
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, "memory", cfg.StorageConfig.Type)
+				assert.Equal(t, 1000000, cfg.StorageConfig.Memory.MaxLogs)
+			},
+		},
+		{
+			name:      "sqlite storage from flag",
+			overrides: config.ParseStorage("sqlite:/var/lib/logstream.db"),
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, "sqlite", cfg.StorageConfig.Type)
+				assert.Equal(t, "/var/lib/logstream.db", cfg.StorageConfig.SQLite.Path)
+			},
+		},
+		{
+			name:      "segment storage from flag",
+			overrides: config.ParseStorage("segment:/var/lib/logstream"),
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, "segment", cfg.StorageConfig.Type)
+				assert.Equal(t, "/var/lib/logstream", cfg.StorageConfig.Segment.Path)
+				assert.Equal(t, time.Hour, cfg.StorageConfig.Segment.Bucket)
+				assert.Equal(t, 10000, cfg.StorageConfig.Segment.FlushLogs)
+				assert.Equal(t, 10, cfg.StorageConfig.Segment.KeyFlushes)
+			},
+		},
+		{
+			name:        "unknown storage",
+			file:        "db:\n  name: logstream\nstorage:\n  type: mysql\n",
+			expectedErr: []string{`storage.type: must be one of postgres, memory, sqlite, segment, got "mysql"`},
+		},
+		{
+			name: "all problems are reported",
+			file: "server:\n  port: 0\n  max_list_logs: -1\ndb:\n  host: ''\nlog:\n  level: verbose\n",
+			expectedErr: []string{
+				"server.port: must be between 1 and 65535",
+				"server.max_list_logs: must not be negative",
+				"db.host: is required",
+				"db.name: is required",
+				`log.level: must be one of debug, info, warn, error, got "verbose"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := config.Load(writeFile(t, "config.yml", tc.file), tc.overrides)
+			if len(tc.expectedErr) == 0 {
+				require.NoError(t, err)
+				tc.check(t, cfg)
+			} else {
+				require.Error(t, err)
+				for _, expected := range tc.expectedErr {
+					assert.Contains(t, err.Error(), expected)
+				}
+				assert.Nil(t, cfg)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "config.yml", "db:\n  name: logstream\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	require.NoError(t, config.Watch(ctx, path, func() {
+		changed <- struct{}{}
+	}))
+
+	// editors replace the file instead of writing it
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte("db:\n  name: other\n"), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not noticed")
+	}
+}
@@ -4,8 +4,42 @@ var defaultConfig = map[string]interface{}{
 	"server.host": "localhost",
 	"server.port": "8080",
 
+	"server.shutdown_timeout": "30s",
+	"server.drain_delay":      "0s",
+
 	"db.host":     "localhost",
 	"db.user":     "postgres",
 	"db.password": "postgres",
 	"db.port":     5432,
+
+	"storage.type":            "postgres",
+	"storage.memory.max_logs": 1000000,
+	"storage.sqlite.path":     "logstream.db",
+
+	"storage.segment.path":        "data",
+	"storage.segment.bucket":      "1h",
+	"storage.segment.flush_logs":  10000,
+	"storage.segment.key_flushes": 10,
+
+	"spool.max_size":        1 << 30,
+	"spool.batch_size":      1000,
+	"spool.replay_interval": "1s",
+
+	"metrics.path": "/metrics",
+
+	"log.level":  "info",
+	"log.format": "text",
+
+	"health.interval":    "5s",
+	"health.timeout":     "2s",
+	"health.max_backlog": 1000,
+}
+
+var defaultAgentConfig = map[string]interface{}{
+	"server.addr": "localhost:8080",
+
+	"checkpoint_path":     "logstream-agent.checkpoint.json",
+	"poll_interval":       "1s",
+	"checkpoint_interval": "5s",
+	"drain_timeout":       "30s",
 }
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/env"
+)
+
+// fileSuffix - suffix of environment variables holding path of a file with
+// the value, e.g. LOGSTREAM_DB_PASSWORD_FILE=/run/secrets/db-password
+const fileSuffix = "_FILE"
+
+// envKeys maps environment variable names without prefix to config paths
+// of fields of t, e.g. DB_PASSWORD to db.password. Lists of sections are
+// configured in the file only.
+func envKeys(t reflect.Type) map[string]string {
+	keys := make(map[string]string)
+	collectEnvKeys(t, "", keys)
+	return keys
+}
+
+func collectEnvKeys(t reflect.Type, prefix string, keys map[string]string) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			collectEnvKeys(ft, path+".", keys)
+		case ft.Kind() == reflect.Map:
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.String:
+		default:
+			keys[strings.ToUpper(strings.ReplaceAll(path, ".", "_"))] = path
+		}
+	}
+}
+
+// loadEnv overrides config paths of target type with environment variables
+// with prefix. Variables with _FILE suffix name a file to read the value
+// from. Unknown variables are ignored.
+func loadEnv(k *koanf.Koanf, prefix string, target any) error {
+	keys := envKeys(reflect.TypeOf(target))
+
+	var errs []error
+	provider := env.ProviderWithValue(prefix, ".", func(name, value string) (string, interface{}) {
+		name = strings.TrimPrefix(name, prefix)
+		if path, ok := keys[name]; ok {
+			return path, value
+		}
+
+		path, ok := keys[strings.TrimSuffix(name, fileSuffix)]
+		if !ok || !strings.HasSuffix(name, fileSuffix) {
+			return "", nil
+		}
+		data, err := os.ReadFile(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: failed to read secret: %v", prefix, name, err))
+			return "", nil
+		}
+		return path, strings.TrimRight(string(data), "\r\n")
+	})
+
+	if err := k.Load(provider, nil); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
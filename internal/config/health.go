@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+type HealthConfig struct {
+	// Interval - how often readiness is checked
+	Interval time.Duration `json:"interval"`
+	// Timeout - timeout of storage checks
+	Timeout time.Duration `json:"timeout"`
+	// MaxBacklog - number of logs waiting for the storage above which
+	// the server is not ready, zero disables the check
+	MaxBacklog int `json:"max_backlog"`
+}
@@ -0,0 +1,30 @@
+package config
+
+type LimitsConfig struct {
+	// By - limit key: source, tenant
+	By string `json:"by"`
+	// Mode - what to do with over-limit logs: reject, sample
+	Mode string `json:"mode"`
+	// SampleEvery - in sample mode every n-th over-limit log is saved, the rest are dropped
+	SampleEvery int `json:"sample_every"`
+	// Default - limit of keys without override
+	Default *LimitConfig `json:"default"`
+	// Overrides - limits of specific keys
+	Overrides []*LimitConfig `json:"overrides"`
+}
+
+type LimitConfig struct {
+	// Key - source or tenant the override applies to, unused in default limit
+	Key string `json:"key"`
+	// Tenant - tenant of the source the override applies to, empty for the
+	// source of every tenant; unused when limits are keyed by tenant
+	Tenant string `json:"tenant"`
+	// Rate - logs per second, zero means unlimited
+	Rate float64 `json:"rate"`
+	// Burst - bucket size, defaults to rate
+	Burst int `json:"burst"`
+	// DailyLogs - logs per UTC day, zero means unlimited
+	DailyLogs int64 `json:"daily_logs"`
+	// DailyBytes - bytes of source, message and attributes per UTC day, zero means unlimited
+	DailyBytes int64 `json:"daily_bytes"`
+}
@@ -0,0 +1,8 @@
+package config
+
+type LogConfig struct {
+	// Level - debug, info, warn or error
+	Level string `json:"level"`
+	// Format - text or json
+	Format string `json:"format"`
+}
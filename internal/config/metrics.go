@@ -0,0 +1,7 @@
+package config
+
+type MetricsConfig struct {
+	// Addr - HTTP address of metrics endpoint, empty disables it
+	Addr string `json:"addr"`
+	Path string `json:"path"`
+}
@@ -0,0 +1,48 @@
+package config
+
+type PipelineConfig struct {
+	// Patterns - custom grok patterns available to all processors
+	Patterns   map[string]string  `json:"patterns"`
+	Processors []*ProcessorConfig `json:"processors"`
+}
+
+type ProcessorConfig struct {
+	// Type - processor type: regex, grok, json, level, source, attributes, drop, route
+	Type string `json:"type"`
+	// When - apply processor only to matching logs
+	When *ConditionConfig `json:"when"`
+
+	// Field - "message" or attribute name (regex, grok, json, level)
+	Field string `json:"field"`
+	// Pattern - regexp (regex, source) or grok expression (grok)
+	Pattern string `json:"pattern"`
+	// Overwrite - replace existing attributes (regex, grok, json)
+	Overwrite bool `json:"overwrite"`
+	// Mapping - value mapping (level, source)
+	Mapping map[string]string `json:"mapping"`
+	// Replacement - regexp replacement (source)
+	Replacement string `json:"replacement"`
+	// Add - attributes to add (attributes)
+	Add map[string]string `json:"add"`
+	// Drop - attributes to drop (attributes)
+	Drop []string `json:"drop"`
+	// Routes - conditional sub-pipelines, the first matching route wins (route)
+	Routes []*RouteConfig `json:"routes"`
+}
+
+type ConditionConfig struct {
+	// Source - source regexp
+	Source string `json:"source"`
+	// Message - message regexp
+	Message string `json:"message"`
+	// Levels - level names
+	Levels []string `json:"levels"`
+	// Attributes - attribute name to value regexp
+	Attributes map[string]string `json:"attributes"`
+}
+
+type RouteConfig struct {
+	When       *ConditionConfig   `json:"when"`
+	Drop       bool               `json:"drop"`
+	Processors []*ProcessorConfig `json:"processors"`
+}
@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+type RedactionConfig struct {
+	// Rules - built-in detectors: email, credit_card, jwt, bearer, ipv4, ipv6, api_key.
+	// Empty enables all of them.
+	Rules []string `json:"rules"`
+	// Custom - additional named regexps
+	Custom []*RedactionRuleConfig `json:"custom"`
+	// Mode - replacement mode: mask, hash
+	Mode string `json:"mode"`
+	// HashKey - HMAC key used in hash mode
+	HashKey string `json:"hash_key"`
+	// AuditInterval - how often redaction counters are logged, zero disables
+	AuditInterval time.Duration `json:"audit_interval"`
+}
+
+type RedactionRuleConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type RetentionConfig struct {
+	// Interval - how often old logs are deleted
+	Interval time.Duration `json:"interval"`
+	// MaxAge - age of logs to delete in tenants without override, zero keeps logs forever
+	MaxAge time.Duration `json:"max_age"`
+	// Tenants - per tenant overrides
+	Tenants []*TenantRetentionConfig `json:"tenants"`
+}
+
+type TenantRetentionConfig struct {
+	Tenant string        `json:"tenant"`
+	MaxAge time.Duration `json:"max_age"`
+}
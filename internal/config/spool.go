@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+type SpoolConfig struct {
+	// Path - directory of the spool keeping logs while the storage is
+	// unavailable, empty disables the spool
+	Path string `json:"path"`
+	// MaxSize - max bytes of spooled logs, logs are rejected above it
+	MaxSize int64 `json:"max_size"`
+	// BatchSize - max logs saved to the storage at once on replay
+	BatchSize int `json:"batch_size"`
+	// ReplayInterval - how often the storage is checked while there are
+	// spooled logs
+	ReplayInterval time.Duration `json:"replay_interval"`
+}
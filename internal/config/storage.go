@@ -0,0 +1,52 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+type StorageConfig struct {
+	// Type - storage backend: postgres, memory, sqlite, segment
+	Type string `json:"type"`
+	// Memory - settings of memory storage
+	Memory *MemoryStorageConfig `json:"memory"`
+	// SQLite - settings of sqlite storage
+	SQLite *SQLiteStorageConfig `json:"sqlite"`
+	// Segment - settings of segment storage
+	Segment *SegmentStorageConfig `json:"segment"`
+}
+
+type SQLiteStorageConfig struct {
+	// Path - database file, created if it does not exist
+	Path string `json:"path"`
+}
+
+type SegmentStorageConfig struct {
+	// Path - data directory, created if it does not exist
+	Path string `json:"path"`
+	// Bucket - time span of logs in one segment file
+	Bucket time.Duration `json:"bucket"`
+	// FlushLogs - number of logs kept in the WAL before they are written
+	// to segments
+	FlushLogs int `json:"flush_logs"`
+	// KeyFlushes - number of the last flushes whose idempotency keys are
+	// kept in memory; a log retried later is saved again
+	KeyFlushes int `json:"key_flushes"`
+}
+
+type MemoryStorageConfig struct {
+	// MaxLogs - number of kept logs, the oldest are evicted first; zero
+	// means no limit
+	MaxLogs int `json:"max_logs"`
+}
+
+// ParseStorage parses storage flag "type" or "type:path", e.g.
+// "sqlite:/var/lib/logstream.db", into config overrides.
+func ParseStorage(value string) map[string]any {
+	storageType, path, ok := strings.Cut(value, ":")
+	overrides := map[string]any{"storage.type": storageType}
+	if ok {
+		overrides["storage."+storageType+".path"] = path
+	}
+	return overrides
+}
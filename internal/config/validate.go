@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate checks server config and returns all found problems. Pipeline,
+// redaction, limits and auth sections are checked when they are built.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, path, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+		}
+	}
+	checkDuration := func(d time.Duration, path string) {
+		check(d >= 0, path, "must not be negative")
+	}
+
+	if c.ServerConfig == nil {
+		errs = append(errs, errors.New("server: is required"))
+	} else {
+		check(c.ServerConfig.Port > 0 && c.ServerConfig.Port < 1<<16, "server.port", "must be between 1 and 65535, got %d", c.ServerConfig.Port)
+		checkDuration(c.ServerConfig.ShutdownTimeout, "server.shutdown_timeout")
+		checkDuration(c.ServerConfig.DrainDelay, "server.drain_delay")
+		check(c.ServerConfig.MaxListLogs >= 0, "server.max_list_logs", "must not be negative")
+		if tls := c.ServerConfig.TLS; tls != nil {
+			check(tls.Cert != "", "server.tls.cert", "is required")
+			check(tls.Key != "", "server.tls.key", "is required")
+			check(tls.ClientCA != "" || !tls.RequireClientCert, "server.tls.client_ca", "is required with require_client_cert")
+		}
+	}
+
+	storageType := "postgres"
+	if c.StorageConfig != nil {
+		storageType = c.StorageConfig.Type
+		types := []string{"postgres", "memory", "sqlite", "segment"}
+		check(slices.Contains(types, storageType), "storage.type", "must be one of postgres, memory, sqlite, segment, got %q", storageType)
+		if c.StorageConfig.Memory != nil {
+			check(c.StorageConfig.Memory.MaxLogs >= 0, "storage.memory.max_logs", "must not be negative")
+		}
+		if storageType == "sqlite" {
+			check(c.StorageConfig.SQLite != nil && c.StorageConfig.SQLite.Path != "", "storage.sqlite.path", "is required")
+		}
+		if storageType == "segment" {
+			check(c.StorageConfig.Segment != nil && c.StorageConfig.Segment.Path != "", "storage.segment.path", "is required")
+		}
+		if segment := c.StorageConfig.Segment; segment != nil {
+			check(segment.Bucket >= time.Second, "storage.segment.bucket", "must be at least 1s")
+			check(segment.FlushLogs > 0, "storage.segment.flush_logs", "must be positive")
+			check(segment.KeyFlushes > 0, "storage.segment.key_flushes", "must be positive")
+		}
+	}
+
+	// database settings are needed only by postgres storage
+	if storageType == "postgres" {
+		if c.DBConfig == nil {
+			errs = append(errs, errors.New("db: is required"))
+		} else {
+			check(c.DBConfig.Host != "", "db.host", "is required")
+			check(c.DBConfig.User != "", "db.user", "is required")
+			check(c.DBConfig.Name != "", "db.name", "is required")
+			check(c.DBConfig.Port > 0 && c.DBConfig.Port < 1<<16, "db.port", "must be between 1 and 65535, got %d", c.DBConfig.Port)
+		}
+	}
+
+	if c.LogConfig != nil {
+		levels := []string{"", "debug", "info", "warn", "error"}
+		check(slices.Contains(levels, strings.ToLower(c.LogConfig.Level)), "log.level", "must be one of debug, info, warn, error, got %q", c.LogConfig.Level)
+		formats := []string{"", "text", "json"}
+		check(slices.Contains(formats, strings.ToLower(c.LogConfig.Format)), "log.format", "must be one of text, json, got %q", c.LogConfig.Format)
+	}
+
+	if c.MetricsConfig != nil && c.MetricsConfig.Addr != "" {
+		check(strings.HasPrefix(c.MetricsConfig.Path, "/"), "metrics.path", "must start with /, got %q", c.MetricsConfig.Path)
+	}
+
+	if c.SpoolConfig != nil {
+		check(c.SpoolConfig.MaxSize > 0, "spool.max_size", "must be positive")
+		check(c.SpoolConfig.BatchSize > 0, "spool.batch_size", "must be positive")
+		checkDuration(c.SpoolConfig.ReplayInterval, "spool.replay_interval")
+	}
+
+	if c.HealthConfig != nil {
+		checkDuration(c.HealthConfig.Interval, "health.interval")
+		checkDuration(c.HealthConfig.Timeout, "health.timeout")
+		check(c.HealthConfig.MaxBacklog >= 0, "health.max_backlog", "must not be negative")
+	}
+
+	if c.RetentionConfig != nil {
+		checkDuration(c.RetentionConfig.Interval, "retention.interval")
+		checkDuration(c.RetentionConfig.MaxAge, "retention.max_age")
+		for i, t := range c.RetentionConfig.Tenants {
+			path := fmt.Sprintf("retention.tenants[%d]", i)
+			check(t.Tenant != "", path+".tenant", "is required")
+			checkDuration(t.MaxAge, path+".max_age")
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay - quiet period after the last change, editors and config map
+// updates touch the file several times
+const watchDelay = 200 * time.Millisecond
+
+// Watch calls onChange when the config file changes until ctx is done. The
+// parent directory is watched, so files replaced by editors and Kubernetes
+// config map updates (swap of the ..data symlink) are detected too.
+func Watch(ctx context.Context, configPath string, onChange func()) error {
+	path, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return err
+	}
+
+	go func() {
+		defer w.Close()
+
+		var changed <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				if name != path && !strings.HasPrefix(filepath.Base(name), "..") {
+					continue
+				}
+				changed = time.After(watchDelay)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("failed to watch config; err: %v", err)
+			case <-changed:
+				changed = nil
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SchemaVersion - version of the latest migration the server relies on
+var SchemaVersion = latestVersion()
+
+func latestVersion() int64 {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var latest int64
+	for _, name := range names {
+		version, err := goose.NumericComponent(path.Base(name))
+		if err != nil {
+			panic(fmt.Sprintf("invalid migration %s: %v", name, err))
+		}
+		latest = max(latest, version)
+	}
+	return latest
+}
+
+// Migrator applies migrations embedded into the binary. Migrations are
+// applied under a Postgres advisory lock, so replicas starting together
+// do not race.
+type Migrator struct {
+	p *goose.Provider
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %v", err)
+	}
+
+	p, err := goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %v", err)
+	}
+	return &Migrator{p: p}, nil
+}
+
+// Up applies pending migrations.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	results, err := m.p.Up(ctx)
+	if err != nil {
+		return results, fmt.Errorf("failed to apply migrations: %v", err)
+	}
+	return results, nil
+}
+
+// Down rolls back the last applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	result, err := m.p.Down(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to roll back migration: %v", err)
+	}
+	return result, nil
+}
+
+// Status returns state of every migration.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	statuses, err := m.p.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %v", err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,30 @@
+package database_test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/database"
+)
+
+func TestMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// embedded migrations are parsed without touching the database
+	_, err = database.NewMigrator(db)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	entries, err := os.ReadDir("migrations")
+	require.NoError(t, err)
+	latest := entries[len(entries)-1].Name()
+	assert.Equal(t, latest[:strings.Index(latest, "_")], strconv.FormatInt(database.SchemaVersion, 10))
+}
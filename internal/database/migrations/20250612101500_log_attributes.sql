@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE logs
+    ALTER COLUMN message TYPE TEXT,
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE logs
+    DROP COLUMN IF EXISTS attributes,
+    ALTER COLUMN message TYPE VARCHAR(255);
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS role VARCHAR(64) NOT NULL DEFAULT 'readwrite';
+UPDATE api_keys SET role = 'admin' WHERE admin;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS admin;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS admin BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE api_keys SET admin = TRUE WHERE role = 'admin';
+ALTER TABLE api_keys DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
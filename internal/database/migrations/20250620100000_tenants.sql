@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS logs_tenant_source_idx ON logs (tenant_id, source, lvl, created_at);
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_name_key;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_tenant_name_key UNIQUE (tenant_id, name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_tenant_name_key;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS logs_tenant_source_idx;
+ALTER TABLE logs DROP COLUMN IF EXISTS tenant_id;
+-- +goose StatementEnd
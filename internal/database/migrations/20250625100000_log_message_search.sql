@@ -0,0 +1,7 @@
+-- +goose Up
+-- +goose NO TRANSACTION
+CREATE INDEX CONCURRENTLY IF NOT EXISTS logs_message_search_idx ON logs USING GIN (to_tsvector('simple', regexp_replace(lower(message), '[^[:alnum:]]+', ' ', 'g')));
+
+-- +goose Down
+-- +goose NO TRANSACTION
+DROP INDEX CONCURRENTLY IF EXISTS logs_message_search_idx;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose NO TRANSACTION
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(128);
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS logs_idempotency_key_idx ON logs (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- +goose Down
+-- +goose NO TRANSACTION
+DROP INDEX CONCURRENTLY IF EXISTS logs_idempotency_key_idx;
+ALTER TABLE logs DROP COLUMN IF EXISTS idempotency_key;
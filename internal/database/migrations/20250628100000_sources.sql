@@ -0,0 +1,86 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sources (
+    tenant_id VARCHAR(64) NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    lvl SMALLINT NOT NULL,
+    first_seen BIGINT NOT NULL,
+    last_seen BIGINT NOT NULL,
+    logs BIGINT NOT NULL,
+    PRIMARY KEY (tenant_id, source, lvl)
+);
+-- +goose StatementEnd
+
+-- rows updated by ON CONFLICT of a saved idempotency key are not in the
+-- inserted rows, so they are not counted twice
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION sources_insert() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sources (tenant_id, source, lvl, first_seen, last_seen, logs)
+    SELECT tenant_id, source, lvl, min(created_at), max(created_at), count(*)
+    FROM inserted
+    GROUP BY tenant_id, source, lvl
+    ORDER BY tenant_id, source, lvl
+    ON CONFLICT (tenant_id, source, lvl) DO UPDATE SET
+        first_seen = LEAST(sources.first_seen, EXCLUDED.first_seen),
+        last_seen = GREATEST(sources.last_seen, EXCLUDED.last_seen),
+        logs = sources.logs + EXCLUDED.logs;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- first seen time of sources having deleted logs is read from
+-- logs_tenant_source_idx, one index lookup per source and level
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION sources_delete() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE sources SET
+        first_seen = COALESCE((
+            SELECT min(l.created_at) FROM logs l
+            WHERE l.tenant_id = sources.tenant_id AND l.source = sources.source AND l.lvl = sources.lvl
+        ), sources.first_seen),
+        logs = sources.logs - d.logs
+    FROM (
+        SELECT tenant_id, source, lvl, count(*) AS logs
+        FROM deleted
+        GROUP BY tenant_id, source, lvl
+    ) d
+    WHERE sources.tenant_id = d.tenant_id AND sources.source = d.source AND sources.lvl = d.lvl;
+
+    DELETE FROM sources WHERE logs <= 0;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS logs_sources_insert ON logs;
+CREATE TRIGGER logs_sources_insert AFTER INSERT ON logs
+    REFERENCING NEW TABLE AS inserted
+    FOR EACH STATEMENT EXECUTE FUNCTION sources_insert();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS logs_sources_delete ON logs;
+CREATE TRIGGER logs_sources_delete AFTER DELETE ON logs
+    REFERENCING OLD TABLE AS deleted
+    FOR EACH STATEMENT EXECUTE FUNCTION sources_delete();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO sources (tenant_id, source, lvl, first_seen, last_seen, logs)
+SELECT tenant_id, source, lvl, min(created_at), max(created_at), count(*)
+FROM logs
+GROUP BY tenant_id, source, lvl
+ON CONFLICT (tenant_id, source, lvl) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS logs_sources_delete ON logs;
+DROP TRIGGER IF EXISTS logs_sources_insert ON logs;
+DROP FUNCTION IF EXISTS sources_delete();
+DROP FUNCTION IF EXISTS sources_insert();
+DROP TABLE IF EXISTS sources;
+-- +goose StatementEnd
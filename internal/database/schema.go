@@ -0,0 +1,18 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AppliedVersion returns version of the last migration applied by goose.
+func AppliedVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %v", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"logstream/internal/config"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+// Checker periodically checks readiness of the server and reports it
+// through the grpc.health.v1 service.
+type Checker struct {
+	storage    func(ctx context.Context) error
+	server     *health.Server
+	services   []string
+	interval   time.Duration
+	timeout    time.Duration
+	maxBacklog int
+	backlog    func() int
+
+	// err - result of the last check
+	err error
+}
+
+// New creates checker reporting status of services, "" being the whole
+// server. Services are not serving until the first check. storage returns
+// reason the storage is not ready, backlog returns number of logs waiting
+// for the storage.
+func New(storage func(ctx context.Context) error, server *health.Server, cfg *config.HealthConfig, backlog func() int, services ...string) *Checker {
+	c := &Checker{
+		storage:  storage,
+		server:   server,
+		services: services,
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		backlog:  backlog,
+	}
+	if cfg != nil {
+		if cfg.Interval > 0 {
+			c.interval = cfg.Interval
+		}
+		if cfg.Timeout > 0 {
+			c.timeout = cfg.Timeout
+		}
+		c.maxBacklog = cfg.MaxBacklog
+	}
+
+	for _, service := range services {
+		server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return c
+}
+
+// Run checks readiness every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Update(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update checks readiness once and reports it.
+func (c *Checker) Update(ctx context.Context) {
+	err := c.Check(ctx)
+
+	st := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, st)
+	}
+
+	switch {
+	case err != nil && (c.err == nil || err.Error() != c.err.Error()):
+		slog.Warn("server is not ready", slog.Any("reason", err))
+	case err == nil && c.err != nil:
+		slog.Info("server is ready")
+	}
+	c.err = err
+}
+
+// Check returns reason the server is not ready, nil if it is.
+func (c *Checker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := c.storage(ctx); err != nil {
+		return err
+	}
+
+	if c.maxBacklog > 0 && c.backlog != nil {
+		if n := c.backlog(); n > c.maxBacklog {
+			return fmt.Errorf("backlog of %d logs exceeds %d", n, c.maxBacklog)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"logstream/internal/config"
+	"logstream/internal/database"
+	"logstream/internal/health"
+	"logstream/internal/storage"
+)
+
+const versionQuery = `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied`
+
+func TestChecker(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockSetup      func(mock sqlmock.Sqlmock)
+		backlog        int
+		expectedStatus healthpb.HealthCheckResponse_ServingStatus
+		expectedErr    string
+	}{
+		{
+			name: "ready",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(database.SchemaVersion))
+			},
+			expectedStatus: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "database is unavailable",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+			},
+			expectedStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+			expectedErr:    "database is unavailable",
+		},
+		{
+			name: "migrations are not applied",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(20250605073557))
+			},
+			expectedStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+			expectedErr:    "migrations are not applied",
+		},
+		{
+			name: "backlog exceeded",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(database.SchemaVersion))
+			},
+			backlog:        11,
+			expectedStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+			expectedErr:    "backlog of 11 logs exceeds 10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			require.NoError(t, err)
+			defer db.Close()
+			tc.mockSetup(mock)
+
+			server := grpchealth.NewServer()
+			c := health.New(storage.NewPostgres(db).Check, server, &config.HealthConfig{MaxBacklog: 10},
+				func() int { return tc.backlog }, "", "logstream.LogsService")
+
+			ctx := context.Background()
+			c.Update(ctx)
+
+			for _, service := range []string{"", "logstream.LogsService"} {
+				resp, err := server.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedStatus, resp.GetStatus(), service)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			if tc.expectedErr != "" {
+				// Check repeats the checks
+				tc.mockSetup(mock)
+				assert.ErrorContains(t, c.Check(ctx), tc.expectedErr)
+			}
+		})
+	}
+}
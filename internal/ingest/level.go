@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+// Default level patterns, checked from most to least severe.
+var (
+	DefaultErrorPattern = `(?i)\b(error|err|fatal|panic|crit(ical)?|exception|traceback)\b`
+	DefaultWarnPattern  = `(?i)\b(warn(ing)?)\b`
+)
+
+// LevelRule assigns level to lines matching pattern.
+type LevelRule struct {
+	Level   pb.Level
+	Pattern *regexp.Regexp
+}
+
+// LevelDetector detects log level from message text.
+type LevelDetector struct {
+	rules []LevelRule
+	def   pb.Level
+}
+
+// NewLevelDetector creates detector with rules checked in order.
+// Lines matching no rule get default level.
+func NewLevelDetector(def pb.Level, rules ...LevelRule) *LevelDetector {
+	return &LevelDetector{
+		rules: rules,
+		def:   def,
+	}
+}
+
+// DefaultLevelDetector creates detector with default error and warn patterns.
+func DefaultLevelDetector() *LevelDetector {
+	return NewLevelDetector(pb.Level_LEVEL_INFO,
+		LevelRule{Level: pb.Level_LEVEL_ERROR, Pattern: regexp.MustCompile(DefaultErrorPattern)},
+		LevelRule{Level: pb.Level_LEVEL_WARN, Pattern: regexp.MustCompile(DefaultWarnPattern)},
+	)
+}
+
+// ParseLevelRule parses rule in form "level=regexp", e.g. "error=^E\d+".
+func ParseLevelRule(s string) (LevelRule, error) {
+	name, pattern, ok := strings.Cut(s, "=")
+	if !ok {
+		return LevelRule{}, fmt.Errorf("invalid level rule %q: expected level=regexp", s)
+	}
+
+	level, err := loglevel.Parse(name)
+	if err != nil {
+		return LevelRule{}, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return LevelRule{}, fmt.Errorf("invalid level rule %q: %v", s, err)
+	}
+
+	return LevelRule{Level: level, Pattern: re}, nil
+}
+
+// Rules returns detector rules.
+func (d *LevelDetector) Rules() []LevelRule {
+	return d.rules
+}
+
+// Detect returns level of the first matching rule.
+func (d *LevelDetector) Detect(message string) pb.Level {
+	for _, rule := range d.rules {
+		if rule.Pattern.MatchString(message) {
+			return rule.Level
+		}
+	}
+	return d.def
+}
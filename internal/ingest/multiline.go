@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultContinuationPattern matches typical stack trace continuation lines:
+// indented frames, "Caused by:" chains and Go goroutine dumps.
+var DefaultContinuationPattern = `^(\s+|Caused by:|\.\.\. \d+ more|goroutine \d+ \[|created by )`
+
+// Joiner joins continuation lines (e.g. stack traces) with the line that
+// started the record.
+type Joiner struct {
+	continuation *regexp.Regexp
+	maxLines     int
+
+	lines []string
+}
+
+// NewJoiner creates joiner. Nil continuation pattern disables joining.
+// Records are flushed after maxLines lines; zero means no limit.
+func NewJoiner(continuation *regexp.Regexp, maxLines int) *Joiner {
+	return &Joiner{
+		continuation: continuation,
+		maxLines:     maxLines,
+	}
+}
+
+// Add adds line and returns previous record when line starts a new one.
+func (j *Joiner) Add(line string) (string, bool) {
+	if j.continuation == nil {
+		return line, true
+	}
+
+	if len(j.lines) > 0 && j.continuation.MatchString(line) {
+		j.lines = append(j.lines, line)
+		if j.maxLines > 0 && len(j.lines) >= j.maxLines {
+			return j.Flush()
+		}
+		return "", false
+	}
+
+	record, ok := j.Flush()
+	j.lines = append(j.lines, line)
+	return record, ok
+}
+
+// Pending reports whether joiner holds unflushed lines.
+func (j *Joiner) Pending() bool {
+	return len(j.lines) > 0
+}
+
+// Flush returns buffered record.
+func (j *Joiner) Flush() (string, bool) {
+	if len(j.lines) == 0 {
+		return "", false
+	}
+	record := strings.Join(j.lines, "\n")
+	j.lines = j.lines[:0]
+	return record, true
+}
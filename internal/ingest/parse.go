@@ -0,0 +1,227 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+// Line formats supported by LineParser.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+	FormatAuto   = "auto"
+)
+
+// Well-known field names used to fill log fields from structured lines.
+var (
+	messageKeys = []string{"msg", "message", "log"}
+	levelKeys   = []string{"level", "lvl", "severity"}
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp"}
+)
+
+// LineParser converts raw records into logs.
+type LineParser struct {
+	Source string
+	Format string
+	Levels *LevelDetector
+}
+
+// Parse converts record into log. Structured records (JSON, logfmt) are
+// parsed into attributes; well-known fields become message, level and time.
+// Records which fail to parse are kept as plain text.
+func (p *LineParser) Parse(record string, now time.Time) *pb.Log {
+	log := &pb.Log{
+		Source:    p.Source,
+		Message:   record,
+		Timestamp: now.Unix(),
+	}
+
+	fields, ok := p.parseFields(record)
+	if !ok {
+		log.Level = p.detect(record)
+		return log
+	}
+
+	if message, ok := popField(fields, messageKeys); ok {
+		log.Message = message
+	}
+
+	log.Level = p.detect(log.Message)
+	if name, ok := popField(fields, levelKeys); ok {
+		if level, err := loglevel.Parse(name); err == nil {
+			log.Level = level
+		} else {
+			fields["level"] = name
+		}
+	}
+
+	if ts, ok := popField(fields, timeKeys); ok {
+		if t, err := parseTimestamp(ts); err == nil {
+			log.Timestamp = t.Unix()
+		} else {
+			fields["time"] = ts
+		}
+	}
+
+	if len(fields) > 0 {
+		log.Attributes = fields
+	}
+	if log.Message == "" {
+		log.Message = record
+	}
+
+	return log
+}
+
+func (p *LineParser) detect(message string) pb.Level {
+	if p.Levels == nil {
+		return pb.Level_LEVEL_INFO
+	}
+	return p.Levels.Detect(message)
+}
+
+func (p *LineParser) parseFields(record string) (map[string]string, bool) {
+	switch p.Format {
+	case FormatJSON:
+		return ParseJSON(record)
+	case FormatLogfmt:
+		return ParseLogfmt(record)
+	case FormatAuto:
+		if fields, ok := ParseJSON(record); ok {
+			return fields, true
+		}
+		return ParseLogfmt(record)
+	default:
+		return nil, false
+	}
+}
+
+// ParseJSON parses JSON object into flat fields. Nested values are kept
+// as JSON strings.
+func ParseJSON(record string) (map[string]string, bool) {
+	record = strings.TrimSpace(record)
+	if !strings.HasPrefix(record, "{") {
+		return nil, false
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(record), &obj); err != nil {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(obj))
+	for key, value := range obj {
+		switch v := value.(type) {
+		case nil:
+			fields[key] = ""
+		case string:
+			fields[key] = v
+		case float64:
+			fields[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			fields[key] = strconv.FormatBool(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, false
+			}
+			fields[key] = string(data)
+		}
+	}
+	return fields, true
+}
+
+// ParseLogfmt parses logfmt record, e.g. `level=info msg="user created" id=42`.
+// Record must consist of key=value pairs only.
+func ParseLogfmt(record string) (map[string]string, bool) {
+	fields := make(map[string]string)
+
+	s := strings.TrimSpace(record)
+	for len(s) > 0 {
+		eq := strings.IndexAny(s, "= ")
+		if eq <= 0 || s[eq] != '=' {
+			return nil, false
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := closingQuote(s)
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			s = s[end+1:]
+			if len(s) > 0 && s[0] != ' ' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+
+		fields[key] = value
+		s = strings.TrimLeft(s, " ")
+	}
+
+	if len(fields) == 0 {
+		return nil, false
+	}
+	return fields, true
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func popField(fields map[string]string, keys []string) (string, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			delete(fields, key)
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		// values above year 33658 in seconds are millis
+		if f > 1e12 {
+			return time.UnixMilli(int64(f)), nil
+		}
+		return time.Unix(int64(f), 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
@@ -0,0 +1,122 @@
+package ingest_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"logstream/internal/ingest"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestLineParser(t *testing.T) {
+	now := time.Unix(1749108957, 0)
+
+	testCases := []struct {
+		name        string
+		format      string
+		record      string
+		expectedLog *pb.Log
+	}{
+		{
+			name:   "text with detected level",
+			format: ingest.FormatText,
+			record: "ERROR failed to connect",
+			expectedLog: &pb.Log{
+				Source:    "app",
+				Level:     pb.Level_LEVEL_ERROR,
+				Message:   "ERROR failed to connect",
+				Timestamp: now.Unix(),
+			},
+		},
+		{
+			name:   "json",
+			format: ingest.FormatJSON,
+			record: `{"level":"warn","msg":"slow query","ms":1500,"ts":"2025-06-01T10:00:00Z","tags":["db"]}`,
+			expectedLog: &pb.Log{
+				Source:     "app",
+				Level:      pb.Level_LEVEL_WARN,
+				Message:    "slow query",
+				Timestamp:  time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC).Unix(),
+				Attributes: map[string]string{"ms": "1500", "tags": `["db"]`},
+			},
+		},
+		{
+			name:   "logfmt",
+			format: ingest.FormatLogfmt,
+			record: `level=info msg="user created" user_id=42 note="a \"quoted\" word"`,
+			expectedLog: &pb.Log{
+				Source:     "app",
+				Level:      pb.Level_LEVEL_INFO,
+				Message:    "user created",
+				Timestamp:  now.Unix(),
+				Attributes: map[string]string{"user_id": "42", "note": `a "quoted" word`},
+			},
+		},
+		{
+			name:   "auto falls back to text",
+			format: ingest.FormatAuto,
+			record: "warning: disk is almost full",
+			expectedLog: &pb.Log{
+				Source:    "app",
+				Level:     pb.Level_LEVEL_WARN,
+				Message:   "warning: disk is almost full",
+				Timestamp: now.Unix(),
+			},
+		},
+		{
+			name:   "unknown level is kept as attribute",
+			format: ingest.FormatJSON,
+			record: `{"level":"debug","message":"cache miss"}`,
+			expectedLog: &pb.Log{
+				Source:     "app",
+				Level:      pb.Level_LEVEL_INFO,
+				Message:    "cache miss",
+				Timestamp:  now.Unix(),
+				Attributes: map[string]string{"level": "debug"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &ingest.LineParser{
+				Source: "app",
+				Format: tc.format,
+				Levels: ingest.DefaultLevelDetector(),
+			}
+
+			assert.Equal(t, tc.expectedLog, p.Parse(tc.record, now))
+		})
+	}
+}
+
+func TestJoiner(t *testing.T) {
+	j := ingest.NewJoiner(regexp.MustCompile(ingest.DefaultContinuationPattern), 0)
+
+	var records []string
+	for _, line := range []string{
+		"Exception in thread main java.lang.IllegalStateException: boom",
+		"\tat com.example.App.run(App.java:10)",
+		"Caused by: java.io.IOException: closed",
+		"\t... 3 more",
+		"next record",
+	} {
+		if record, ok := j.Add(line); ok {
+			records = append(records, record)
+		}
+	}
+	if record, ok := j.Flush(); ok {
+		records = append(records, record)
+	}
+
+	assert.Equal(t, []string{
+		"Exception in thread main java.lang.IllegalStateException: boom\n" +
+			"\tat com.example.App.run(App.java:10)\n" +
+			"Caused by: java.io.IOException: closed\n" +
+			"\t... 3 more",
+		"next record",
+	}, records)
+}
@@ -0,0 +1,281 @@
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "logstream/pkg/api/logstream"
+)
+
+// AckFunc is called with log id once server saved the log. Logs rejected
+// by the server as invalid or out of the key's source scope are
+// acknowledged with zero id.
+type AckFunc func(id int32)
+
+// ShipperOptions configures Shipper.
+type ShipperOptions struct {
+	// QueueSize - max number of logs waiting to be sent
+	QueueSize int
+
+	// MinBackoff, MaxBackoff - reconnect delay bounds
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnError - called on stream errors before reconnecting
+	OnError func(err error)
+
+	// OnReject - called when server rejected log as invalid or out of the
+	// key's source scope; log is dropped
+	OnReject func(log *pb.Log, err error)
+}
+
+type entry struct {
+	log *pb.Log
+	key string
+	ack AckFunc
+}
+
+// Shipper sends logs through SaveLogStream. Logs are acknowledged in order;
+// unacknowledged logs are resent after reconnect with the same idempotency
+// key, so the server does not save them twice.
+type Shipper struct {
+	client pb.LogsServiceClient
+	opts   ShipperOptions
+	queue  chan *entry
+	// id - random prefix of idempotency keys of the shipper
+	id  string
+	seq atomic.Uint64
+
+	mu      sync.Mutex
+	pending []*entry
+}
+
+// NewShipper creates shipper. Run must be called to start sending.
+func NewShipper(client pb.LogsServiceClient, opts ShipperOptions) *Shipper {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 200 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 30 * time.Second
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &Shipper{
+		client: client,
+		opts:   opts,
+		queue:  make(chan *entry, opts.QueueSize),
+		id:     hex.EncodeToString(id),
+	}
+}
+
+// Send queues log saved once per idempotency key. Empty key is replaced by
+// a key unique to the shipper, which does not survive restarts. It blocks
+// while the queue is full.
+func (s *Shipper) Send(ctx context.Context, log *pb.Log, key string, ack AckFunc) error {
+	if key == "" {
+		key = fmt.Sprintf("%s-%d", s.id, s.seq.Add(1))
+	}
+	select {
+	case s.queue <- &entry{log: log, key: key, ack: ack}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting logs. Run returns once queued logs are acknowledged.
+func (s *Shipper) Close() {
+	close(s.queue)
+}
+
+// Run sends queued logs until Close is called and every log is
+// acknowledged, or ctx is done.
+func (s *Shipper) Run(ctx context.Context) error {
+	backoff := s.opts.MinBackoff
+
+	for {
+		done, progress, err := s.runStream(ctx)
+		if done {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if s.opts.OnError != nil {
+			s.opts.OnError(err)
+		}
+
+		wait := backoff
+		if delay, ok := retryDelay(err); ok {
+			// server asked to slow down, e.g. rate limit exceeded
+			wait = min(max(delay, s.opts.MinBackoff), s.opts.MaxBackoff)
+		} else if progress || rejected(err) {
+			backoff = s.opts.MinBackoff
+			continue
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, s.opts.MaxBackoff)
+	}
+}
+
+// rejected reports whether err rejects the log being saved for good: it is
+// invalid or its source is out of scope of the key. Resending it would
+// block the logs after it.
+func rejected(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.PermissionDenied:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns delay from RetryInfo detail of err.
+func retryDelay(err error) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// Pending returns number of sent but unacknowledged logs.
+func (s *Shipper) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *Shipper) runStream(ctx context.Context) (done, progress bool, err error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.SaveLogStream(streamCtx)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to open stream: %v", err)
+	}
+
+	acked := 0
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- s.receive(stream, &acked)
+	}()
+
+	fail := func(err error) (bool, bool, error) {
+		cancel()
+		if rerr := <-recvErr; rerr != nil {
+			err = rerr
+		}
+		return false, acked > 0, err
+	}
+
+	s.mu.Lock()
+	resend := append([]*entry(nil), s.pending...)
+	s.mu.Unlock()
+	for _, e := range resend {
+		if err := stream.Send(&pb.SaveLogRequest{Log: e.log, IdempotencyKey: e.key}); err != nil {
+			return fail(err)
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-s.queue:
+			if !ok {
+				if err := stream.CloseSend(); err != nil {
+					return fail(err)
+				}
+				if err := <-recvErr; err != nil {
+					return false, acked > 0, err
+				}
+				return s.Pending() == 0, acked > 0, errors.New("stream closed with unacknowledged logs")
+			}
+
+			s.mu.Lock()
+			s.pending = append(s.pending, e)
+			s.mu.Unlock()
+
+			if err := stream.Send(&pb.SaveLogRequest{Log: e.log, IdempotencyKey: e.key}); err != nil {
+				return fail(err)
+			}
+		case err := <-recvErr:
+			if err == nil {
+				err = errors.New("stream closed by server")
+			}
+			return false, acked > 0, err
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		}
+	}
+}
+
+func (s *Shipper) receive(stream pb.LogsService_SaveLogStreamClient, acked *int) error {
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if rejected(err) {
+				s.reject(err)
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return errors.New("received response without pending log")
+		}
+		e := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+
+		*acked++
+		if e.ack != nil {
+			e.ack(resp.GetId())
+		}
+	}
+}
+
+// reject drops the first pending log: the server handles logs in order,
+// so it is the one that failed validation or the source check.
+func (s *Shipper) reject(err error) {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	s.mu.Unlock()
+
+	if s.opts.OnReject != nil {
+		s.opts.OnReject(e.log, err)
+	}
+	if e.ack != nil {
+		e.ack(0)
+	}
+}
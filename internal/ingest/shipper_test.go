@@ -0,0 +1,149 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"logstream/internal/ingest"
+	pb "logstream/pkg/api/logstream"
+)
+
+// flakyServer fails the first stream after failAfter logs, rejects logs
+// with "invalid" message and denies logs of "secret" source. Like the server, it saves a log once per
+// idempotency key.
+type flakyServer struct {
+	pb.UnimplementedLogsServiceServer
+
+	mu        sync.Mutex
+	failAfter int
+	nextID    int32
+	keys      map[string]int32
+	saved     []string
+}
+
+func (s *flakyServer) SaveLogStream(stream pb.LogsService_SaveLogStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.GetLog().GetMessage() == "invalid" {
+			return status.Error(codes.InvalidArgument, "invalid log")
+		}
+		if req.GetLog().GetSource() == "secret" {
+			return status.Error(codes.PermissionDenied, "source is out of scope")
+		}
+
+		s.mu.Lock()
+		if s.failAfter == 0 {
+			s.failAfter = -1
+			s.mu.Unlock()
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+		s.failAfter--
+		id, ok := s.keys[req.GetIdempotencyKey()]
+		if !ok {
+			s.nextID++
+			id = s.nextID
+			s.keys[req.GetIdempotencyKey()] = id
+			s.saved = append(s.saved, req.GetLog().GetMessage())
+		}
+		s.mu.Unlock()
+
+		if err := stream.Send(&pb.SaveLogResponse{Id: id}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestShipper(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := &flakyServer{failAfter: 2, keys: make(map[string]int32)}
+	s := grpc.NewServer()
+	pb.RegisterLogsServiceServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var (
+		mu       sync.Mutex
+		acked    []int32
+		rejected int
+	)
+	shipper := ingest.NewShipper(pb.NewLogsServiceClient(conn), ingest.ShipperOptions{
+		MinBackoff: time.Millisecond,
+		OnReject: func(log *pb.Log, err error) {
+			rejected++
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- shipper.Run(ctx)
+	}()
+
+	logs := []*pb.Log{
+		{Source: "app", Message: "one", Timestamp: 1},
+		{Source: "app", Message: "two", Timestamp: 1},
+		{Source: "app", Message: "three", Timestamp: 1},
+		{Source: "app", Message: "invalid", Timestamp: 1},
+		{Source: "secret", Message: "denied", Timestamp: 1},
+		{Source: "app", Message: "four", Timestamp: 1},
+	}
+	for _, log := range logs {
+		err := shipper.Send(ctx, log, "", func(id int32) {
+			mu.Lock()
+			acked = append(acked, id)
+			mu.Unlock()
+		})
+		require.NoError(t, err)
+	}
+	shipper.Close()
+
+	require.NoError(t, <-done)
+	assert.Equal(t, 2, rejected)
+	assert.Len(t, acked, 6)
+	assert.Equal(t, 2, countZero(acked))
+	// resent logs keep their keys
+	assert.Equal(t, []string{"one", "two", "three", "four"}, srv.saved)
+	assert.NotContains(t, srv.keys, "")
+	assert.Equal(t, 0, shipper.Pending())
+}
+
+func countZero(ids []int32) int {
+	n := 0
+	for _, id := range ids {
+		if id == 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey - metadata key of request id, sent back in response headers
+const RequestIDKey = "x-request-id"
+
+const requestIDKey = contextKey("request_id")
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDFromContext returns id of request, empty if it is not set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestID returns id from incoming metadata or a new one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDKey); len(values) > 0 && requestIDPattern.MatchString(values[0]) {
+			return values[0]
+		}
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withRequest assigns request id to ctx and returns ctx with request logger.
+func withRequest(ctx context.Context, l *slog.Logger, method string) (context.Context, *slog.Logger) {
+	id := requestID(ctx)
+	ctx = context.WithValue(ctx, requestIDKey, id)
+
+	l = l.With(slog.String("request_id", id))
+	ctx = WithLogger(ctx, l)
+
+	attrs := []any{slog.String("method", method)}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	return ctx, l.With(attrs...)
+}
+
+func logCall(l *slog.Logger, start time.Time, err error, attrs ...any) {
+	code := status.Code(err)
+	attrs = append(attrs,
+		slog.Duration("duration", time.Since(start)),
+		slog.String("code", code.String()),
+	)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled, codes.NotFound, codes.InvalidArgument, codes.AlreadyExists,
+		codes.Unauthenticated, codes.PermissionDenied, codes.ResourceExhausted:
+	default:
+		level = slog.LevelError
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	l.Log(context.Background(), level, "call finished", attrs...)
+}
+
+// UnaryServerInterceptor assigns request id and logs unary calls.
+func UnaryServerInterceptor(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, callLogger := withRequest(ctx, l, info.FullMethod)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, RequestIDFromContext(ctx)))
+
+		resp, err := handler(ctx, req)
+		logCall(callLogger, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor assigns request id and logs streaming calls with
+// number of received and sent messages.
+func StreamServerInterceptor(l *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, callLogger := withRequest(ss.Context(), l, info.FullMethod)
+		_ = ss.SetHeader(metadata.Pairs(RequestIDKey, RequestIDFromContext(ctx)))
+
+		stream := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, stream)
+		logCall(callLogger, start, err,
+			slog.Int64("received", stream.received.Load()),
+			slog.Int64("sent", stream.sent.Load()),
+		)
+		return err
+	}
+}
+
+// RecoveryUnaryServerInterceptor turns panics of handlers into
+// codes.Internal errors. It should be the last interceptor of the chain.
+func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor turns panics of streaming handlers into
+// codes.Internal errors.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, r any) error {
+	FromContext(ctx).Error("panic recovered",
+		slog.String("method", method),
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received atomic.Int64
+	sent     atomic.Int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(msg any) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.received.Add(1)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(msg any) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"logstream/internal/config"
+)
+
+type contextKey string
+
+const loggerKey = contextKey("logger")
+
+// level - level of loggers created by New, changed by SetLevel
+var level = new(slog.LevelVar)
+
+// New creates logger writing to w with level and format of cfg.
+func New(w io.Writer, cfg *config.LogConfig) (*slog.Logger, error) {
+	if cfg == nil {
+		cfg = &config.LogConfig{}
+	}
+
+	if err := SetLevel(cfg.Level); err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log.format: unknown format %q", cfg.Format)
+	}
+}
+
+// SetLevel changes level of loggers created by New, empty name means info.
+func SetLevel(name string) error {
+	var l slog.Level
+	if name != "" {
+		if err := l.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("log.level: unknown level %q", name)
+		}
+	}
+	level.Set(l)
+	return nil
+}
+
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns logger of request, default logger if it is not set.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,133 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/config"
+	"logstream/internal/logging"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         *config.LogConfig
+		expectedErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "json debug",
+			cfg:  &config.LogConfig{Level: "debug", Format: "json"},
+		},
+		{
+			name:        "unknown level",
+			cfg:         &config.LogConfig{Level: "verbose"},
+			expectedErr: "log.level: unknown level",
+		},
+		{
+			name:        "unknown format",
+			cfg:         &config.LogConfig{Format: "xml"},
+			expectedErr: "log.format: unknown format",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := logging.New(&bytes.Buffer{}, tc.cfg)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.NotNil(t, l)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	testCases := []struct {
+		name              string
+		md                metadata.MD
+		handler           grpc.UnaryHandler
+		expectedCode      codes.Code
+		expectedRequestID string
+	}{
+		{
+			name: "request id from metadata",
+			md:   metadata.Pairs(logging.RequestIDKey, "req-1"),
+			handler: func(ctx context.Context, req any) (any, error) {
+				return "ok", nil
+			},
+			expectedCode:      codes.OK,
+			expectedRequestID: "req-1",
+		},
+		{
+			name: "generated request id",
+			handler: func(ctx context.Context, req any) (any, error) {
+				return nil, status.Error(codes.NotFound, "record not found")
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "invalid request id is replaced",
+			md:   metadata.Pairs(logging.RequestIDKey, "bad id\n"),
+			handler: func(ctx context.Context, req any) (any, error) {
+				return "ok", nil
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "panic",
+			handler: func(ctx context.Context, req any) (any, error) {
+				panic("boom")
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := logging.New(&buf, &config.LogConfig{Format: "json"})
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/logstream.LogsService/GetLog"}
+
+			var requestID string
+			handler := func(ctx context.Context, req any) (any, error) {
+				requestID = logging.RequestIDFromContext(ctx)
+				return logging.RecoveryUnaryServerInterceptor()(ctx, req, info, tc.handler)
+			}
+			_, err = logging.UnaryServerInterceptor(l)(ctx, nil, info, handler)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+
+			assert.NotEmpty(t, requestID)
+			if tc.expectedRequestID != "" {
+				assert.Equal(t, tc.expectedRequestID, requestID)
+			}
+
+			// the last line is the call log
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(lines[len(lines)-1], &entry))
+			assert.Equal(t, requestID, entry["request_id"])
+			assert.Equal(t, info.FullMethod, entry["method"])
+			assert.Equal(t, tc.expectedCode.String(), entry["code"])
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Package loglevel parses and names log levels for clients and the server.
+package loglevel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "logstream/pkg/api/logstream"
+)
+
+// Parse parses level names ("info", "WARN", "level_error") and numbers.
+func Parse(s string) (pb.Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	if name == "WARNING" {
+		name = "WARN"
+	}
+	if !strings.HasPrefix(name, "LEVEL_") {
+		name = "LEVEL_" + name
+	}
+	if v, ok := pb.Level_value[name]; ok {
+		return pb.Level(v), nil
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		if _, ok := pb.Level_name[int32(n)]; ok {
+			return pb.Level(n), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid level %q: should be info, warn or error", s)
+}
+
+// Name returns short level name, e.g. "INFO".
+func Name(level pb.Level) string {
+	return strings.TrimPrefix(level.String(), "LEVEL_")
+}
@@ -0,0 +1,28 @@
+package loglevel_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/loglevel"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestParse(t *testing.T) {
+	for input, expected := range map[string]pb.Level{
+		"info":        pb.Level_LEVEL_INFO,
+		"WARN":        pb.Level_LEVEL_WARN,
+		"warning":     pb.Level_LEVEL_WARN,
+		"LEVEL_ERROR": pb.Level_LEVEL_ERROR,
+		"2":           pb.Level_LEVEL_ERROR,
+	} {
+		level, err := loglevel.Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, level, input)
+	}
+
+	_, err := loglevel.Parse("debug")
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor counts and times unary calls.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeCall(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts and times streaming calls, tracks open
+// streams and their messages.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		active := m.activeStreams.WithLabelValues(info.FullMethod)
+		active.Inc()
+		defer active.Dec()
+
+		start := time.Now()
+		err := handler(srv, &serverStream{
+			ServerStream: ss,
+			received:     m.streamMessages.WithLabelValues(info.FullMethod, "received"),
+			sent:         m.streamMessages.WithLabelValues(info.FullMethod, "sent"),
+		})
+		m.observeCall(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observeCall(method string, start time.Time, err error) {
+	m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+type counter interface {
+	Inc()
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	received counter
+	sent     counter
+}
+
+func (s *serverStream) RecvMsg(msg any) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.received.Inc()
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(msg any) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.sent.Inc()
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"logstream/internal/spool"
+)
+
+const namespace = "logstream"
+
+// Metrics - server metrics. Methods of nil Metrics do nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	activeStreams   *prometheus.GaugeVec
+	streamMessages  *prometheus.CounterVec
+
+	ingestedLogs  *prometheus.CounterVec
+	ingestedBytes *prometheus.CounterVec
+	droppedLogs   *prometheus.CounterVec
+	lastIngest    prometheus.Gauge
+	batchSize     *prometheus.HistogramVec
+
+	dbDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "Handled gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of gRPC calls by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+		}, []string{"method"}),
+		activeStreams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "active_streams",
+			Help:      "Open gRPC streams by method.",
+		}, []string{"method"}),
+		streamMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "stream_messages_total",
+			Help:      "Stream messages by method and direction (received, sent).",
+		}, []string{"method", "direction"}),
+
+		ingestedLogs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "logs_total",
+			Help:      "Saved logs by source and level.",
+		}, []string{"source", "level"}),
+		ingestedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "bytes_total",
+			Help:      "Bytes of source, message and attributes of saved logs by source and level.",
+		}, []string{"source", "level"}),
+		droppedLogs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "dropped_logs_total",
+			Help:      "Logs accepted but not saved by reason (pipeline, limit).",
+		}, []string{"reason"}),
+		lastIngest: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "last_log_timestamp_seconds",
+			Help:      "Unix time when the last log was saved.",
+		}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "batch_size",
+			Help:      "Logs saved per batch: per SaveLogStream call or AddLogs query.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"kind"}),
+
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of repo methods by method and result (ok, not_found, error).",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+		}, []string{"method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.activeStreams,
+		m.streamMessages,
+		m.ingestedLogs,
+		m.ingestedBytes,
+		m.droppedLogs,
+		m.lastIngest,
+		m.batchSize,
+		m.dbDuration,
+	)
+
+	return m
+}
+
+// RegisterDB exposes connection pool stats of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler returns /metrics handler.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns registry metrics are registered in.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// ObserveIngest accounts saved log.
+func (m *Metrics) ObserveIngest(source, level string, bytes int) {
+	if m == nil {
+		return
+	}
+	m.ingestedLogs.WithLabelValues(source, level).Inc()
+	m.ingestedBytes.WithLabelValues(source, level).Add(float64(bytes))
+	m.lastIngest.SetToCurrentTime()
+}
+
+// ObserveDrop accounts log dropped for reason.
+func (m *Metrics) ObserveDrop(reason string) {
+	if m == nil {
+		return
+	}
+	m.droppedLogs.WithLabelValues(reason).Inc()
+}
+
+// ObserveBatch accounts number of logs saved in one batch of kind.
+func (m *Metrics) ObserveBatch(kind string, size int) {
+	if m == nil {
+		return
+	}
+	m.batchSize.WithLabelValues(kind).Observe(float64(size))
+}
+
+func (m *Metrics) observeDB(method string, start time.Time, result string) {
+	m.dbDuration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+// RegisterRedactions exposes redaction counters returned by counts.
+func (m *Metrics) RegisterRedactions(counts func() map[string]uint64) {
+	m.registry.MustRegister(&redactionCollector{counts: counts})
+}
+
+var redactionsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "redact", "redactions_total"),
+	"Redacted values by rule.",
+	[]string{"rule"}, nil,
+)
+
+type redactionCollector struct {
+	counts func() map[string]uint64
+}
+
+// Describe implements prometheus.Collector
+func (c *redactionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redactionsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *redactionCollector) Collect(ch chan<- prometheus.Metric) {
+	for rule, count := range c.counts() {
+		ch <- prometheus.MustNewConstMetric(redactionsDesc, prometheus.CounterValue, float64(count), rule)
+	}
+}
+
+// RegisterSpool exposes usage of the spool returned by stats.
+func (m *Metrics) RegisterSpool(stats func() spool.Stats) {
+	m.registry.MustRegister(&spoolCollector{stats: stats})
+}
+
+var (
+	spoolLogsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "spool", "logs"),
+		"Spooled logs not yet saved to the storage.",
+		nil, nil,
+	)
+	spoolBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "spool", "bytes"),
+		"Size of spooled logs not yet saved to the storage.",
+		nil, nil,
+	)
+	spoolEventsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "spool", "logs_total"),
+		"Logs by spool event (spooled, replayed, rejected).",
+		[]string{"event"}, nil,
+	)
+)
+
+type spoolCollector struct {
+	stats func() spool.Stats
+}
+
+// Describe implements prometheus.Collector
+func (c *spoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spoolLogsDesc
+	ch <- spoolBytesDesc
+	ch <- spoolEventsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *spoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(spoolLogsDesc, prometheus.GaugeValue, float64(stats.Logs))
+	ch <- prometheus.MustNewConstMetric(spoolBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+	ch <- prometheus.MustNewConstMetric(spoolEventsDesc, prometheus.CounterValue, float64(stats.Spooled), "spooled")
+	ch <- prometheus.MustNewConstMetric(spoolEventsDesc, prometheus.CounterValue, float64(stats.Replayed), "replayed")
+	ch <- prometheus.MustNewConstMetric(spoolEventsDesc, prometheus.CounterValue, float64(stats.Rejected), "rejected")
+}
@@ -0,0 +1,72 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/metrics"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	testCases := []struct {
+		name         string
+		handlerErr   error
+		expectedCode string
+	}{
+		{
+			name:         "ok",
+			expectedCode: "OK",
+		},
+		{
+			name:         "not found",
+			handlerErr:   status.Error(codes.NotFound, "not found"),
+			expectedCode: "NotFound",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := metrics.New()
+			interceptor := m.UnaryServerInterceptor()
+			info := &grpc.UnaryServerInfo{FullMethod: "/logstream.LogsService/GetLog"}
+
+			_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+				return nil, tc.handlerErr
+			})
+			assert.Equal(t, tc.handlerErr, err)
+
+			expected := `
+# HELP logstream_grpc_requests_total Handled gRPC calls by method and status code.
+# TYPE logstream_grpc_requests_total counter
+logstream_grpc_requests_total{code="` + tc.expectedCode + `",method="/logstream.LogsService/GetLog"} 1
+`
+			require.NoError(t, testutil.CollectAndCompare(m.Registry(), strings.NewReader(expected), "logstream_grpc_requests_total"))
+		})
+	}
+}
+
+func TestObserveIngest(t *testing.T) {
+	m := metrics.New()
+	m.ObserveIngest("api", "info", 10)
+	m.ObserveIngest("api", "info", 5)
+	m.ObserveDrop("limit")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.Registry(), "logstream_ingest_logs_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.Registry(), "logstream_ingest_dropped_logs_total"))
+
+	// nil metrics are disabled
+	var disabled *metrics.Metrics
+	assert.NotPanics(t, func() {
+		disabled.ObserveIngest("api", "info", 10)
+		disabled.ObserveDrop("limit")
+		disabled.ObserveBatch("save_log_stream", 1)
+	})
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+)
+
+// InstrumentRepo returns repo timing every method.
+func (m *Metrics) InstrumentRepo(r repo.Repo) repo.Repo {
+	return &instrumentedRepo{
+		r: r,
+		m: m,
+	}
+}
+
+type instrumentedRepo struct {
+	r repo.Repo
+	m *Metrics
+}
+
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case database.IsRecordNotFoundError(err):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+func (r *instrumentedRepo) RunInTx(ctx context.Context, f func(ctx context.Context) error) (err error) {
+	defer func(start time.Time) { r.m.observeDB("RunInTx", start, result(err)) }(time.Now())
+	return r.r.RunInTx(ctx, f)
+}
+
+func (r *instrumentedRepo) GetLog(ctx context.Context, id int32) (log *repo.Log, err error) {
+	defer func(start time.Time) { r.m.observeDB("GetLog", start, result(err)) }(time.Now())
+	return r.r.GetLog(ctx, id)
+}
+
+func (r *instrumentedRepo) GetLogs(ctx context.Context, filter repo.Filter) (logs []*repo.Log, err error) {
+	defer func(start time.Time) { r.m.observeDB("GetLogs", start, result(err)) }(time.Now())
+	return r.r.GetLogs(ctx, filter)
+}
+
+func (r *instrumentedRepo) GetLogsPage(ctx context.Context, filter repo.Filter, page repo.Page) (logs []*repo.Log, err error) {
+	defer func(start time.Time) { r.m.observeDB("GetLogsPage", start, result(err)) }(time.Now())
+	return r.r.GetLogsPage(ctx, filter, page)
+}
+
+func (r *instrumentedRepo) AddLog(ctx context.Context, log *repo.Log) (id int32, err error) {
+	defer func(start time.Time) { r.m.observeDB("AddLog", start, result(err)) }(time.Now())
+	return r.r.AddLog(ctx, log)
+}
+
+func (r *instrumentedRepo) AddLogs(ctx context.Context, logs []*repo.Log) (ids []int32, err error) {
+	defer func(start time.Time) {
+		r.m.observeDB("AddLogs", start, result(err))
+		if err == nil {
+			r.m.ObserveBatch("add_logs", len(logs))
+		}
+	}(time.Now())
+	return r.r.AddLogs(ctx, logs)
+}
+
+func (r *instrumentedRepo) GetTenants(ctx context.Context) (tenants []string, err error) {
+	defer func(start time.Time) { r.m.observeDB("GetTenants", start, result(err)) }(time.Now())
+	return r.r.GetTenants(ctx)
+}
+
+func (r *instrumentedRepo) GetSources(ctx context.Context, prefix string) (sources []*repo.Source, err error) {
+	defer func(start time.Time) { r.m.observeDB("GetSources", start, result(err)) }(time.Now())
+	return r.r.GetSources(ctx, prefix)
+}
+
+func (r *instrumentedRepo) DeleteLogs(ctx context.Context, before int64) (n int64, err error) {
+	defer func(start time.Time) { r.m.observeDB("DeleteLogs", start, result(err)) }(time.Now())
+	return r.r.DeleteLogs(ctx, before)
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"logstream/internal/config"
+	"logstream/internal/loglevel"
+	"logstream/internal/repo"
+)
+
+// Condition matches logs. All configured checks must match.
+type Condition struct {
+	source     *regexp.Regexp
+	message    *regexp.Regexp
+	levels     map[int32]bool
+	attributes map[string]*regexp.Regexp
+}
+
+func NewCondition(cfg *config.ConditionConfig) (*Condition, error) {
+	c := &Condition{}
+
+	var err error
+	if cfg.Source != "" {
+		if c.source, err = regexp.Compile(cfg.Source); err != nil {
+			return nil, fmt.Errorf("invalid source pattern: %v", err)
+		}
+	}
+	if cfg.Message != "" {
+		if c.message, err = regexp.Compile(cfg.Message); err != nil {
+			return nil, fmt.Errorf("invalid message pattern: %v", err)
+		}
+	}
+	if len(cfg.Levels) > 0 {
+		c.levels = make(map[int32]bool, len(cfg.Levels))
+		for _, name := range cfg.Levels {
+			level, err := loglevel.Parse(name)
+			if err != nil {
+				return nil, err
+			}
+			c.levels[int32(level)] = true
+		}
+	}
+	if len(cfg.Attributes) > 0 {
+		c.attributes = make(map[string]*regexp.Regexp, len(cfg.Attributes))
+		for key, pattern := range cfg.Attributes {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid attribute %q pattern: %v", key, err)
+			}
+			c.attributes[key] = re
+		}
+	}
+
+	return c, nil
+}
+
+// Match reports whether log matches condition.
+func (c *Condition) Match(log *repo.Log) bool {
+	if c == nil {
+		return true
+	}
+	if c.source != nil && !c.source.MatchString(log.Source) {
+		return false
+	}
+	if c.message != nil && !c.message.MatchString(log.Message) {
+		return false
+	}
+	if c.levels != nil && !c.levels[log.Level] {
+		return false
+	}
+	for key, re := range c.attributes {
+		value, ok := log.Attributes[key]
+		if !ok || !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// When applies processor only to logs matching condition.
+func When(cond *Condition, processor Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+		if !cond.Match(log) {
+			return log, nil
+		}
+		return processor.Process(ctx, log)
+	})
+}
+
+// Route - sub-pipeline applied to logs matching condition
+type Route struct {
+	// When - nil matches every log
+	When     *Condition
+	Drop     bool
+	Pipeline *Pipeline
+}
+
+// Router applies the first matching route. Logs matching no route pass
+// unchanged.
+type Router struct {
+	routes []Route
+}
+
+func NewRouter(routes ...Route) *Router {
+	return &Router{
+		routes: routes,
+	}
+}
+
+// Process implements Processor
+func (r *Router) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	for _, route := range r.routes {
+		if !route.When.Match(log) {
+			continue
+		}
+		if route.Drop {
+			return nil, nil
+		}
+		return route.Pipeline.Process(ctx, log)
+	}
+	return log, nil
+}
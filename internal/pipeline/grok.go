@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// basePatterns - commonly used grok patterns
+var basePatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"EMAILADDRESS":      `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"USER":              `[A-Za-z0-9._-]+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"YEAR":              `\d{4}`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0?[1-9]|[12]\d|3[01])`,
+	"HOUR":              `(?:[01]?\d|2[0-3])`,
+	"MINUTE":            `[0-5]\d`,
+	"SECOND":            `(?:[0-5]?\d|60)(?:[.,]\d+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"MONTH":             `\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)[a-z]*\b`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+}
+
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::([\w.\-]+))?\}`)
+
+// Grok compiles grok expressions such as "%{IP:client} %{WORD:method}"
+// into regular expressions with named groups.
+type Grok struct {
+	patterns map[string]string
+}
+
+// NewGrok creates grok with base patterns extended by custom ones.
+func NewGrok(custom map[string]string) *Grok {
+	patterns := make(map[string]string, len(basePatterns)+len(custom))
+	for name, pattern := range basePatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range custom {
+		patterns[name] = pattern
+	}
+	return &Grok{
+		patterns: patterns,
+	}
+}
+
+// Compile compiles grok expression.
+func (g *Grok) Compile(expr string) (*regexp.Regexp, error) {
+	pattern, err := g.expand(expr, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok expression %q: %v", expr, err)
+	}
+	return re, nil
+}
+
+func (g *Grok) expand(expr string, depth int) (string, error) {
+	if depth > 16 {
+		return "", fmt.Errorf("grok patterns are nested too deep")
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, m := range grokRef.FindAllStringSubmatchIndex(expr, -1) {
+		b.WriteString(expr[last:m[0]])
+		last = m[1]
+
+		name := expr[m[2]:m[3]]
+		pattern, ok := g.patterns[name]
+		if !ok {
+			return "", fmt.Errorf("unknown grok pattern %q", name)
+		}
+		expanded, err := g.expand(pattern, depth+1)
+		if err != nil {
+			return "", err
+		}
+
+		if m[4] >= 0 {
+			field := strings.NewReplacer(".", "_", "-", "_").Replace(expr[m[4]:m[5]])
+			fmt.Fprintf(&b, "(?P<%s>%s)", field, expanded)
+		} else {
+			fmt.Fprintf(&b, "(?:%s)", expanded)
+		}
+	}
+	b.WriteString(expr[last:])
+
+	return b.String(), nil
+}
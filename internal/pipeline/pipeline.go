@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"logstream/internal/config"
+	"logstream/internal/repo"
+)
+
+// Processor transforms log before it is saved. Returning nil log drops it.
+type Processor interface {
+	// Process - process log
+	Process(ctx context.Context, log *repo.Log) (*repo.Log, error)
+}
+
+// ProcessorFunc adapts function to Processor.
+type ProcessorFunc func(ctx context.Context, log *repo.Log) (*repo.Log, error)
+
+// Process implements Processor
+func (f ProcessorFunc) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	return f(ctx, log)
+}
+
+// Pipeline runs processors in order.
+type Pipeline struct {
+	processors []Processor
+}
+
+func New(processors ...Processor) *Pipeline {
+	return &Pipeline{
+		processors: processors,
+	}
+}
+
+// FromConfig builds pipeline from config. Nil config gives empty pipeline.
+func FromConfig(cfg *config.PipelineConfig) (*Pipeline, error) {
+	if cfg == nil {
+		return New(), nil
+	}
+
+	b := &builder{grok: NewGrok(cfg.Patterns)}
+	processors, err := b.processors(cfg.Processors, "pipeline.processors")
+	if err != nil {
+		return nil, err
+	}
+	return New(processors...), nil
+}
+
+// Process implements Processor
+func (p *Pipeline) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	for _, processor := range p.processors {
+		var err error
+		log, err = processor.Process(ctx, log)
+		if err != nil {
+			return nil, err
+		}
+		if log == nil {
+			return nil, nil
+		}
+	}
+	return log, nil
+}
+
+// Len returns number of processors.
+func (p *Pipeline) Len() int {
+	return len(p.processors)
+}
+
+type builder struct {
+	grok *Grok
+}
+
+func (b *builder) processors(cfgs []*config.ProcessorConfig, path string) ([]Processor, error) {
+	processors := make([]Processor, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		processor, err := b.processor(cfg, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+func (b *builder) processor(cfg *config.ProcessorConfig, path string) (Processor, error) {
+	var (
+		processor Processor
+		err       error
+	)
+
+	switch cfg.Type {
+	case "regex":
+		processor, err = NewRegexExtractor(cfg.Field, cfg.Pattern, cfg.Overwrite)
+	case "grok":
+		processor, err = NewGrokExtractor(b.grok, cfg.Field, cfg.Pattern, cfg.Overwrite)
+	case "json":
+		processor = NewJSONParser(cfg.Field, cfg.Overwrite)
+	case "level":
+		processor, err = NewLevelRemapper(cfg.Field, cfg.Mapping)
+	case "source":
+		processor, err = NewSourceRenamer(cfg.Mapping, cfg.Pattern, cfg.Replacement)
+	case "attributes":
+		processor = NewAttributesEditor(cfg.Add, cfg.Drop)
+	case "drop":
+		processor = ProcessorFunc(func(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+			return nil, nil
+		})
+	case "route":
+		processor, err = b.router(cfg.Routes, path)
+	default:
+		return nil, fmt.Errorf("%s: unknown processor type %q", path, cfg.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s): %v", path, cfg.Type, err)
+	}
+
+	if cfg.When != nil {
+		cond, err := NewCondition(cfg.When)
+		if err != nil {
+			return nil, fmt.Errorf("%s.when: %v", path, err)
+		}
+		processor = When(cond, processor)
+	}
+
+	return processor, nil
+}
+
+func (b *builder) router(cfgs []*config.RouteConfig, path string) (Processor, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("routes are empty")
+	}
+
+	routes := make([]Route, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		routePath := fmt.Sprintf("%s.routes[%d]", path, i)
+
+		var route Route
+		if cfg.When != nil {
+			cond, err := NewCondition(cfg.When)
+			if err != nil {
+				return nil, fmt.Errorf("%s.when: %v", routePath, err)
+			}
+			route.When = cond
+		}
+
+		if cfg.Drop {
+			route.Drop = true
+		} else {
+			processors, err := b.processors(cfg.Processors, routePath+".processors")
+			if err != nil {
+				return nil, err
+			}
+			route.Pipeline = New(processors...)
+		}
+
+		routes = append(routes, route)
+	}
+
+	return NewRouter(routes...), nil
+}
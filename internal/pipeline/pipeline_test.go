@@ -0,0 +1,244 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/config"
+	"logstream/internal/pipeline"
+	"logstream/internal/repo"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestFromConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         *config.PipelineConfig
+		log         *repo.Log
+		expectedLog *repo.Log
+		expectedErr string
+	}{
+		{
+			name:        "nil config",
+			cfg:         nil,
+			log:         &repo.Log{Source: "api", Message: "hello"},
+			expectedLog: &repo.Log{Source: "api", Message: "hello"},
+		},
+		{
+			name: "regex",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "regex", Pattern: `user=(?P<user>\w+)`},
+				},
+			},
+			log: &repo.Log{Source: "api", Message: "login user=bob"},
+			expectedLog: &repo.Log{
+				Source:     "api",
+				Message:    "login user=bob",
+				Attributes: repo.Attributes{"user": "bob"},
+			},
+		},
+		{
+			name: "grok with custom pattern",
+			cfg: &config.PipelineConfig{
+				Patterns: map[string]string{"REQID": `[a-f0-9]{4}`},
+				Processors: []*config.ProcessorConfig{
+					{Type: "grok", Pattern: `%{IP:client} %{WORD:http.method} %{URIPATHPARAM:path} %{REQID:req}`},
+				},
+			},
+			log: &repo.Log{Source: "nginx", Message: "10.0.0.1 GET /users?id=1 beef"},
+			expectedLog: &repo.Log{
+				Source:  "nginx",
+				Message: "10.0.0.1 GET /users?id=1 beef",
+				Attributes: repo.Attributes{
+					"client":      "10.0.0.1",
+					"http_method": "GET",
+					"path":        "/users?id=1",
+					"req":         "beef",
+				},
+			},
+		},
+		{
+			name: "json keeps existing attributes",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "json"},
+				},
+			},
+			log: &repo.Log{
+				Source:     "api",
+				Level:      int32(pb.Level_LEVEL_INFO),
+				Message:    `{"msg":"failed","level":"error","user":"bob","host":"b"}`,
+				Attributes: repo.Attributes{"host": "a"},
+			},
+			expectedLog: &repo.Log{
+				Source:     "api",
+				Level:      int32(pb.Level_LEVEL_ERROR),
+				Message:    "failed",
+				Attributes: repo.Attributes{"user": "bob", "host": "a"},
+			},
+		},
+		{
+			name: "level from attribute",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "level", Field: "severity", Mapping: map[string]string{"critical": "error"}},
+				},
+			},
+			log: &repo.Log{
+				Source:     "api",
+				Message:    "disk full",
+				Attributes: repo.Attributes{"severity": "CRITICAL"},
+			},
+			expectedLog: &repo.Log{
+				Source:     "api",
+				Level:      int32(pb.Level_LEVEL_ERROR),
+				Message:    "disk full",
+				Attributes: repo.Attributes{"severity": "CRITICAL"},
+			},
+		},
+		{
+			name: "source rename by pattern",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "source", Pattern: `^(\w+)-[a-z0-9]+-[a-z0-9]+$`, Replacement: "$1"},
+				},
+			},
+			log:         &repo.Log{Source: "api-7d9f-x2k", Message: "hello"},
+			expectedLog: &repo.Log{Source: "api", Message: "hello"},
+		},
+		{
+			name: "attributes add and drop",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "attributes", Add: map[string]string{"env": "prod"}, Drop: []string{"password"}},
+				},
+			},
+			log: &repo.Log{
+				Source:     "api",
+				Message:    "hello",
+				Attributes: repo.Attributes{"password": "secret"},
+			},
+			expectedLog: &repo.Log{
+				Source:     "api",
+				Message:    "hello",
+				Attributes: repo.Attributes{"env": "prod"},
+			},
+		},
+		{
+			name: "when not matched",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "drop", When: &config.ConditionConfig{Source: "^debug$"}},
+				},
+			},
+			log:         &repo.Log{Source: "api", Message: "hello"},
+			expectedLog: &repo.Log{Source: "api", Message: "hello"},
+		},
+		{
+			name: "when matched drops",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "drop", When: &config.ConditionConfig{Source: "^debug$"}},
+				},
+			},
+			log:         &repo.Log{Source: "debug", Message: "hello"},
+			expectedLog: nil,
+		},
+		{
+			name: "route first match wins",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "route", Routes: []*config.RouteConfig{
+						{
+							When: &config.ConditionConfig{Levels: []string{"error"}},
+							Processors: []*config.ProcessorConfig{
+								{Type: "attributes", Add: map[string]string{"alert": "true"}},
+							},
+						},
+						{
+							When: &config.ConditionConfig{Message: "^healthcheck"},
+							Drop: true,
+						},
+					}},
+				},
+			},
+			log: &repo.Log{Source: "api", Level: int32(pb.Level_LEVEL_ERROR), Message: "healthcheck failed"},
+			expectedLog: &repo.Log{
+				Source:     "api",
+				Level:      int32(pb.Level_LEVEL_ERROR),
+				Message:    "healthcheck failed",
+				Attributes: repo.Attributes{"alert": "true"},
+			},
+		},
+		{
+			name: "route drop",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "route", Routes: []*config.RouteConfig{
+						{When: &config.ConditionConfig{Message: "^healthcheck"}, Drop: true},
+					}},
+				},
+			},
+			log:         &repo.Log{Source: "api", Message: "healthcheck ok"},
+			expectedLog: nil,
+		},
+		{
+			name: "unknown processor",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "unknown"},
+				},
+			},
+			expectedErr: `pipeline.processors[0]: unknown processor type "unknown"`,
+		},
+		{
+			name: "unknown grok pattern",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "grok", Pattern: "%{NOPE:x}"},
+				},
+			},
+			expectedErr: `pipeline.processors[0] (grok): unknown grok pattern "NOPE"`,
+		},
+		{
+			name: "regex without named groups",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "regex", Pattern: `user=\w+`},
+				},
+			},
+			expectedErr: "pipeline.processors[0] (regex): pattern has no named groups",
+		},
+		{
+			name: "invalid route condition",
+			cfg: &config.PipelineConfig{
+				Processors: []*config.ProcessorConfig{
+					{Type: "route", Routes: []*config.RouteConfig{
+						{When: &config.ConditionConfig{Levels: []string{"loud"}}, Drop: true},
+					}},
+				},
+			},
+			expectedErr: "pipeline.processors[0].routes[0].when:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := pipeline.FromConfig(tc.cfg)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			log, err := p.Process(context.Background(), tc.log)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLog, log)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"logstream/internal/ingest"
+	"logstream/internal/loglevel"
+	"logstream/internal/repo"
+	pb "logstream/pkg/api/logstream"
+)
+
+const messageField = "message"
+
+func getField(log *repo.Log, field string) (string, bool) {
+	if field == "" || field == messageField {
+		return log.Message, true
+	}
+	value, ok := log.Attributes[field]
+	return value, ok
+}
+
+func setAttribute(log *repo.Log, key, value string, overwrite bool) {
+	if log.Attributes == nil {
+		log.Attributes = make(repo.Attributes)
+	}
+	if _, ok := log.Attributes[key]; ok && !overwrite {
+		return
+	}
+	log.Attributes[key] = value
+}
+
+// RegexExtractor extracts named groups of regexp into attributes.
+type RegexExtractor struct {
+	field     string
+	re        *regexp.Regexp
+	overwrite bool
+}
+
+func NewRegexExtractor(field, pattern string, overwrite bool) (*RegexExtractor, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return newRegexExtractor(field, re, overwrite)
+}
+
+func newRegexExtractor(field string, re *regexp.Regexp, overwrite bool) (*RegexExtractor, error) {
+	named := false
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			named = true
+		}
+	}
+	if !named {
+		return nil, fmt.Errorf("pattern has no named groups")
+	}
+
+	return &RegexExtractor{
+		field:     field,
+		re:        re,
+		overwrite: overwrite,
+	}, nil
+}
+
+// Process implements Processor
+func (p *RegexExtractor) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	value, ok := getField(log, p.field)
+	if !ok {
+		return log, nil
+	}
+
+	match := p.re.FindStringSubmatch(value)
+	if match == nil {
+		return log, nil
+	}
+	for i, name := range p.re.SubexpNames() {
+		if name == "" || i >= len(match) {
+			continue
+		}
+		setAttribute(log, name, match[i], p.overwrite)
+	}
+	return log, nil
+}
+
+// NewGrokExtractor creates extractor from grok expression, e.g.
+// "%{IP:client} %{WORD:method} %{URIPATHPARAM:path}".
+func NewGrokExtractor(grok *Grok, field, expr string, overwrite bool) (*RegexExtractor, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	re, err := grok.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return newRegexExtractor(field, re, overwrite)
+}
+
+// JSONParser parses JSON message (or attribute) into attributes. Well-known
+// fields "msg"/"message" and "level"/"severity" replace message and level.
+type JSONParser struct {
+	field     string
+	overwrite bool
+}
+
+func NewJSONParser(field string, overwrite bool) *JSONParser {
+	return &JSONParser{
+		field:     field,
+		overwrite: overwrite,
+	}
+}
+
+// Process implements Processor
+func (p *JSONParser) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	value, ok := getField(log, p.field)
+	if !ok {
+		return log, nil
+	}
+
+	fields, ok := ingest.ParseJSON(value)
+	if !ok {
+		return log, nil
+	}
+
+	for _, key := range []string{"msg", "message"} {
+		if message, ok := fields[key]; ok && message != "" {
+			log.Message = message
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range []string{"level", "lvl", "severity"} {
+		if name, ok := fields[key]; ok {
+			if level, err := loglevel.Parse(name); err == nil {
+				log.Level = int32(level)
+				delete(fields, key)
+			}
+			break
+		}
+	}
+	for key, value := range fields {
+		setAttribute(log, key, value, p.overwrite)
+	}
+	return log, nil
+}
+
+// LevelRemapper sets level from attribute or remaps current level.
+// Mapping keys are compared case-insensitively.
+type LevelRemapper struct {
+	field   string
+	mapping map[string]pb.Level
+}
+
+func NewLevelRemapper(field string, mapping map[string]string) (*LevelRemapper, error) {
+	if field == "" && len(mapping) == 0 {
+		return nil, fmt.Errorf("field or mapping is required")
+	}
+
+	p := &LevelRemapper{
+		field:   field,
+		mapping: make(map[string]pb.Level, len(mapping)),
+	}
+	for from, to := range mapping {
+		level, err := loglevel.Parse(to)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %q: %v", from, err)
+		}
+		p.mapping[strings.ToLower(from)] = level
+	}
+	return p, nil
+}
+
+// Process implements Processor
+func (p *LevelRemapper) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	var value string
+	if p.field == "" {
+		value = loglevel.Name(pb.Level(log.Level))
+	} else {
+		var ok bool
+		if value, ok = log.Attributes[p.field]; !ok {
+			return log, nil
+		}
+	}
+
+	if level, ok := p.mapping[strings.ToLower(value)]; ok {
+		log.Level = int32(level)
+	} else if level, err := loglevel.Parse(value); err == nil && p.field != "" {
+		log.Level = int32(level)
+	}
+	return log, nil
+}
+
+// SourceRenamer renames sources by exact mapping or by regexp replacement.
+type SourceRenamer struct {
+	mapping     map[string]string
+	re          *regexp.Regexp
+	replacement string
+}
+
+func NewSourceRenamer(mapping map[string]string, pattern, replacement string) (*SourceRenamer, error) {
+	if len(mapping) == 0 && pattern == "" {
+		return nil, fmt.Errorf("mapping or pattern is required")
+	}
+
+	p := &SourceRenamer{
+		mapping:     mapping,
+		replacement: replacement,
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		}
+		p.re = re
+	}
+	return p, nil
+}
+
+// Process implements Processor
+func (p *SourceRenamer) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	if source, ok := p.mapping[log.Source]; ok {
+		log.Source = source
+		return log, nil
+	}
+	if p.re != nil && p.re.MatchString(log.Source) {
+		if source := p.re.ReplaceAllString(log.Source, p.replacement); source != "" {
+			log.Source = source
+		}
+	}
+	return log, nil
+}
+
+// AttributesEditor adds and drops attributes.
+type AttributesEditor struct {
+	add  map[string]string
+	drop []string
+}
+
+func NewAttributesEditor(add map[string]string, drop []string) *AttributesEditor {
+	return &AttributesEditor{
+		add:  add,
+		drop: drop,
+	}
+}
+
+// Process implements Processor
+func (p *AttributesEditor) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	for _, key := range p.drop {
+		delete(log.Attributes, key)
+	}
+	for key, value := range p.add {
+		setAttribute(log, key, value, true)
+	}
+	if len(log.Attributes) == 0 {
+		log.Attributes = nil
+	}
+	return log, nil
+}
@@ -0,0 +1,315 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"logstream/internal/config"
+)
+
+// Over-limit modes
+const (
+	ModeReject = "reject"
+	ModeSample = "sample"
+)
+
+// Limit keys
+const (
+	KeyBySource = "source"
+	KeyByTenant = "tenant"
+)
+
+const (
+	defaultSampleEvery = 100
+
+	// sweepInterval - how often idle states are evicted
+	sweepInterval = time.Minute
+)
+
+// Limit - token bucket and daily quota of one key
+type Limit struct {
+	Rate       float64
+	Burst      int
+	DailyLogs  int64
+	DailyBytes int64
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 && l.DailyLogs <= 0 && l.DailyBytes <= 0
+}
+
+// Decision - result of Limiter.Allow
+type Decision struct {
+	// Allowed - log may be saved
+	Allowed bool
+	// Reject - log must be rejected, otherwise not allowed log is dropped
+	Reject bool
+	// RetryAfter - when the key is expected to be allowed again
+	RetryAfter time.Duration
+	// Reason - which limit was exceeded
+	Reason string
+}
+
+// overrideKey - tenant and key of override, empty tenant applies to the
+// source of every tenant
+type overrideKey struct {
+	tenant string
+	key    string
+}
+
+type state struct {
+	tokens float64
+	last   time.Time
+	// limit - the limit applied last, to tell if the bucket is refilled
+	limit Limit
+
+	day   int64
+	logs  int64
+	bytes int64
+
+	over int
+}
+
+// Limiter applies per key token-bucket rate limits and daily quotas.
+type Limiter struct {
+	now func() time.Time
+
+	// mu guards settings too, they change on Update
+	mu          sync.Mutex
+	by          string
+	mode        string
+	sampleEvery int
+	def         Limit
+	overrides   map[overrideKey]Limit
+	states      map[string]*state
+	swept       time.Time
+}
+
+// New creates limiter keyed by source. Overrides apply to sources of every
+// tenant.
+func New(mode string, sampleEvery int, def Limit, overrides map[string]Limit) (*Limiter, error) {
+	switch mode {
+	case "":
+		mode = ModeReject
+	case ModeReject, ModeSample:
+	default:
+		return nil, fmt.Errorf("invalid mode %q: should be reject or sample", mode)
+	}
+	if sampleEvery <= 0 {
+		sampleEvery = defaultSampleEvery
+	}
+
+	keyed := make(map[overrideKey]Limit, len(overrides))
+	for key, limit := range overrides {
+		keyed[overrideKey{key: key}] = limit
+	}
+
+	return &Limiter{
+		by:          KeyBySource,
+		mode:        mode,
+		sampleEvery: sampleEvery,
+		def:         def,
+		overrides:   keyed,
+		now:         time.Now,
+		states:      make(map[string]*state),
+	}, nil
+}
+
+// FromConfig creates limiter from config. Nil config gives nil limiter.
+func FromConfig(cfg *config.LimitsConfig) (*Limiter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	by := cfg.By
+	switch by {
+	case "":
+		by = KeyBySource
+	case KeyBySource, KeyByTenant:
+	default:
+		return nil, fmt.Errorf("limits: invalid by %q: should be source or tenant", cfg.By)
+	}
+
+	var def Limit
+	if cfg.Default != nil {
+		def = toLimit(cfg.Default)
+	}
+	overrides := make(map[overrideKey]Limit, len(cfg.Overrides))
+	for i, o := range cfg.Overrides {
+		if o.Key == "" {
+			return nil, fmt.Errorf("limits.overrides[%d]: key is empty", i)
+		}
+		if o.Tenant != "" && by == KeyByTenant {
+			return nil, fmt.Errorf("limits.overrides[%d]: tenant is set, but limits are keyed by tenant", i)
+		}
+		overrides[overrideKey{tenant: o.Tenant, key: o.Key}] = toLimit(o)
+	}
+
+	l, err := New(cfg.Mode, cfg.SampleEvery, def, nil)
+	if err != nil {
+		return nil, fmt.Errorf("limits: %v", err)
+	}
+	l.by = by
+	l.overrides = overrides
+	return l, nil
+}
+
+// By returns what limits are keyed by: source or tenant.
+func (l *Limiter) By() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.by
+}
+
+// Update applies settings of limiter from. Usage of keys is kept unless
+// limits are keyed differently.
+func (l *Limiter) Update(from *Limiter) {
+	from.mu.Lock()
+	by, mode, sampleEvery, def, overrides := from.by, from.mode, from.sampleEvery, from.def, from.overrides
+	from.mu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if by != l.by {
+		l.states = make(map[string]*state)
+	}
+	l.by = by
+	l.mode = mode
+	l.sampleEvery = sampleEvery
+	l.def = def
+	l.overrides = overrides
+}
+
+func toLimit(cfg *config.LimitConfig) Limit {
+	return Limit{
+		Rate:       cfg.Rate,
+		Burst:      cfg.Burst,
+		DailyLogs:  cfg.DailyLogs,
+		DailyBytes: cfg.DailyBytes,
+	}
+}
+
+// key returns state key and limit of source of tenant. Sources are limited
+// per tenant, so tenants sharing source names do not share limits.
+func (l *Limiter) key(tenantID, source string) (string, Limit) {
+	if l.by == KeyByTenant {
+		if limit, ok := l.overrides[overrideKey{key: tenantID}]; ok {
+			return tenantID, limit
+		}
+		return tenantID, l.def
+	}
+
+	key := tenantID + "\x00" + source
+	if limit, ok := l.overrides[overrideKey{tenant: tenantID, key: source}]; ok {
+		return key, limit
+	}
+	if limit, ok := l.overrides[overrideKey{key: source}]; ok {
+		return key, limit
+	}
+	return key, l.def
+}
+
+// Allow accounts log of size bytes of source of tenant.
+func (l *Limiter) Allow(tenantID, source string, size int) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key, limit := l.key(tenantID, source)
+	if limit.unlimited() {
+		return Decision{Allowed: true}
+	}
+
+	now := l.now()
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	st, ok := l.states[key]
+	if !ok {
+		st = &state{
+			tokens: float64(burst(limit)),
+			last:   now,
+		}
+		l.states[key] = st
+	}
+
+	if day := now.Unix() / 86400; day != st.day {
+		st.day = day
+		st.logs = 0
+		st.bytes = 0
+	}
+
+	if limit.Rate > 0 {
+		st.tokens = math.Min(float64(burst(limit)), st.tokens+now.Sub(st.last).Seconds()*limit.Rate)
+		st.last = now
+	}
+	st.limit = limit
+
+	var d Decision
+	switch {
+	case limit.DailyLogs > 0 && st.logs >= limit.DailyLogs:
+		d = Decision{Reason: "daily logs quota exceeded", RetryAfter: untilNextDay(now)}
+	case limit.DailyBytes > 0 && st.bytes+int64(size) > limit.DailyBytes:
+		d = Decision{Reason: "daily bytes quota exceeded", RetryAfter: untilNextDay(now)}
+	case limit.Rate > 0 && st.tokens < 1:
+		d = Decision{
+			Reason:     "rate limit exceeded",
+			RetryAfter: time.Duration((1 - st.tokens) / limit.Rate * float64(time.Second)),
+		}
+	default:
+		if limit.Rate > 0 {
+			st.tokens--
+		}
+		st.logs++
+		st.bytes += int64(size)
+		return Decision{Allowed: true}
+	}
+
+	if l.mode == ModeReject {
+		d.Reject = true
+		return d
+	}
+
+	st.over++
+	if (st.over-1)%l.sampleEvery == 0 {
+		st.logs++
+		st.bytes += int64(size)
+		d.Allowed = true
+	}
+	return d
+}
+
+// sweep evicts idle states: their bucket is full and they have no usage
+// today, so a new state is the same. Sources are supplied by clients, so
+// states would grow without bound otherwise.
+func (l *Limiter) sweep(now time.Time) {
+	l.swept = now
+	day := now.Unix() / 86400
+	for key, st := range l.states {
+		if st.day < day && st.refilled(now) {
+			delete(l.states, key)
+		}
+	}
+}
+
+func (st *state) refilled(now time.Time) bool {
+	if st.limit.Rate <= 0 {
+		return true
+	}
+	return st.tokens+now.Sub(st.last).Seconds()*st.limit.Rate >= float64(burst(st.limit))
+}
+
+func burst(limit Limit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return max(1, int(math.Ceil(limit.Rate)))
+}
+
+func untilNextDay(now time.Time) time.Duration {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return next.Sub(now)
+}
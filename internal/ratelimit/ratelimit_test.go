@@ -0,0 +1,250 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/config"
+)
+
+type call struct {
+	tenant  string
+	key     string
+	size    int
+	advance time.Duration
+
+	expectedAllowed    bool
+	expectedReject     bool
+	expectedRetryAfter time.Duration
+}
+
+func TestLimiter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   *config.LimitsConfig
+		calls []call
+	}{
+		{
+			name: "token bucket",
+			cfg: &config.LimitsConfig{
+				Default: &config.LimitConfig{Rate: 2, Burst: 2},
+			},
+			calls: []call{
+				{key: "api", expectedAllowed: true},
+				{key: "api", expectedAllowed: true},
+				{key: "api", expectedReject: true, expectedRetryAfter: 500 * time.Millisecond},
+				{key: "web", expectedAllowed: true},
+				{key: "api", advance: 500 * time.Millisecond, expectedAllowed: true},
+			},
+		},
+		{
+			name: "override",
+			cfg: &config.LimitsConfig{
+				Default:   &config.LimitConfig{Rate: 1},
+				Overrides: []*config.LimitConfig{{Key: "batch"}},
+			},
+			calls: []call{
+				{key: "batch", expectedAllowed: true},
+				{key: "batch", expectedAllowed: true},
+				{key: "api", expectedAllowed: true},
+				{key: "api", expectedReject: true, expectedRetryAfter: time.Second},
+			},
+		},
+		{
+			name: "sources of tenants are limited apart",
+			cfg: &config.LimitsConfig{
+				Default: &config.LimitConfig{DailyLogs: 1},
+			},
+			calls: []call{
+				{tenant: "acme", key: "api", expectedAllowed: true},
+				{tenant: "acme", key: "api", expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+				{tenant: "globex", key: "api", expectedAllowed: true},
+			},
+		},
+		{
+			name: "override of tenant",
+			cfg: &config.LimitsConfig{
+				Default: &config.LimitConfig{DailyLogs: 1},
+				Overrides: []*config.LimitConfig{
+					{Key: "api", DailyLogs: 2},
+					{Tenant: "acme", Key: "api"},
+				},
+			},
+			calls: []call{
+				{tenant: "acme", key: "api", expectedAllowed: true},
+				{tenant: "acme", key: "api", expectedAllowed: true},
+				{tenant: "acme", key: "api", expectedAllowed: true},
+				{tenant: "globex", key: "api", expectedAllowed: true},
+				{tenant: "globex", key: "api", expectedAllowed: true},
+				{tenant: "globex", key: "api", expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+				{tenant: "globex", key: "web", expectedAllowed: true},
+				{tenant: "globex", key: "web", expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+			},
+		},
+		{
+			name: "keyed by tenant",
+			cfg: &config.LimitsConfig{
+				By:        KeyByTenant,
+				Default:   &config.LimitConfig{DailyLogs: 1},
+				Overrides: []*config.LimitConfig{{Key: "acme", DailyLogs: 2}},
+			},
+			calls: []call{
+				{tenant: "acme", key: "api", expectedAllowed: true},
+				{tenant: "acme", key: "web", expectedAllowed: true},
+				{tenant: "acme", key: "api", expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+				{tenant: "globex", key: "api", expectedAllowed: true},
+				{tenant: "globex", key: "web", expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+			},
+		},
+		{
+			name: "daily quotas reset at midnight",
+			cfg: &config.LimitsConfig{
+				Default: &config.LimitConfig{DailyLogs: 2, DailyBytes: 10},
+			},
+			calls: []call{
+				{key: "api", size: 6, expectedAllowed: true},
+				{key: "api", size: 6, expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+				{key: "api", size: 4, expectedAllowed: true},
+				{key: "api", size: 0, expectedReject: true, expectedRetryAfter: 12 * time.Hour},
+				{key: "api", size: 6, advance: 12 * time.Hour, expectedAllowed: true},
+			},
+		},
+		{
+			name: "sample",
+			cfg: &config.LimitsConfig{
+				Mode:        ModeSample,
+				SampleEvery: 2,
+				Default:     &config.LimitConfig{Rate: 1},
+			},
+			calls: []call{
+				{key: "api", expectedAllowed: true},
+				{key: "api", expectedAllowed: true, expectedRetryAfter: time.Second},
+				{key: "api", expectedRetryAfter: time.Second},
+				{key: "api", expectedAllowed: true, expectedRetryAfter: time.Second},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := FromConfig(tc.cfg)
+			require.NoError(t, err)
+
+			now := time.Date(2025, 6, 12, 12, 0, 0, 0, time.UTC)
+			l.now = func() time.Time { return now }
+
+			for i, c := range tc.calls {
+				now = now.Add(c.advance)
+
+				d := l.Allow(c.tenant, c.key, c.size)
+				assert.Equal(t, c.expectedAllowed, d.Allowed, "call %d", i)
+				assert.Equal(t, c.expectedReject, d.Reject, "call %d", i)
+				assert.Equal(t, c.expectedRetryAfter, d.RetryAfter, "call %d", i)
+			}
+		})
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         *config.LimitsConfig
+		expectedErr string
+	}{
+		{
+			name:        "invalid mode",
+			cfg:         &config.LimitsConfig{Mode: "drop"},
+			expectedErr: `limits: invalid mode "drop": should be reject or sample`,
+		},
+		{
+			name:        "invalid key",
+			cfg:         &config.LimitsConfig{By: "host"},
+			expectedErr: `limits: invalid by "host": should be source or tenant`,
+		},
+		{
+			name:        "override without key",
+			cfg:         &config.LimitsConfig{Overrides: []*config.LimitConfig{{Rate: 1}}},
+			expectedErr: "limits.overrides[0]: key is empty",
+		},
+		{
+			name:        "override of tenant keyed by tenant",
+			cfg:         &config.LimitsConfig{By: KeyByTenant, Overrides: []*config.LimitConfig{{Tenant: "acme", Key: "acme"}}},
+			expectedErr: "limits.overrides[0]: tenant is set, but limits are keyed by tenant",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromConfig(tc.cfg)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestLimiterUpdate(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	l, err := FromConfig(&config.LimitsConfig{
+		Default: &config.LimitConfig{DailyLogs: 3},
+	})
+	require.NoError(t, err)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("default", "api", 1).Allowed)
+	assert.True(t, l.Allow("default", "api", 1).Allowed)
+
+	// quota is lowered, but today's usage is kept
+	next, err := FromConfig(&config.LimitsConfig{
+		Default: &config.LimitConfig{DailyLogs: 2},
+	})
+	require.NoError(t, err)
+	l.Update(next)
+	assert.True(t, l.Allow("default", "api", 1).Reject)
+
+	// usage is reset when limits are keyed differently
+	next, err = FromConfig(&config.LimitsConfig{
+		By:      KeyByTenant,
+		Default: &config.LimitConfig{DailyLogs: 2},
+	})
+	require.NoError(t, err)
+	l.Update(next)
+	assert.Equal(t, KeyByTenant, l.By())
+	assert.True(t, l.Allow("default", "api", 1).Allowed)
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2025, 6, 10, 23, 59, 0, 0, time.UTC)
+
+	l, err := FromConfig(&config.LimitsConfig{
+		Default: &config.LimitConfig{Rate: 0.01, Burst: 10, DailyLogs: 100},
+	})
+	require.NoError(t, err)
+	l.now = func() time.Time { return now }
+
+	for range 10 {
+		assert.True(t, l.Allow("default", "api", 1).Allowed)
+	}
+	assert.True(t, l.Allow("default", "web", 1).Allowed)
+	assert.Len(t, l.states, 2)
+
+	// next day, but the bucket of api is not refilled yet
+	now = now.Add(2 * time.Minute)
+	assert.True(t, l.Allow("acme", "api", 1).Allowed)
+	assert.Len(t, l.states, 2)
+	assert.Contains(t, l.states, "default\x00api")
+	assert.NotContains(t, l.states, "default\x00web")
+
+	// used today
+	now = now.Add(time.Minute)
+	assert.True(t, l.Allow("default", "api", 1).Allowed)
+	now = now.Add(time.Minute)
+	assert.True(t, l.Allow("acme", "api", 1).Allowed)
+	assert.Contains(t, l.states, "default\x00api")
+
+	now = now.Add(24 * time.Hour)
+	assert.True(t, l.Allow("acme", "api", 1).Allowed)
+	assert.Len(t, l.states, 1)
+}
@@ -0,0 +1,194 @@
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"logstream/internal/config"
+	"logstream/internal/repo"
+)
+
+// Replacement modes
+const (
+	ModeMask = "mask"
+	ModeHash = "hash"
+)
+
+// Redactor replaces sensitive data in log message and attribute values.
+type Redactor struct {
+	settings atomic.Pointer[settings]
+}
+
+// settings - rules of redactor, replaced as a whole on Update
+type settings struct {
+	rules   []Rule
+	mode    string
+	hashKey []byte
+	counts  map[string]*atomic.Uint64
+}
+
+func New(rules []Rule, mode string, hashKey []byte) (*Redactor, error) {
+	switch mode {
+	case "":
+		mode = ModeMask
+	case ModeMask, ModeHash:
+	default:
+		return nil, fmt.Errorf("invalid mode %q: should be mask or hash", mode)
+	}
+
+	st := &settings{
+		rules:   rules,
+		mode:    mode,
+		hashKey: hashKey,
+		counts:  make(map[string]*atomic.Uint64, len(rules)),
+	}
+	for _, rule := range rules {
+		if _, ok := st.counts[rule.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		st.counts[rule.Name] = &atomic.Uint64{}
+	}
+
+	r := &Redactor{}
+	r.settings.Store(st)
+	return r, nil
+}
+
+// Update applies rules and mode of redactor from. Counts of rules present
+// in both redactors are kept.
+func (r *Redactor) Update(from *Redactor) {
+	next := *from.settings.Load()
+	current := r.settings.Load()
+
+	next.counts = make(map[string]*atomic.Uint64, len(next.rules))
+	for _, rule := range next.rules {
+		count, ok := current.counts[rule.Name]
+		if !ok {
+			count = &atomic.Uint64{}
+		}
+		next.counts[rule.Name] = count
+	}
+	r.settings.Store(&next)
+}
+
+// FromConfig creates redactor from config. Nil config gives nil redactor.
+func FromConfig(cfg *config.RedactionConfig) (*Redactor, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	names := cfg.Rules
+	if len(names) == 0 {
+		names = BuiltinRules()
+	}
+
+	rules := make([]Rule, 0, len(names)+len(cfg.Custom))
+	for _, name := range names {
+		rule, ok := builtinRule(name)
+		if !ok {
+			return nil, fmt.Errorf("redaction: unknown rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	for i, custom := range cfg.Custom {
+		if custom.Name == "" {
+			return nil, fmt.Errorf("redaction.custom[%d]: name is empty", i)
+		}
+		re, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction.custom[%d]: invalid pattern: %v", i, err)
+		}
+		rules = append(rules, Rule{
+			Name:    custom.Name,
+			Pattern: re,
+		})
+	}
+
+	r, err := New(rules, cfg.Mode, []byte(cfg.HashKey))
+	if err != nil {
+		return nil, fmt.Errorf("redaction: %v", err)
+	}
+	return r, nil
+}
+
+// Process implements pipeline.Processor
+func (r *Redactor) Process(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+	log.Message = r.Redact(log.Message)
+	for key, value := range log.Attributes {
+		log.Attributes[key] = r.Redact(value)
+	}
+	return log, nil
+}
+
+// Redact applies all rules to s.
+func (r *Redactor) Redact(s string) string {
+	st := r.settings.Load()
+	for _, rule := range st.rules {
+		s = st.apply(rule, s)
+	}
+	return s
+}
+
+func (st *settings) apply(rule Rule, s string) string {
+	matches := rule.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var (
+		b     strings.Builder
+		last  int
+		count uint64
+	)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		// redact only the first group when pattern has one and it matched
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if start == end || start < last {
+			continue
+		}
+		value := s[start:end]
+		if rule.Valid != nil && !rule.Valid(value) {
+			continue
+		}
+
+		b.WriteString(s[last:start])
+		b.WriteString(st.replacement(rule.Name, value))
+		last = end
+		count++
+	}
+	if count == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+
+	st.counts[rule.Name].Add(count)
+	return b.String()
+}
+
+func (st *settings) replacement(rule, value string) string {
+	if st.mode == ModeHash {
+		mac := hmac.New(sha256.New, st.hashKey)
+		mac.Write([]byte(value))
+		return fmt.Sprintf("[%s:%s]", rule, hex.EncodeToString(mac.Sum(nil))[:16])
+	}
+	return fmt.Sprintf("[REDACTED:%s]", rule)
+}
+
+// Counts returns number of redactions per rule.
+func (r *Redactor) Counts() map[string]uint64 {
+	st := r.settings.Load()
+	counts := make(map[string]uint64, len(st.counts))
+	for name, count := range st.counts {
+		counts[name] = count.Load()
+	}
+	return counts
+}
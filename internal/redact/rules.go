@@ -0,0 +1,109 @@
+package redact
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Built-in rule names
+const (
+	RuleEmail      = "email"
+	RuleCreditCard = "credit_card"
+	RuleJWT        = "jwt"
+	RuleBearer     = "bearer"
+	RuleAPIKey     = "api_key"
+	RuleIPv4       = "ipv4"
+	RuleIPv6       = "ipv6"
+)
+
+// Rule - named detector. If pattern has capture group, only the first
+// group is redacted, otherwise the whole match.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid - optional check of matched text, e.g. Luhn checksum
+	Valid func(s string) bool
+}
+
+// builtinRules are applied in this order, so e.g. JWT sent as bearer token
+// is counted as jwt.
+var builtinRules = []Rule{
+	{
+		Name:    RuleEmail,
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		Name:    RuleCreditCard,
+		Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Valid:   luhn,
+	},
+	{
+		Name:    RuleJWT,
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	{
+		Name:    RuleBearer,
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	},
+	{
+		Name: RuleAPIKey,
+		Pattern: regexp.MustCompile(`\b(?:AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36}|(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}|xox[abposr]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})\b` +
+			`|(?i)\b(?:api[_-]?key|secret|access[_-]?token|password|passwd)\b["']?\s*[=:]\s*["']?([^\s"',;&]+)`),
+	},
+	{
+		Name:    RuleIPv4,
+		Pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	},
+	{
+		Name:    RuleIPv6,
+		Pattern: regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`),
+		Valid:   ipv6,
+	},
+}
+
+// BuiltinRules returns names of built-in rules.
+func BuiltinRules() []string {
+	names := make([]string, 0, len(builtinRules))
+	for _, rule := range builtinRules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func builtinRule(name string) (Rule, bool) {
+	for _, rule := range builtinRules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// luhn checks card number checksum ignoring spaces and dashes.
+func luhn(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func ipv6(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is6()
+}
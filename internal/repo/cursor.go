@@ -0,0 +1,126 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// Position - position of a log in created_at and id order
+type Position struct {
+	CreatedAt int64
+	Id        int32
+}
+
+// Compare returns -1, 0 or +1 as p is before, at or after other.
+func (p Position) Compare(other Position) int {
+	return cmp.Or(cmp.Compare(p.CreatedAt, other.CreatedAt), cmp.Compare(p.Id, other.Id))
+}
+
+// Position returns position of the saved log.
+func (l *Log) Position() Position {
+	return Position{CreatedAt: l.CreatedAt, Id: *l.Id}
+}
+
+// Page - page of logs in order of filter
+type Page struct {
+	// After - position of the last log of the previous page, nil for the
+	// first page
+	After *Position
+	Limit int
+}
+
+// Match reports whether log follows the previous page in order of filter.
+// Limit is not checked.
+func (p *Page) Match(filter *Filter, log *Log) bool {
+	return p.After == nil || filter.Compare(log.Position(), *p.After) > 0
+}
+
+// Paginate returns logs of the page in order of filter, sorting logs in
+// place. Limit of filter is ignored.
+func Paginate(logs []*Log, filter *Filter, page Page) []*Log {
+	logs = slices.DeleteFunc(logs, func(l *Log) bool { return !page.Match(filter, l) })
+	slices.SortFunc(logs, func(a, b *Log) int { return filter.Compare(a.Position(), b.Position()) })
+	if len(logs) > page.Limit {
+		logs = logs[:page.Limit]
+	}
+	return logs
+}
+
+// Cursor iterates logs by filter in its order up to its limit. Logs are
+// read from repo page by page, so at most one page is held in memory.
+type Cursor struct {
+	r        Repo
+	filter   Filter
+	page     Page
+	pageSize int
+
+	logs []*Log
+	log  *Log
+	read int
+	last bool
+	err  error
+}
+
+// NewCursor creates cursor reading pages of pageSize logs.
+func NewCursor(r Repo, filter Filter, pageSize int) *Cursor {
+	return &Cursor{
+		r:        r,
+		filter:   filter,
+		pageSize: max(pageSize, 1),
+	}
+}
+
+// Next advances to the next log, reading the next page once the current
+// one is over. It returns false when logs are over, ctx is done or reading
+// failed.
+func (c *Cursor) Next(ctx context.Context) bool {
+	if c.err != nil {
+		return false
+	}
+
+	if len(c.logs) == 0 {
+		if c.last {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			c.err = err
+			return false
+		}
+		c.page.Limit = c.pageSize
+		if c.filter.Limit > 0 {
+			if c.read >= c.filter.Limit {
+				return false
+			}
+			c.page.Limit = min(c.pageSize, c.filter.Limit-c.read)
+		}
+
+		logs, err := c.r.GetLogsPage(ctx, c.filter, c.page)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		// a short page is the last one
+		c.last = len(logs) < c.page.Limit
+		if len(logs) == 0 {
+			return false
+		}
+		after := logs[len(logs)-1].Position()
+		c.page.After = &after
+		c.logs = logs
+	}
+
+	c.log, c.logs = c.logs[0], c.logs[1:]
+	c.read++
+	return true
+}
+
+// Log returns the current log.
+func (c *Cursor) Log() *Log {
+	return c.log
+}
+
+// Err returns error stopped the cursor.
+func (c *Cursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,105 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/repo"
+	"logstream/internal/repo/memory"
+)
+
+// pagesRepo counts pages read by cursor.
+type pagesRepo struct {
+	repo.Repo
+	pages int
+}
+
+func (r *pagesRepo) GetLogsPage(ctx context.Context, filter repo.Filter, page repo.Page) ([]*repo.Log, error) {
+	r.pages++
+	return r.Repo.GetLogsPage(ctx, filter, page)
+}
+
+func TestCursor(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.NewRepo(0)
+	_, err := mem.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "3", CreatedAt: 30},
+		{Source: "api", Message: "1", CreatedAt: 10},
+		{Source: "api", Message: "2a", CreatedAt: 20},
+		{Source: "api", Message: "2b", CreatedAt: 20},
+		{Source: "api", Message: "4", CreatedAt: 40},
+		{Source: "worker", Message: "other source", CreatedAt: 20},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		filter           repo.Filter
+		pageSize         int
+		expectedMessages []string
+		expectedPages    int
+	}{
+		{
+			name:             "logs in time order",
+			filter:           repo.Filter{Source: "api", EndTime: 100},
+			pageSize:         2,
+			expectedMessages: []string{"1", "2a", "2b", "3", "4"},
+			expectedPages:    3,
+		},
+		{
+			name:             "full last page",
+			filter:           repo.Filter{Source: "api", EndTime: 100},
+			pageSize:         5,
+			expectedMessages: []string{"1", "2a", "2b", "3", "4"},
+			expectedPages:    2,
+		},
+		{
+			name:             "one log per page",
+			filter:           repo.Filter{Source: "api", EndTime: 100},
+			pageSize:         1,
+			expectedMessages: []string{"1", "2a", "2b", "3", "4"},
+			expectedPages:    6,
+		},
+		{
+			name:             "newest logs up to limit",
+			filter:           repo.Filter{Source: "api", EndTime: 100, Desc: true, Limit: 3},
+			pageSize:         2,
+			expectedMessages: []string{"4", "3", "2b"},
+			expectedPages:    2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &pagesRepo{Repo: mem}
+			cursor := repo.NewCursor(r, tc.filter, tc.pageSize)
+
+			var messages []string
+			for cursor.Next(ctx) {
+				messages = append(messages, cursor.Log().Message)
+			}
+			require.NoError(t, cursor.Err())
+			assert.Equal(t, tc.expectedMessages, messages)
+			assert.Equal(t, tc.expectedPages, r.pages)
+		})
+	}
+}
+
+func TestCursorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := memory.NewRepo(0)
+	_, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "1", CreatedAt: 10},
+		{Source: "api", Message: "2", CreatedAt: 20},
+	})
+	require.NoError(t, err)
+
+	cursor := repo.NewCursor(r, repo.Filter{Source: "api", EndTime: 100}, 1)
+	require.True(t, cursor.Next(ctx))
+	cancel()
+	assert.False(t, cursor.Next(ctx))
+	assert.ErrorIs(t, cursor.Err(), context.Canceled)
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Filter - filter of logs
+type Filter struct {
+	Source    string
+	Level     int32
+	StartTime int64
+	EndTime   int64
+	// Query - words the message must contain in any order, case-insensitive;
+	// empty matches every message
+	Query string
+	// Desc - newest logs first; logs are ordered by created_at, then id
+	Desc bool
+	// Limit - max logs to get, 0 means no limit
+	Limit int
+}
+
+// Words splits s into lowercase words the way full-text indexes of
+// storages do: on every character that is not a letter or a digit.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Match reports whether log matches the filter. Tenant is not checked.
+func (f *Filter) Match(log *Log) bool {
+	if log.Source != f.Source || log.Level != f.Level || log.CreatedAt < f.StartTime || log.CreatedAt > f.EndTime {
+		return false
+	}
+
+	words := Words(f.Query)
+	if len(words) == 0 {
+		return true
+	}
+	message := make(map[string]bool)
+	for _, word := range Words(log.Message) {
+		message[word] = true
+	}
+	for _, word := range words {
+		if !message[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares positions in order of the filter.
+func (f *Filter) Compare(a, b Position) int {
+	if f.Desc {
+		return b.Compare(a)
+	}
+	return a.Compare(b)
+}
+
+// Sort sorts logs in order of the filter, in place, and cuts them to the
+// limit.
+func (f *Filter) Sort(logs []*Log) []*Log {
+	slices.SortFunc(logs, func(a, b *Log) int { return f.Compare(a.Position(), b.Position()) })
+	if f.Limit > 0 && len(logs) > f.Limit {
+		logs = logs[:f.Limit]
+	}
+	return logs
+}
@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"logstream/internal/database"
+	"logstream/internal/tenant"
+	pb "logstream/pkg/api/logstream"
+)
+
+type APIKey struct {
+	Id        int32  `db:"id"`
+	Tenant    string `db:"tenant_id"`
+	Name      string `db:"name"`
+	Prefix    string `db:"prefix"`
+	Hash      string `db:"key_hash"`
+	Role      string `db:"role"`
+	CreatedAt int64  `db:"created_at"`
+	ExpiresAt int64  `db:"expires_at"`
+	RevokedAt int64  `db:"revoked_at"`
+}
+
+func (key *APIKey) ToPbAPIKey() *pb.APIKey {
+	return &pb.APIKey{
+		Id:        key.Id,
+		TenantId:  key.Tenant,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Role:      key.Role,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+type keysRepo struct {
+	db *sql.DB
+}
+
+type KeysRepo interface {
+	// GetAPIKeyByHash - get API key by hash of the key
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+
+	// GetAPIKeys - get API keys of tenant
+	GetAPIKeys(ctx context.Context) ([]*APIKey, error)
+
+	// AddAPIKey - add API key
+	AddAPIKey(ctx context.Context, key *APIKey) (int32, error)
+
+	// RevokeAPIKey - revoke API key of tenant
+	RevokeAPIKey(ctx context.Context, id int32, revokedAt int64) error
+}
+
+func NewKeysRepo(db *sql.DB) KeysRepo {
+	return &keysRepo{
+		db: db,
+	}
+}
+
+const apiKeyColumns = "id, tenant_id, name, prefix, key_hash, role, created_at, expires_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var key APIKey
+	err := row.Scan(&key.Id, &key.Tenant, &key.Name, &key.Prefix, &key.Hash, &key.Role, &key.CreatedAt, &key.ExpiresAt, &key.RevokedAt)
+	return &key, err
+}
+
+func (r *keysRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = $1"
+	key, err := scanAPIKey(db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %v", err)
+	}
+
+	return key, nil
+}
+
+func (r *keysRepo) GetAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE tenant_id = $1 ORDER BY id"
+	rows, err := db.QueryContext(ctx, query, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (r *keysRepo) AddAPIKey(ctx context.Context, key *APIKey) (int32, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var id int32
+	query := "INSERT INTO api_keys (tenant_id, name, prefix, key_hash, role, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	if err := db.QueryRowContext(ctx, query, key.Tenant, key.Name, key.Prefix, key.Hash, key.Role, key.CreatedAt, key.ExpiresAt).Scan(&id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return 0, database.ErrPKeyConflict
+		}
+		return 0, fmt.Errorf("failed to add api key: %v", err)
+	}
+
+	return id, nil
+}
+
+func (r *keysRepo) RevokeAPIKey(ctx context.Context, id int32, revokedAt int64) error {
+	db := database.FromContext(ctx, r.db)
+
+	query := "UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at = 0 AND tenant_id = $3"
+	res, err := db.ExecContext(ctx, query, id, revokedAt, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
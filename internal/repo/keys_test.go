@@ -0,0 +1,126 @@
+package repo_test
+
+import (
+	"database/sql"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/repo"
+)
+
+func (s *Suite) TestGetAPIKeyByHash() {
+	r := repo.NewKeysRepo(s.db)
+	columns := []string{"id", "tenant_id", "name", "prefix", "key_hash", "role", "created_at", "expires_at", "revoked_at"}
+
+	testCases := []struct {
+		name        string
+		hash        string
+		mockSetup   func(mock sqlmock.Sqlmock)
+		expectedKey *repo.APIKey
+		expectedErr string
+	}{
+		{
+			name: "get api key",
+			hash: "abc",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, tenant_id, name, prefix, key_hash, role, created_at, expires_at, revoked_at FROM api_keys WHERE key_hash = $1`)).
+					WithArgs("abc").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "default", "agent", "lsk_abcdefgh", "abc", "writer", 1749108957, 0, 0))
+			},
+			expectedKey: &repo.APIKey{
+				Id:        1,
+				Tenant:    "default",
+				Name:      "agent",
+				Prefix:    "lsk_abcdefgh",
+				Hash:      "abc",
+				Role:      "writer",
+				CreatedAt: 1749108957,
+			},
+		},
+		{
+			name: "api key not found",
+			hash: "def",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, tenant_id, name, prefix, key_hash, role, created_at, expires_at, revoked_at FROM api_keys WHERE key_hash = $1`)).
+					WithArgs("def").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: "record not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			key, err := r.GetAPIKeyByHash(s.ctx, tc.hash)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.True(t, reflect.DeepEqual(tc.expectedKey, key))
+			} else {
+				assert.Nil(t, key)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *Suite) TestAddAPIKey() {
+	r := repo.NewKeysRepo(s.db)
+
+	testCases := []struct {
+		name        string
+		key         *repo.APIKey
+		mockSetup   func(mock sqlmock.Sqlmock)
+		expectedId  int32
+		expectedErr string
+	}{
+		{
+			name: "add api key",
+			key:  &repo.APIKey{Tenant: "default", Name: "agent", Prefix: "lsk_abcdefgh", Hash: "abc", Role: "writer", CreatedAt: 1749108957},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`INSERT INTO api_keys (tenant_id, name, prefix, key_hash, role, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`)).
+					WithArgs("default", "agent", "lsk_abcdefgh", "abc", "writer", 1749108957, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			expectedId: 1,
+		},
+		{
+			name: "duplicate name",
+			key:  &repo.APIKey{Tenant: "default", Name: "agent", Prefix: "lsk_abcdefgh", Hash: "def", Role: "writer", CreatedAt: 1749108957},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`INSERT INTO api_keys (tenant_id, name, prefix, key_hash, role, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`)).
+					WithArgs("default", "agent", "lsk_abcdefgh", "def", "writer", 1749108957, 0).
+					WillReturnError(&pq.Error{Code: "23505"})
+			},
+			expectedErr: "primary key conflict",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			id, err := r.AddAPIKey(s.ctx, tc.key)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedId, id)
+			} else {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type keysRepo struct {
+	mu     sync.RWMutex
+	lastID int32
+	// keys - keys of every tenant in id order
+	keys []*repo.APIKey
+}
+
+func NewKeysRepo() repo.KeysRepo {
+	return &keysRepo{}
+}
+
+func (r *keysRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*repo.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			k := *key
+			return &k, nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+func (r *keysRepo) GetAPIKeys(ctx context.Context) ([]*repo.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	var keys []*repo.APIKey
+	for _, key := range r.keys {
+		if key.Tenant == tenantID {
+			k := *key
+			keys = append(keys, &k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *keysRepo) AddAPIKey(ctx context.Context, key *repo.APIKey) (int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if (k.Tenant == key.Tenant && k.Name == key.Name) || k.Hash == key.Hash {
+			return 0, database.ErrPKeyConflict
+		}
+	}
+
+	r.lastID++
+	k := *key
+	k.Id = r.lastID
+	k.RevokedAt = 0
+	r.keys = append(r.keys, &k)
+
+	return k.Id, nil
+}
+
+func (r *keysRepo) RevokeAPIKey(ctx context.Context, id int32, revokedAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := tenant.FromContext(ctx)
+	for _, key := range r.keys {
+		if key.Id == id && key.RevokedAt == 0 && key.Tenant == tenantID {
+			key.RevokedAt = revokedAt
+			return nil
+		}
+	}
+
+	return database.ErrNotFound
+}
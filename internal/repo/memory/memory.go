@@ -0,0 +1,264 @@
+// Package memory implements repositories keeping data in memory. Data is
+// lost on restart; it is meant for local development, tests and running
+// as a sidecar without a database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type entry struct {
+	tenant string
+	log    repo.Log
+}
+
+type idempotencyKey struct {
+	tenant string
+	key    string
+}
+
+type logsRepo struct {
+	mu      sync.RWMutex
+	maxLogs int
+	lastID  int32
+	// logs - logs of every tenant in id order
+	logs []*entry
+	// keys - ids of logs having idempotency key
+	keys map[idempotencyKey]int32
+	// sources - sources of stored logs by tenant and name
+	sources map[string]map[string]*repo.Source
+}
+
+// NewRepo creates repo keeping at most maxLogs logs, the oldest are
+// evicted first. Zero maxLogs means no limit.
+func NewRepo(maxLogs int) repo.Repo {
+	return &logsRepo{
+		maxLogs: maxLogs,
+		keys:    make(map[idempotencyKey]int32),
+		sources: make(map[string]map[string]*repo.Source),
+	}
+}
+
+// RunInTx runs f. Changes made by f are not rolled back on error.
+func (r *logsRepo) RunInTx(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := f(ctx); err != nil {
+		return fmt.Errorf("failed to invoke func: %v", err)
+	}
+	return nil
+}
+
+func (r *logsRepo) GetLog(ctx context.Context, id int32) (*repo.Log, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := sort.Search(len(r.logs), func(i int) bool { return *r.logs[i].log.Id >= id })
+	if i == len(r.logs) || *r.logs[i].log.Id != id || r.logs[i].tenant != tenant.FromContext(ctx) {
+		return nil, database.ErrNotFound
+	}
+
+	return r.logs[i].log.Clone(), nil
+}
+
+func (r *logsRepo) GetLogs(ctx context.Context, filter repo.Filter) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	var logs []*repo.Log
+	for _, e := range r.logs {
+		if e.tenant != tenantID || !filter.Match(&e.log) {
+			continue
+		}
+		logs = append(logs, e.log.Clone())
+	}
+
+	if len(logs) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	return filter.Sort(logs), nil
+}
+
+func (r *logsRepo) GetLogsPage(ctx context.Context, filter repo.Filter, page repo.Page) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	var logs []*repo.Log
+	for _, e := range r.logs {
+		if e.tenant != tenantID || !filter.Match(&e.log) || !page.Match(&filter, &e.log) {
+			continue
+		}
+		logs = append(logs, &e.log)
+	}
+
+	logs = repo.Paginate(logs, &filter, page)
+	for i, l := range logs {
+		logs[i] = l.Clone()
+	}
+	return logs, nil
+}
+
+func (r *logsRepo) AddLog(ctx context.Context, log *repo.Log) (int32, error) {
+	if log.Level > 2 {
+		return 0, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.add(tenant.FromContext(ctx), log), nil
+}
+
+func (r *logsRepo) AddLogs(ctx context.Context, logs []*repo.Log) ([]int32, error) {
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no logs to add")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	ids := make([]int32, 0, len(logs))
+	for _, log := range logs {
+		ids = append(ids, r.add(tenantID, log))
+	}
+
+	return ids, nil
+}
+
+// add stores copy of log and evicts the oldest logs over the limit. Log
+// with saved idempotency key is not stored again. r.mu must be held.
+func (r *logsRepo) add(tenantID string, log *repo.Log) int32 {
+	key := idempotencyKey{tenant: tenantID, key: log.IdempotencyKey}
+	if key.key != "" {
+		if id, ok := r.keys[key]; ok {
+			return id
+		}
+	}
+
+	r.lastID++
+	id := r.lastID
+
+	e := &entry{
+		tenant: tenantID,
+		log:    *log.Clone(),
+	}
+	e.log.Id = &id
+	r.logs = append(r.logs, e)
+	if key.key != "" {
+		r.keys[key] = id
+	}
+	sources, ok := r.sources[tenantID]
+	if !ok {
+		sources = make(map[string]*repo.Source)
+		r.sources[tenantID] = sources
+	}
+	source, ok := sources[log.Source]
+	if !ok {
+		source = &repo.Source{Name: log.Source}
+		sources[log.Source] = source
+	}
+	source.Add(&e.log)
+
+	if r.maxLogs > 0 && len(r.logs) > r.maxLogs {
+		n := len(r.logs) - r.maxLogs
+		for _, e := range r.logs[:n] {
+			r.forget(e)
+		}
+		clear(r.logs[:n])
+		r.logs = r.logs[n:]
+	}
+
+	return id
+}
+
+// forget removes idempotency key of removed log and uncounts it from its
+// source. r.mu must be held.
+func (r *logsRepo) forget(e *entry) {
+	if e.log.IdempotencyKey != "" {
+		delete(r.keys, idempotencyKey{tenant: e.tenant, key: e.log.IdempotencyKey})
+	}
+	if source, ok := r.sources[e.tenant][e.log.Source]; ok {
+		source.Remove(&e.log)
+		if source.Total() == 0 {
+			delete(r.sources[e.tenant], e.log.Source)
+		}
+	}
+}
+
+func (r *logsRepo) GetTenants(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var tenants []string
+	for _, e := range r.logs {
+		if !seen[e.tenant] {
+			seen[e.tenant] = true
+			tenants = append(tenants, e.tenant)
+		}
+	}
+
+	return tenants, nil
+}
+
+func (r *logsRepo) GetSources(ctx context.Context, prefix string) ([]*repo.Source, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return repo.SortSources(r.sources[tenant.FromContext(ctx)], prefix), nil
+}
+
+func (r *logsRepo) DeleteLogs(ctx context.Context, before int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	kept := make([]*entry, 0, len(r.logs))
+	for _, e := range r.logs {
+		if e.tenant != tenantID || e.log.CreatedAt >= before {
+			kept = append(kept, e)
+			continue
+		}
+		r.forget(e)
+	}
+	n := int64(len(r.logs) - len(kept))
+	r.logs = kept
+
+	// forget moved first seen times to the deleted logs, the oldest kept
+	// logs are known here
+	first := make(map[string]int64)
+	for _, e := range kept {
+		if e.tenant != tenantID {
+			continue
+		}
+		if t, ok := first[e.log.Source]; !ok || e.log.CreatedAt < t {
+			first[e.log.Source] = e.log.CreatedAt
+		}
+	}
+	for name, t := range first {
+		r.sources[tenantID][name].FirstSeen = t
+	}
+
+	return n, nil
+}
@@ -0,0 +1,330 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/repo/memory"
+	"logstream/internal/tenant"
+)
+
+func addLogs(t *testing.T, ctx context.Context, r repo.Repo, logs ...*repo.Log) []int32 {
+	t.Helper()
+	ids, err := r.AddLogs(ctx, logs)
+	require.NoError(t, err)
+	return ids
+}
+
+func TestGetLogs(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepo(0)
+	addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Level: 1, Message: "slow request", CreatedAt: 100, Attributes: repo.Attributes{"path": "/"}},
+		&repo.Log{Source: "api", Level: 1, Message: "slow query", CreatedAt: 200},
+		&repo.Log{Source: "api", Level: 2, Message: "failed", CreatedAt: 150},
+		&repo.Log{Source: "worker", Level: 1, Message: "retry", CreatedAt: 150},
+	)
+	addLogs(t, tenant.WithTenant(ctx, "acme"), r,
+		&repo.Log{Source: "api", Level: 1, Message: "other tenant", CreatedAt: 150},
+	)
+
+	testCases := []struct {
+		name             string
+		ctx              context.Context
+		filter           repo.Filter
+		expectedMessages []string
+		expectedErr      string
+	}{
+		{
+			name:             "get logs in id order",
+			ctx:              ctx,
+			filter:           repo.Filter{Source: "api", Level: 1, EndTime: 1000},
+			expectedMessages: []string{"slow request", "slow query"},
+		},
+		{
+			name:             "newest logs",
+			ctx:              ctx,
+			filter:           repo.Filter{Source: "api", Level: 1, EndTime: 1000, Desc: true, Limit: 1},
+			expectedMessages: []string{"slow query"},
+		},
+		{
+			name:             "bounds are inclusive",
+			ctx:              ctx,
+			filter:           repo.Filter{Source: "api", Level: 1, StartTime: 200, EndTime: 200},
+			expectedMessages: []string{"slow query"},
+		},
+		{
+			name:             "search message",
+			ctx:              ctx,
+			filter:           repo.Filter{Source: "api", Level: 1, EndTime: 1000, Query: "QUERY slow"},
+			expectedMessages: []string{"slow query"},
+		},
+		{
+			name:             "tenant",
+			ctx:              tenant.WithTenant(ctx, "acme"),
+			filter:           repo.Filter{Source: "api", Level: 1, EndTime: 1000},
+			expectedMessages: []string{"other tenant"},
+		},
+		{
+			name:        "logs not found",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", EndTime: 1000},
+			expectedErr: "record not found",
+		},
+		{
+			name:        "invalid log level",
+			ctx:         ctx,
+			filter:      repo.Filter{Level: 1000},
+			expectedErr: "invalid log level",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := r.GetLogs(tc.ctx, tc.filter)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				messages := make([]string, 0, len(logs))
+				for _, l := range logs {
+					messages = append(messages, l.Message)
+				}
+				assert.Equal(t, tc.expectedMessages, messages)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				assert.Nil(t, logs)
+			}
+		})
+	}
+}
+
+func TestLogsAreCopied(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepo(0)
+
+	l := &repo.Log{Source: "api", Message: "started", CreatedAt: 1, Attributes: repo.Attributes{"pid": "1"}}
+	id, err := r.AddLog(ctx, l)
+	require.NoError(t, err)
+	assert.Nil(t, l.Id)
+	l.Attributes["pid"] = "2"
+
+	saved, err := r.GetLog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, *saved.Id)
+	assert.Equal(t, repo.Attributes{"pid": "1"}, saved.Attributes)
+	saved.Attributes["pid"] = "3"
+
+	saved, err = r.GetLog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "1", saved.Attributes["pid"])
+
+	_, err = r.GetLog(tenant.WithTenant(ctx, "acme"), id)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestMaxLogs(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepo(2)
+
+	ids := addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Message: "1", CreatedAt: 1},
+		&repo.Log{Source: "api", Message: "2", CreatedAt: 2},
+		&repo.Log{Source: "api", Message: "3", CreatedAt: 3},
+	)
+	assert.Equal(t, []int32{1, 2, 3}, ids)
+
+	_, err := r.GetLog(ctx, ids[0])
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	logs, err := r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "2", logs[0].Message)
+	assert.Equal(t, "3", logs[1].Message)
+}
+
+func TestDeleteLogs(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	r := memory.NewRepo(0)
+
+	addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Message: "old", CreatedAt: 1},
+		&repo.Log{Source: "api", Message: "new", CreatedAt: 10},
+	)
+	addLogs(t, acme, r, &repo.Log{Source: "api", Message: "old", CreatedAt: 1})
+
+	tenants, err := r.GetTenants(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"default", "acme"}, tenants)
+
+	n, err := r.DeleteLogs(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	logs, err := r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "new", logs[0].Message)
+
+	logs, err = r.GetLogs(acme, repo.Filter{Source: "api", EndTime: 10})
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepo(0)
+
+	ids := addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Message: "first", CreatedAt: 1, IdempotencyKey: "a"},
+		&repo.Log{Source: "api", Message: "retried", CreatedAt: 1, IdempotencyKey: "a"},
+		&repo.Log{Source: "api", Message: "no key", CreatedAt: 1},
+	)
+	assert.Equal(t, []int32{1, 1, 2}, ids)
+
+	id, err := r.AddLog(ctx, &repo.Log{Source: "api", Message: "retried", CreatedAt: 1, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), id)
+
+	// keys are per tenant
+	id, err = r.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 1, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), id)
+
+	// keys of deleted logs are free again
+	_, err = r.DeleteLogs(ctx, 10)
+	require.NoError(t, err)
+	id, err = r.AddLog(ctx, &repo.Log{Source: "api", Message: "after delete", CreatedAt: 20, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), id)
+}
+
+func TestGetSources(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	r := memory.NewRepo(0)
+
+	addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		&repo.Log{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		&repo.Log{Source: "api", Level: 2, Message: "failed", CreatedAt: 30},
+		&repo.Log{Source: "api-gateway", Level: 1, Message: "slow", CreatedAt: 40},
+		&repo.Log{Source: "web", Level: 0, Message: "started", CreatedAt: 170},
+		&repo.Log{Source: "api", Level: 0, Message: "stopped", CreatedAt: 180},
+	)
+	addLogs(t, acme, r, &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 10})
+
+	// the retried log is counted once
+	sources, err := r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 30, LastSeen: 180, Logs: [3]int64{2, 0, 1}},
+		{Name: "api-gateway", FirstSeen: 40, LastSeen: 40, Logs: [3]int64{0, 1, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+
+	sources, err = r.GetSources(ctx, "api")
+	require.NoError(t, err)
+	assert.Len(t, sources, 2)
+	sources, err = r.GetSources(ctx, "db")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+
+	sources, err = r.GetSources(acme, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{{Name: "api", FirstSeen: 10, LastSeen: 10, Logs: [3]int64{1, 0, 0}}}, sources)
+
+	// sources without logs are gone, first seen moves to the oldest kept log
+	_, err = r.DeleteLogs(ctx, 50)
+	require.NoError(t, err)
+	sources, err = r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 50, LastSeen: 180, Logs: [3]int64{2, 0, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+}
+
+func TestGetSourcesEvicted(t *testing.T) {
+	ctx := context.Background()
+	r := memory.NewRepo(2)
+
+	addLogs(t, ctx, r,
+		&repo.Log{Source: "api", Message: "evicted", CreatedAt: 10},
+		&repo.Log{Source: "web", Message: "evicted", CreatedAt: 20},
+		&repo.Log{Source: "api", Message: "kept", CreatedAt: 30},
+		&repo.Log{Source: "api", Message: "kept", CreatedAt: 40},
+	)
+
+	sources, err := r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{{Name: "api", FirstSeen: 10, LastSeen: 40, Logs: [3]int64{2, 0, 0}}}, sources)
+}
+
+func TestAddLogsEmpty(t *testing.T) {
+	ids, err := memory.NewRepo(0).AddLogs(context.Background(), nil)
+	assert.ErrorContains(t, err, "no logs to add")
+	assert.Nil(t, ids)
+}
+
+func TestKeysRepo(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	r := memory.NewKeysRepo()
+
+	id, err := r.AddAPIKey(ctx, &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h1", Role: "writer"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), id)
+
+	testCases := []struct {
+		name        string
+		key         *repo.APIKey
+		expectedErr error
+	}{
+		{
+			name: "same name of other tenant",
+			key:  &repo.APIKey{Tenant: "acme", Name: "ci", Hash: "h2"},
+		},
+		{
+			name:        "duplicate name",
+			key:         &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h3"},
+			expectedErr: database.ErrPKeyConflict,
+		},
+		{
+			name:        "duplicate hash",
+			key:         &repo.APIKey{Tenant: "default", Name: "cd", Hash: "h1"},
+			expectedErr: database.ErrPKeyConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := r.AddAPIKey(ctx, tc.key)
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+
+	key, err := r.GetAPIKeyByHash(ctx, "h2")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", key.Tenant)
+
+	keys, err := r.GetAPIKeys(acme)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "h2", keys[0].Hash)
+
+	// keys of other tenants are not revoked
+	assert.ErrorIs(t, r.RevokeAPIKey(acme, id, 100), database.ErrNotFound)
+	require.NoError(t, r.RevokeAPIKey(ctx, id, 100))
+	assert.ErrorIs(t, r.RevokeAPIKey(ctx, id, 200), database.ErrNotFound)
+
+	key, err = r.GetAPIKeyByHash(ctx, "h1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), key.RevokedAt)
+}
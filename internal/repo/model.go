@@ -1,31 +1,100 @@
 package repo
 
-import pb "logstream/pkg/api/logstream"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	pb "logstream/pkg/api/logstream"
+)
 
 type Log struct {
-	Id        *int32 `db:"id"`
-	Source    string `db:"source"`
-	Level     int32  `db:"lvl"`
-	Message   string `db:"message"`
-	CreatedAt int64  `db:"created_at"`
+	Id         *int32     `db:"id"`
+	Source     string     `db:"source"`
+	Level      int32      `db:"lvl"`
+	Message    string     `db:"message"`
+	CreatedAt  int64      `db:"created_at"`
+	Attributes Attributes `db:"attributes"`
+	// IdempotencyKey - optional key unique per tenant, a log with a saved
+	// key is not added again
+	IdempotencyKey string `db:"idempotency_key"`
+}
+
+// Clone returns copy of log not sharing id and attributes with it. Empty
+// attributes are nil in the copy, the way they are read from the database.
+func (l *Log) Clone() *Log {
+	c := *l
+	if l.Id != nil {
+		id := *l.Id
+		c.Id = &id
+	}
+	c.Attributes = nil
+	if len(l.Attributes) > 0 {
+		c.Attributes = maps.Clone(l.Attributes)
+	}
+	return &c
+}
+
+// Attributes - structured log fields stored as JSON object
+type Attributes map[string]string
+
+// Value implements driver.Valuer
+func (a Attributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(a))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attributes: %v", err)
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan attributes: unsupported type %T", src)
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("failed to unmarshal attributes: %v", err)
+	}
+	if len(m) == 0 {
+		m = nil
+	}
+	*a = m
+	return nil
 }
 
 func FromPbLog(l *pb.Log) *Log {
 	return &Log{
-		Id:        l.Id,
-		Source:    l.Source,
-		Level:     int32(l.Level),
-		Message:   l.Message,
-		CreatedAt: l.Timestamp,
+		Id:         l.Id,
+		Source:     l.Source,
+		Level:      int32(l.Level),
+		Message:    l.Message,
+		CreatedAt:  l.Timestamp,
+		Attributes: l.Attributes,
 	}
 }
 
 func (l *Log) ToPbLog() *pb.Log {
 	return &pb.Log{
-		Id:        l.Id,
-		Source:    l.Source,
-		Level:     pb.Level(l.Level),
-		Message:   l.Message,
-		Timestamp: l.CreatedAt,
+		Id:         l.Id,
+		Source:     l.Source,
+		Level:      pb.Level(l.Level),
+		Message:    l.Message,
+		Timestamp:  l.CreatedAt,
+		Attributes: l.Attributes,
 	}
 }
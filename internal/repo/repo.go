@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/lib/pq"
+
 	"logstream/internal/database"
+	"logstream/internal/tenant"
 )
 
 type repo struct {
@@ -22,13 +25,25 @@ type Repo interface {
 	GetLog(ctx context.Context, id int32) (*Log, error)
 
 	// GetLogs - get logs by filter
-	GetLogs(ctx context.Context, source string, level int32, startTime, endTime int64) ([]*Log, error)
+	GetLogs(ctx context.Context, filter Filter) ([]*Log, error)
+
+	// GetLogsPage - get page of logs by filter, empty after the last page
+	GetLogsPage(ctx context.Context, filter Filter, page Page) ([]*Log, error)
 
 	// AddLog - add log
 	AddLog(ctx context.Context, log *Log) (int32, error)
 
 	// AddLogs - add logs
 	AddLogs(ctx context.Context, logs []*Log) ([]int32, error)
+
+	// GetTenants - get tenants having logs
+	GetTenants(ctx context.Context) ([]string, error)
+
+	// GetSources - get sources having logs by name prefix, sorted by name
+	GetSources(ctx context.Context, prefix string) ([]*Source, error)
+
+	// DeleteLogs - delete logs created before the time
+	DeleteLogs(ctx context.Context, before int64) (int64, error)
 }
 
 func NewRepo(db *sql.DB) Repo {
@@ -45,8 +60,8 @@ func (r *repo) GetLog(ctx context.Context, id int32) (*Log, error) {
 	db := database.FromContext(ctx, r.db)
 
 	var log Log
-	query := "SELECT id, source, lvl, message, created_at FROM logs WHERE id = $1"
-	if err := db.QueryRowContext(ctx, query, id).Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt); err != nil {
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2"
+	if err := db.QueryRowContext(ctx, query, id, tenant.FromContext(ctx)).Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, database.ErrNotFound
 		}
@@ -56,15 +71,25 @@ func (r *repo) GetLog(ctx context.Context, id int32) (*Log, error) {
 	return &log, nil
 }
 
-func (r *repo) GetLogs(ctx context.Context, source string, level int32, startTime, endTime int64) ([]*Log, error) {
-	if level > 2 {
+func (r *repo) GetLogs(ctx context.Context, filter Filter) ([]*Log, error) {
+	if filter.Level > 2 {
 		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
 	}
 
 	db := database.FromContext(ctx, r.db)
 
-	query := "SELECT id, source, lvl, message, created_at FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4"
-	rows, err := db.QueryContext(ctx, query, source, level, startTime, endTime)
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5"
+	args := []any{filter.Source, filter.Level, filter.StartTime, filter.EndTime, tenant.FromContext(ctx)}
+	if words := Words(filter.Query); len(words) > 0 {
+		query += " AND " + messageWords + " @@ plainto_tsquery('simple', $6)"
+		args = append(args, strings.Join(words, " "))
+	}
+	query += orderBy(filter)
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+		args = append(args, filter.Limit)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, database.ErrNotFound
@@ -76,7 +101,7 @@ func (r *repo) GetLogs(ctx context.Context, source string, level int32, startTim
 	var logs []*Log
 	for rows.Next() {
 		var log Log
-		if err := rows.Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt); err != nil {
+		if err := rows.Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
 			//return nil, fmt.Errorf("failed to scan log: %v", err)
 			continue
 		}
@@ -94,6 +119,61 @@ func (r *repo) GetLogs(ctx context.Context, source string, level int32, startTim
 	return logs, nil
 }
 
+func (r *repo) GetLogsPage(ctx context.Context, filter Filter, page Page) ([]*Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5"
+	args := []any{filter.Source, filter.Level, filter.StartTime, filter.EndTime, tenant.FromContext(ctx)}
+	if words := Words(filter.Query); len(words) > 0 {
+		query += fmt.Sprintf(" AND "+messageWords+" @@ plainto_tsquery('simple', $%d)", len(args)+1)
+		args = append(args, strings.Join(words, " "))
+	}
+	if page.After != nil {
+		// the next page starts after the position in the filter order
+		op := ">"
+		if filter.Desc {
+			op = "<"
+		}
+		query += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", op, len(args)+1, len(args)+2)
+		args = append(args, page.After.CreatedAt, page.After.Id)
+	}
+	query += orderBy(filter) + fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, page.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer rows.Close()
+
+	var logs []*Log
+	for rows.Next() {
+		var log Log
+		if err := rows.Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to scan log: %v", err)
+		}
+		logs = append(logs, &log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return logs, nil
+}
+
+// orderBy returns ORDER BY clause of the filter order.
+func orderBy(filter Filter) string {
+	if filter.Desc {
+		return " ORDER BY created_at DESC, id DESC"
+	}
+	return " ORDER BY created_at, id"
+}
+
 func (r *repo) AddLog(ctx context.Context, log *Log) (int32, error) {
 	if log.Level > 2 {
 		return 0, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
@@ -102,8 +182,8 @@ func (r *repo) AddLog(ctx context.Context, log *Log) (int32, error) {
 	db := database.FromContext(ctx, r.db)
 
 	var id int32
-	query := "INSERT INTO logs (source, lvl, message, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
-	err := db.QueryRowContext(ctx, query, log.Source, log.Level, log.Message, log.CreatedAt).Scan(&id)
+	query := "INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))" + onKeyConflict
+	err := db.QueryRowContext(ctx, query, log.Source, log.Level, log.Message, log.CreatedAt, log.Attributes, tenant.FromContext(ctx), log.IdempotencyKey).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("failed to add log: %v", err)
 	}
@@ -118,32 +198,105 @@ func (r *repo) AddLogs(ctx context.Context, logs []*Log) ([]int32, error) {
 
 	db := database.FromContext(ctx, r.db)
 
-	query := "INSERT INTO logs (source, lvl, message, created_at) VALUES "
-	values := make([]interface{}, 0, len(logs)*4)
-	placeholders := make([]string, len(logs))
+	tenantID := tenant.FromContext(ctx)
+
+	// a statement may not update the same row twice on conflict, so logs
+	// with the same key are inserted once
+	rows, rowOf := uniqueKeys(logs)
+
+	var ids []int32
+	if len(rows) <= addLogsChunk {
+		var err error
+		if ids, err = insertLogs(ctx, db, tenantID, rows); err != nil {
+			return nil, fmt.Errorf("failed to add logs: %v", err)
+		}
+	} else {
+		// chunks are inserted in one transaction to save all logs or none
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start tx: %v", err)
+		}
+		defer tx.Rollback()
+
+		ids = make([]int32, 0, len(rows))
+		for start := 0; start < len(rows); start += addLogsChunk {
+			chunk, err := insertLogs(ctx, tx, tenantID, rows[start:min(start+addLogsChunk, len(rows))])
+			if err != nil {
+				return nil, fmt.Errorf("failed to add logs: %v", err)
+			}
+			ids = append(ids, chunk...)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit tx: %v", err)
+		}
+	}
+
+	if len(rows) == len(logs) {
+		return ids, nil
+	}
+	logIDs := make([]int32, len(logs))
+	for i := range logs {
+		logIDs[i] = ids[rowOf[i]]
+	}
+	return logIDs, nil
+}
+
+// messageWords splits message into words like Words does; the default
+// parser would keep emails, hosts and paths whole. It must match the
+// expression of logs_message_search_idx.
+const messageWords = "to_tsvector('simple', regexp_replace(lower(message), '[^[:alnum:]]+', ' ', 'g'))"
+
+// addLogsChunk - max logs inserted by one statement. Logs are passed as
+// arrays, so statements have the same parameters for any number of logs.
+const addLogsChunk = 10000
+
+// insertLogsQuery inserts logs unnested from arrays. Rows are inserted and
+// returned in order of the arrays.
+const insertLogsQuery = "INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) SELECT source, lvl, message, created_at, attributes, $7, NULLIF(idempotency_key, '') FROM unnest($1::text[], $2::smallint[], $3::text[], $4::bigint[], $5::jsonb[], $6::text[]) WITH ORDINALITY AS l (source, lvl, message, created_at, attributes, idempotency_key, n) ORDER BY n" + onKeyConflict
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// insertLogs inserts logs having unique idempotency keys by one statement
+// and returns their ids in order.
+func insertLogs(ctx context.Context, q querier, tenantID string, logs []*Log) ([]int32, error) {
+	sources := make([]string, len(logs))
+	levels := make([]int32, len(logs))
+	messages := make([]string, len(logs))
+	createdAt := make([]int64, len(logs))
+	attributes := make([]string, len(logs))
+	keys := make([]string, len(logs))
 	for i, log := range logs {
-		base := i * 4
-		placeholders[i] = fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4)
-		values = append(values, log.Source, log.Level, log.Message, log.CreatedAt)
+		attrs, err := log.Attributes.Value()
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = log.Source
+		levels[i] = log.Level
+		messages[i] = log.Message
+		createdAt[i] = log.CreatedAt
+		attributes[i] = attrs.(string)
+		keys[i] = log.IdempotencyKey
 	}
-	query += strings.Join(placeholders, ", ") + " RETURNING id"
 
-	rows, err := db.QueryContext(ctx, query, values...)
+	result, err := q.QueryContext(ctx, insertLogsQuery, pq.Array(sources), pq.Array(levels), pq.Array(messages), pq.Array(createdAt), pq.Array(attributes), pq.Array(keys), tenantID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add logs: %v", err)
+		return nil, err
 	}
-	defer rows.Close()
+	defer result.Close()
 
 	ids := make([]int32, 0, len(logs))
-	for rows.Next() {
+	for result.Next() {
 		var id int32
-		if err := rows.Scan(&id); err != nil {
+		if err := result.Scan(&id); err != nil {
 			return nil, fmt.Errorf("failed to scan id: %v", err)
 		}
 		ids = append(ids, id)
 	}
 
-	if err := rows.Err(); err != nil {
+	if err := result.Err(); err != nil {
 		return nil, fmt.Errorf("failed to iterate rows: %v", err)
 	}
 
@@ -153,3 +306,111 @@ func (r *repo) AddLogs(ctx context.Context, logs []*Log) ([]int32, error) {
 
 	return ids, nil
 }
+
+// onKeyConflict makes insert of a log with saved idempotency key return id
+// of the saved log. The update is a no-op needed for RETURNING to see the
+// row.
+const onKeyConflict = " ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id"
+
+// uniqueKeys returns logs without later logs having the same idempotency
+// key, and index of the returned log saving every log.
+func uniqueKeys(logs []*Log) ([]*Log, []int) {
+	unique := make([]*Log, 0, len(logs))
+	rowOf := make([]int, len(logs))
+	seen := make(map[string]int)
+	for i, log := range logs {
+		if log.IdempotencyKey != "" {
+			if row, ok := seen[log.IdempotencyKey]; ok {
+				rowOf[i] = row
+				continue
+			}
+			seen[log.IdempotencyKey] = len(unique)
+		}
+		rowOf[i] = len(unique)
+		unique = append(unique, log)
+	}
+	return unique, rowOf
+}
+
+func (r *repo) GetTenants(ctx context.Context) ([]string, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT DISTINCT tenant_id FROM logs"
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenants: %v", err)
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %v", err)
+		}
+		tenants = append(tenants, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return tenants, nil
+}
+
+// GetSources reads sources table maintained by triggers on logs, a row per
+// source and level.
+func (r *repo) GetSources(ctx context.Context, prefix string) ([]*Source, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT source, lvl, first_seen, last_seen, logs FROM sources WHERE tenant_id = $1 AND starts_with(source, $2) AND logs > 0 ORDER BY source, lvl"
+	rows, err := db.QueryContext(ctx, query, tenant.FromContext(ctx), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sources: %v", err)
+	}
+	defer rows.Close()
+
+	var sources []*Source
+	for rows.Next() {
+		var (
+			s     Source
+			level int
+			logs  int64
+		)
+		if err := rows.Scan(&s.Name, &level, &s.FirstSeen, &s.LastSeen, &logs); err != nil {
+			return nil, fmt.Errorf("failed to scan source: %v", err)
+		}
+		if level < 0 || level >= len(s.Logs) {
+			return nil, fmt.Errorf("invalid level %d of source %s", level, s.Name)
+		}
+		s.Logs[level] = logs
+
+		if n := len(sources); n > 0 && sources[n-1].Name == s.Name {
+			sources[n-1].Merge(&s)
+			continue
+		}
+		sources = append(sources, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return sources, nil
+}
+
+func (r *repo) DeleteLogs(ctx context.Context, before int64) (int64, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "DELETE FROM logs WHERE created_at < $1 AND tenant_id = $2"
+	res, err := db.ExecContext(ctx, query, before, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %v", err)
+	}
+
+	return n, nil
+}
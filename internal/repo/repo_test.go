@@ -3,12 +3,14 @@ package repo_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"reflect"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -60,10 +62,10 @@ func (s *Suite) TestGetLog() {
 			inputLogId: 1,
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE id = $1`)).
-					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at"}).
-						AddRow(1, "test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix()))
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+					WithArgs(1, "default").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(1, "test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}"))
 			},
 			expectedLog: &repo.Log{
 				Id:        func() *int32 { id := int32(1); return &id }(),
@@ -78,8 +80,8 @@ func (s *Suite) TestGetLog() {
 			inputLogId: 2,
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE id = $1`)).
-					WithArgs(2).
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+					WithArgs(2, "default").
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectedLog: nil,
@@ -112,6 +114,9 @@ func (s *Suite) TestGetLogs() {
 		inputLevel     int32
 		inputStartTime int64
 		inputEndTime   int64
+		inputQuery     string
+		inputDesc      bool
+		inputLimit     int
 		mockSetup      func(mock sqlmock.Sqlmock)
 		expectedLogs   []*repo.Log
 		expectedErr    string
@@ -124,11 +129,11 @@ func (s *Suite) TestGetLogs() {
 			inputEndTime:   1000000,
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4`)).
-					WithArgs("test-source", 1, 10000, 1000000).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at"}).
-						AddRow(1, "test-source", 1, "test message 1", 10000).
-						AddRow(2, "test-source", 1, "test message 2", 10001))
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(1, "test-source", 1, "test message 1", 10000, "{}").
+						AddRow(2, "test-source", 1, "test message 2", 10001, "{}"))
 			},
 			expectedLogs: []*repo.Log{
 				{
@@ -147,6 +152,55 @@ func (s *Suite) TestGetLogs() {
 				},
 			},
 		},
+		{
+			name:           "search message",
+			inputSource:    "test-source",
+			inputLevel:     2,
+			inputStartTime: 10000,
+			inputEndTime:   1000000,
+			inputQuery:     "Disk, full!",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 AND to_tsvector('simple', regexp_replace(lower(message), '[^[:alnum:]]+', ' ', 'g')) @@ plainto_tsquery('simple', $6)`)).
+					WithArgs("test-source", 2, 10000, 1000000, "default", "disk full").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(3, "test-source", 2, "full disk", 10000, "{}"))
+			},
+			expectedLogs: []*repo.Log{
+				{
+					Id:        func() *int32 { id := int32(3); return &id }(),
+					Source:    "test-source",
+					Level:     int32(pb.Level_LEVEL_ERROR),
+					Message:   "full disk",
+					CreatedAt: 10000,
+				},
+			},
+		},
+		{
+			name:           "newest logs",
+			inputSource:    "test-source",
+			inputLevel:     2,
+			inputStartTime: 10000,
+			inputEndTime:   1000000,
+			inputDesc:      true,
+			inputLimit:     100,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 ORDER BY created_at DESC, id DESC LIMIT $6`)).
+					WithArgs("test-source", 2, 10000, 1000000, "default", 100).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(3, "test-source", 2, "full disk", 10000, "{}"))
+			},
+			expectedLogs: []*repo.Log{
+				{
+					Id:        func() *int32 { id := int32(3); return &id }(),
+					Source:    "test-source",
+					Level:     int32(pb.Level_LEVEL_ERROR),
+					Message:   "full disk",
+					CreatedAt: 10000,
+				},
+			},
+		},
 		{
 			name:        "invalid log level",
 			inputLevel:  1000,
@@ -157,8 +211,8 @@ func (s *Suite) TestGetLogs() {
 			name: "logs not found",
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4`)).
-					WithArgs("", 0, 0, 0).
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5`)).
+					WithArgs("", 0, 0, 0, "default").
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectedErr: "record not found",
@@ -169,7 +223,15 @@ func (s *Suite) TestGetLogs() {
 		s.T().Run(tc.name, func(t *testing.T) {
 			tc.mockSetup(s.mock)
 
-			actualLogs, err := s.r.GetLogs(s.ctx, tc.inputSource, tc.inputLevel, tc.inputStartTime, tc.inputEndTime)
+			actualLogs, err := s.r.GetLogs(s.ctx, repo.Filter{
+				Source:    tc.inputSource,
+				Level:     tc.inputLevel,
+				StartTime: tc.inputStartTime,
+				EndTime:   tc.inputEndTime,
+				Query:     tc.inputQuery,
+				Desc:      tc.inputDesc,
+				Limit:     tc.inputLimit,
+			})
 
 			if tc.expectedErr == "" {
 				require.NoError(t, err)
@@ -183,6 +245,82 @@ func (s *Suite) TestGetLogs() {
 	}
 }
 
+func (s *Suite) TestGetLogsPage() {
+	testCases := []struct {
+		name        string
+		filter      repo.Filter
+		page        repo.Page
+		mockSetup   func(mock sqlmock.Sqlmock)
+		expectedIds []int32
+		expectedErr string
+	}{
+		{
+			name:   "first page",
+			filter: repo.Filter{Source: "test-source", Level: 1, StartTime: 10000, EndTime: 1000000},
+			page:   repo.Page{Limit: 2},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 ORDER BY created_at, id LIMIT $6`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(2, "test-source", 1, "test message 2", 10000, "{}").
+						AddRow(1, "test-source", 1, "test message 1", 10001, "{}"))
+			},
+			expectedIds: []int32{2, 1},
+		},
+		{
+			name:   "page after position with query",
+			filter: repo.Filter{Source: "test-source", Level: 1, StartTime: 10000, EndTime: 1000000, Query: "disk full"},
+			page:   repo.Page{After: &repo.Position{CreatedAt: 10001, Id: 1}, Limit: 2},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 AND to_tsvector('simple', regexp_replace(lower(message), '[^[:alnum:]]+', ' ', 'g')) @@ plainto_tsquery('simple', $6) AND (created_at, id) > ($7, $8) ORDER BY created_at, id LIMIT $9`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", "disk full", 10001, 1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}))
+			},
+		},
+		{
+			name:   "page of newest logs",
+			filter: repo.Filter{Source: "test-source", Level: 1, StartTime: 10000, EndTime: 1000000, Desc: true},
+			page:   repo.Page{After: &repo.Position{CreatedAt: 10001, Id: 1}, Limit: 2},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 AND (created_at, id) < ($6, $7) ORDER BY created_at DESC, id DESC LIMIT $8`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", 10001, 1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(2, "test-source", 1, "test message 2", 10000, "{}"))
+			},
+			expectedIds: []int32{2},
+		},
+		{
+			name:        "invalid log level",
+			filter:      repo.Filter{Level: 1000},
+			page:        repo.Page{Limit: 2},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: "invalid log level",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			logs, err := s.r.GetLogsPage(s.ctx, tc.filter, tc.page)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				var ids []int32
+				for _, l := range logs {
+					ids = append(ids, *l.Id)
+				}
+				assert.Equal(t, tc.expectedIds, ids)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
 func (s *Suite) TestAddLog() {
 	testCases := []struct {
 		name        string
@@ -201,12 +339,29 @@ func (s *Suite) TestAddLog() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`INSERT INTO logs (source, lvl, message, created_at) VALUES ($1, $2, $3, $4) RETURNING id`)).
-					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix()).
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
+					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}", "default", "").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 			},
 			expectedId: 1,
 		},
+		{
+			name: "add log with attributes",
+			inputLog: &repo.Log{
+				Source:     "test-source",
+				Level:      int32(pb.Level_LEVEL_INFO),
+				Message:    "test message",
+				CreatedAt:  time.Now().Unix(),
+				Attributes: repo.Attributes{"user_id": "42"},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
+					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), `{"user_id":"42"}`, "default", "").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+			},
+			expectedId: 2,
+		},
 		{
 			name: "invalid level",
 			inputLog: &repo.Log{
@@ -264,15 +419,80 @@ func (s *Suite) TestAddLogs() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`INSERT INTO logs (source, lvl, message, created_at) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8) RETURNING id`)).
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) SELECT source, lvl, message, created_at, attributes, $7, NULLIF(idempotency_key, '') FROM unnest($1::text[], $2::smallint[], $3::text[], $4::bigint[], $5::jsonb[], $6::text[]) WITH ORDINALITY AS l (source, lvl, message, created_at, attributes, idempotency_key, n) ORDER BY n ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
 					WithArgs(
-						"test-source-1", pb.Level_LEVEL_INFO, "test message 1", time.Now().Unix(),
-						"test-source-2", pb.Level_LEVEL_WARN, "test message 2", time.Now().Unix(),
+						pq.Array([]string{"test-source-1", "test-source-2"}),
+						pq.Array([]int32{int32(pb.Level_LEVEL_INFO), int32(pb.Level_LEVEL_WARN)}),
+						pq.Array([]string{"test message 1", "test message 2"}),
+						pq.Array([]int64{time.Now().Unix(), time.Now().Unix()}),
+						pq.Array([]string{"{}", "{}"}),
+						pq.Array([]string{"", ""}),
+						"default",
 					).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
 			},
 			expectedIds: []int32{1, 2},
 		},
+		{
+			name: "logs with the same idempotency key are inserted once",
+			inputLogs: []*repo.Log{
+				{
+					Source:         "test-source-1",
+					Level:          int32(pb.Level_LEVEL_INFO),
+					Message:        "test message 1",
+					CreatedAt:      time.Now().Unix(),
+					IdempotencyKey: "key-1",
+				},
+				{
+					Source:         "test-source-1",
+					Level:          int32(pb.Level_LEVEL_INFO),
+					Message:        "test message 1",
+					CreatedAt:      time.Now().Unix(),
+					IdempotencyKey: "key-1",
+				},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) SELECT`)).
+					WithArgs(
+						pq.Array([]string{"test-source-1"}),
+						pq.Array([]int32{int32(pb.Level_LEVEL_INFO)}),
+						pq.Array([]string{"test message 1"}),
+						pq.Array([]int64{time.Now().Unix()}),
+						pq.Array([]string{"{}"}),
+						pq.Array([]string{"key-1"}),
+						"default",
+					).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+			expectedIds: []int32{7, 7},
+		},
+		{
+			name:      "large batch is inserted by chunks in transaction",
+			inputLogs: manyLogs(10001),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO logs`)).
+					WillReturnRows(idRows(1, 10000))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO logs`)).
+					WillReturnRows(idRows(10001, 10001))
+				mock.ExpectCommit()
+			},
+			expectedIds: ids(1, 10001),
+		},
+		{
+			name:      "failed chunk rolls back transaction",
+			inputLogs: manyLogs(10001),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO logs`)).
+					WillReturnRows(idRows(1, 10000))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO logs`)).
+					WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			expectedErr: "connection reset",
+		},
 		{
 			name:        "add zero logs",
 			inputLogs:   []*repo.Log{},
@@ -290,7 +510,7 @@ func (s *Suite) TestAddLogs() {
 
 			if tc.expectedErr == "" {
 				require.NoError(t, err)
-				assert.ElementsMatch(t, tc.expectedIds, actualIds)
+				assert.Equal(t, tc.expectedIds, actualIds)
 			} else {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tc.expectedErr)
@@ -299,3 +519,99 @@ func (s *Suite) TestAddLogs() {
 		})
 	}
 }
+
+func (s *Suite) TestGetSources() {
+	query := regexp.QuoteMeta(`SELECT source, lvl, first_seen, last_seen, logs FROM sources WHERE tenant_id = $1 AND starts_with(source, $2) AND logs > 0 ORDER BY source, lvl`)
+	columns := []string{"source", "lvl", "first_seen", "last_seen", "logs"}
+
+	testCases := []struct {
+		name            string
+		prefix          string
+		mockSetup       func(mock sqlmock.Sqlmock)
+		expectedSources []*repo.Source
+		expectedErr     string
+	}{
+		{
+			name:   "levels are merged",
+			prefix: "api",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("default", "api").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("api", 0, 10000, 10005, 3).
+						AddRow("api", 2, 9000, 10001, 1).
+						AddRow("api-gateway", 1, 10002, 10002, 2))
+			},
+			expectedSources: []*repo.Source{
+				{Name: "api", FirstSeen: 9000, LastSeen: 10005, Logs: [3]int64{3, 0, 1}},
+				{Name: "api-gateway", FirstSeen: 10002, LastSeen: 10002, Logs: [3]int64{0, 2, 0}},
+			},
+		},
+		{
+			name: "no sources",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("default", "").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name: "invalid level",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("default", "").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("api", 5, 10000, 10000, 1))
+			},
+			expectedErr: "invalid level 5",
+		},
+		{
+			name: "query error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("default", "").
+					WillReturnError(errors.New("db error"))
+			},
+			expectedErr: "failed to get sources: db error",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			sources, err := s.r.GetSources(s.ctx, tc.prefix)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedSources, sources)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func manyLogs(n int) []*repo.Log {
+	logs := make([]*repo.Log, n)
+	for i := range logs {
+		logs[i] = &repo.Log{Source: "test-source", Message: "test message", CreatedAt: time.Now().Unix()}
+	}
+	return logs
+}
+
+func ids(from, to int32) []int32 {
+	var ids []int32
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func idRows(from, to int32) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id"})
+	for id := from; id <= to; id++ {
+		rows.AddRow(id)
+	}
+	return rows
+}
@@ -0,0 +1,126 @@
+package segment
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	"logstream/internal/repo"
+)
+
+var errCorrupted = errors.New("corrupted data")
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// log encodes log without tenant, attributes are sorted by key.
+func (e *encoder) log(l *repo.Log) {
+	e.uvarint(uint64(uint32(*l.Id)))
+	e.string(l.Source)
+	e.uvarint(uint64(l.Level))
+	e.string(l.Message)
+	e.varint(l.CreatedAt)
+
+	keys := make([]string, 0, len(l.Attributes))
+	for key := range l.Attributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	e.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		e.string(key)
+		e.string(l.Attributes[key])
+	}
+	e.string(l.IdempotencyKey)
+}
+
+// decoder reads values written by encoder. The first error is kept and
+// the following reads return zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < n {
+		d.err = errCorrupted
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+// count reads number of following items, each taking at least one byte.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = errCorrupted
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) log() *repo.Log {
+	id := int32(uint32(d.uvarint()))
+	l := &repo.Log{
+		Id:        &id,
+		Source:    d.string(),
+		Level:     int32(d.uvarint()),
+		Message:   d.string(),
+		CreatedAt: d.varint(),
+	}
+	if n := d.count(); n > 0 {
+		l.Attributes = make(repo.Attributes, n)
+		for range n {
+			key := d.string()
+			l.Attributes[key] = d.string()
+		}
+	}
+	l.IdempotencyKey = d.string()
+	return l
+}
@@ -0,0 +1,505 @@
+// Package segment implements a storage engine keeping logs in append-only
+// segment files instead of a database.
+//
+// Accepted logs are appended to a write-ahead log and kept in memory until
+// FlushLogs of them are collected. Then they are written to immutable
+// segments, one per tenant and time bucket, and a new WAL is started.
+// Segments hold blocks of compressed logs followed by an index with time
+// and id ranges of every block and blocks of every source, so queries read
+// only blocks that may match.
+//
+// Segments and WAL files carry a generation: segments written by a flush
+// get generation of the WAL they come from, and the WAL is removed only
+// after the segments are synced. On open, segments of the generation of
+// the remaining WAL are leftovers of an interrupted flush and are removed,
+// and the WAL is replayed.
+//
+// Idempotency keys are checked against logs of the head and of the last
+// KeyFlushes flushes only, so memory used by keys does not grow with
+// stored logs. A log retried after that is saved again.
+package segment
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"logstream/internal/repo"
+)
+
+const (
+	defaultBucket     = time.Hour
+	defaultFlushLogs  = 10000
+	defaultKeyFlushes = 10
+)
+
+// Options configures Engine.
+type Options struct {
+	// Bucket - time span of logs in one segment, 1h by default
+	Bucket time.Duration
+	// FlushLogs - number of logs kept in WAL and memory before they are
+	// written to segments, 10000 by default
+	FlushLogs int
+	// KeyFlushes - number of the last flushes whose idempotency keys are
+	// kept in memory besides keys of the head, 10 by default
+	KeyFlushes int
+}
+
+type entry struct {
+	tenant string
+	log    *repo.Log
+}
+
+// Engine implements repo.Repo on top of segment files in a directory.
+// Queries hold a read lock while reading segments, so writes wait for
+// running queries.
+type Engine struct {
+	dir     string
+	opts    Options
+	release func() error
+
+	mu     sync.RWMutex
+	lastID int32
+	wal    *wal
+	// head - logs of the WAL not yet written to segments, in id order
+	head []*entry
+	// segments - segments of every tenant
+	segments map[string][]*segment
+	// keys - ids of logs having idempotency key of every tenant, only of
+	// the head and segments of the last KeyFlushes generations
+	keys map[string]map[string]int32
+	// err - failed write of the WAL, the engine rejects writes after it
+	err error
+}
+
+// Open opens engine in dir, creating it if needed, and recovers logs of
+// the WAL. Only one engine may use dir at a time.
+func Open(dir string, opts Options) (*Engine, error) {
+	if opts.Bucket <= 0 {
+		opts.Bucket = defaultBucket
+	}
+	if opts.FlushLogs <= 0 {
+		opts.FlushLogs = defaultFlushLogs
+	}
+	if opts.KeyFlushes <= 0 {
+		opts.KeyFlushes = defaultKeyFlushes
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "segments"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dir: %v", err)
+	}
+	release, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		dir:      dir,
+		opts:     opts,
+		release:  release,
+		segments: make(map[string][]*segment),
+		keys:     make(map[string]map[string]int32),
+	}
+	if err := e.recover(); err != nil {
+		release()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Engine) recover() error {
+	walGens, err := listWALs(e.dir)
+	if err != nil {
+		return err
+	}
+	// segments of the last WAL generation were not completely written
+	unfinished := uint64(0)
+	if len(walGens) > 0 {
+		unfinished = walGens[len(walGens)-1]
+	}
+
+	lastGen, err := e.loadSegments(unfinished)
+	if err != nil {
+		return err
+	}
+
+	if len(walGens) == 0 {
+		e.wal, err = createWAL(e.dir, lastGen+1)
+		return err
+	}
+
+	// older WALs are left by a crash after the next WAL was created, their
+	// segments are complete
+	for _, gen := range walGens[:len(walGens)-1] {
+		if err := os.Remove(walPath(e.dir, gen)); err != nil {
+			return fmt.Errorf("failed to remove wal: %v", err)
+		}
+	}
+
+	e.wal, err = openWAL(e.dir, unfinished, e.replay)
+	if err != nil {
+		return err
+	}
+	if len(e.head) > 0 {
+		slog.Info("logs recovered from wal", slog.String("dir", e.dir), slog.Int("logs", len(e.head)))
+	}
+	return nil
+}
+
+// listWALs returns generations of WAL files in ascending order.
+func listWALs(dir string) ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	var gens []uint64
+	for _, name := range names {
+		gen, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "wal-"), ".log"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected wal file %s", name)
+		}
+		gens = append(gens, gen)
+	}
+	// names are zero padded, so they are already sorted
+	return gens, nil
+}
+
+// loadSegments reads indexes of segments and removes segments of
+// unfinished generation and temporary files. Idempotency keys are read
+// only from segments of the last KeyFlushes generations. It returns the
+// latest generation of segments.
+func (e *Engine) loadSegments(unfinished uint64) (uint64, error) {
+	tenantDirs, err := os.ReadDir(filepath.Join(e.dir, "segments"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read segments dir: %v", err)
+	}
+
+	type segmentFile struct {
+		path string
+		gen  uint64
+	}
+	var (
+		segmentFiles []segmentFile
+		lastGen      uint64
+	)
+	for _, tenantDir := range tenantDirs {
+		if !tenantDir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(e.dir, "segments", tenantDir.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read segments dir: %v", err)
+		}
+
+		for _, file := range files {
+			path := filepath.Join(dir, file.Name())
+			gen, ok := parseSegmentName(file.Name())
+			if !ok || (unfinished > 0 && gen >= unfinished) {
+				if err := os.Remove(path); err != nil {
+					return 0, fmt.Errorf("failed to remove unfinished segment: %v", err)
+				}
+				continue
+			}
+
+			segmentFiles = append(segmentFiles, segmentFile{path: path, gen: gen})
+			lastGen = max(lastGen, gen)
+		}
+	}
+
+	// generation of the WAL after recovery
+	current := lastGen + 1
+	if unfinished > 0 {
+		current = unfinished
+	}
+	for _, f := range segmentFiles {
+		s, err := readSegment(f.path, f.gen, f.gen+uint64(e.opts.KeyFlushes) >= current)
+		if err != nil {
+			return 0, err
+		}
+		e.segments[s.tenant] = append(e.segments[s.tenant], s)
+		for key, id := range s.keys {
+			e.setKey(s.tenant, key, id)
+		}
+		e.lastID = max(e.lastID, s.maxID)
+	}
+	return lastGen, nil
+}
+
+// replay applies WAL record.
+func (e *Engine) replay(payload []byte) error {
+	if len(payload) == 0 {
+		return errCorrupted
+	}
+	dec := decoder{buf: payload[1:]}
+	tenantID := dec.string()
+
+	switch payload[0] {
+	case recordAdd:
+		n := dec.count()
+		for range n {
+			l := dec.log()
+			if dec.err != nil {
+				break
+			}
+			e.head = append(e.head, &entry{tenant: tenantID, log: l})
+			e.setKey(tenantID, l.IdempotencyKey, *l.Id)
+			e.lastID = max(e.lastID, *l.Id)
+		}
+	case recordDelete:
+		before := dec.varint()
+		if dec.err == nil {
+			if _, err := e.delete(tenantID, before); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown record type %d", payload[0])
+	}
+	return dec.err
+}
+
+// Err returns error of the last failed WAL write. The engine does not
+// accept logs after it and must be reopened.
+func (e *Engine) Err() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.err
+}
+
+// Close writes logs of the WAL to segments and releases the directory.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	if e.err == nil {
+		// not flushed logs are recovered from the WAL on open anyway
+		if err := e.flush(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush segments: %v", err))
+		}
+	}
+	errs = append(errs, e.wal.close(), e.release())
+	return errors.Join(errs...)
+}
+
+type bucketKey struct {
+	tenant string
+	bucket int64
+}
+
+// bucket returns start of the time bucket of t.
+func (e *Engine) bucket(t int64) int64 {
+	size := int64(e.opts.Bucket / time.Second)
+	if size <= 0 {
+		size = 1
+	}
+	b := t - t%size
+	if t < 0 && t%size != 0 {
+		b -= size
+	}
+	return b
+}
+
+// flush writes logs of the WAL to segments and starts a new WAL. e.mu
+// must be held.
+func (e *Engine) flush() error {
+	if len(e.head) == 0 {
+		return nil
+	}
+	gen := e.wal.gen
+
+	groups := make(map[bucketKey][]*repo.Log)
+	var keys []bucketKey
+	for _, en := range e.head {
+		key := bucketKey{tenant: en.tenant, bucket: e.bucket(en.log.CreatedAt)}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], en.log)
+	}
+
+	var written []*segment
+	dirs := make(map[string]bool)
+	cleanup := func(err error) error {
+		// a retry may produce other buckets, stale files must not survive it
+		for _, s := range written {
+			os.Remove(s.path)
+		}
+		return err
+	}
+	for _, key := range keys {
+		dir := segmentsDir(e.dir, key.tenant)
+		if !dirs[dir] {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return cleanup(fmt.Errorf("failed to create segments dir: %v", err))
+			}
+			dirs[dir] = true
+		}
+		s, err := writeSegment(filepath.Join(dir, segmentName(key.bucket, gen)), key.tenant, gen, groups[key])
+		if err != nil {
+			return cleanup(err)
+		}
+		written = append(written, s)
+	}
+	dirs[filepath.Join(e.dir, "segments")] = true
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return cleanup(err)
+		}
+	}
+
+	// segments are durable, the WAL may be replaced
+	next, err := createWAL(e.dir, gen+1)
+	if err != nil {
+		return cleanup(err)
+	}
+	prev := e.wal
+	e.wal = next
+	for _, s := range written {
+		e.segments[s.tenant] = append(e.segments[s.tenant], s)
+	}
+	e.head = nil
+	e.expireKeys()
+
+	if err := prev.close(); err != nil {
+		slog.Warn("failed to close wal", slog.Any("error", err))
+	}
+	// a remaining WAL is removed on open
+	if err := os.Remove(walPath(e.dir, gen)); err != nil {
+		slog.Warn("failed to remove wal", slog.Any("error", err))
+	}
+	return nil
+}
+
+// delete removes logs of tenant created before the time from segments and
+// the head. e.mu must be held.
+func (e *Engine) delete(tenantID string, before int64) (int64, error) {
+	var n int64
+
+	segments := e.segments[tenantID]
+	kept := make([]*segment, 0, len(segments))
+	for i, s := range segments {
+		switch {
+		case s.minTime >= before:
+			kept = append(kept, s)
+		case s.maxTime < before:
+			if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				e.segments[tenantID] = append(kept, segments[i:]...)
+				return n, fmt.Errorf("failed to remove segment: %v", err)
+			}
+			for key, id := range s.keys {
+				e.deleteKey(tenantID, key, id)
+			}
+			n += int64(s.count)
+		default:
+			logs, err := s.readLogs(nil)
+			if err != nil {
+				e.segments[tenantID] = append(kept, segments[i:]...)
+				return n, err
+			}
+			rest := logs[:0]
+			var deleted []*repo.Log
+			for _, l := range logs {
+				if l.CreatedAt >= before {
+					rest = append(rest, l)
+				} else if l.IdempotencyKey != "" {
+					deleted = append(deleted, l)
+				}
+			}
+			if len(rest) == 0 {
+				if err := os.Remove(s.path); err != nil {
+					e.segments[tenantID] = append(kept, segments[i:]...)
+					return n, fmt.Errorf("failed to remove segment: %v", err)
+				}
+			} else {
+				rewritten, err := writeSegment(s.path, tenantID, s.gen, rest)
+				if err != nil {
+					e.segments[tenantID] = append(kept, segments[i:]...)
+					return n, err
+				}
+				if s.keys == nil {
+					// keys of the segment are expired already
+					rewritten.keys = nil
+				}
+				kept = append(kept, rewritten)
+			}
+			for _, l := range deleted {
+				e.deleteKey(tenantID, l.IdempotencyKey, *l.Id)
+			}
+			n += int64(len(logs) - len(rest))
+		}
+	}
+	if len(kept) > 0 {
+		e.segments[tenantID] = kept
+	} else {
+		delete(e.segments, tenantID)
+	}
+	if len(segments) > 0 {
+		if err := syncDir(segmentsDir(e.dir, tenantID)); err != nil {
+			return n, err
+		}
+	}
+
+	head := e.head[:0]
+	for _, en := range e.head {
+		if en.tenant == tenantID && en.log.CreatedAt < before {
+			e.deleteKey(tenantID, en.log.IdempotencyKey, *en.log.Id)
+			n++
+			continue
+		}
+		head = append(head, en)
+	}
+	clear(e.head[len(head):])
+	e.head = head
+
+	return n, nil
+}
+
+// expireKeys forgets idempotency keys of segments older than the last
+// KeyFlushes generations before the WAL. e.mu must be held.
+func (e *Engine) expireKeys() {
+	for tenantID, segments := range e.segments {
+		for _, s := range segments {
+			if s.keys == nil || s.gen+uint64(e.opts.KeyFlushes) >= e.wal.gen {
+				continue
+			}
+			for key, id := range s.keys {
+				e.deleteKey(tenantID, key, id)
+			}
+			s.keys = nil
+		}
+	}
+}
+
+// setKey remembers id of log having idempotency key. e.mu must be held.
+func (e *Engine) setKey(tenantID, key string, id int32) {
+	if key == "" {
+		return
+	}
+	keys := e.keys[tenantID]
+	if keys == nil {
+		keys = make(map[string]int32)
+		e.keys[tenantID] = keys
+	}
+	keys[key] = id
+}
+
+// deleteKey forgets idempotency key of deleted log with id. The key is kept
+// if it was saved again with a later log after it expired. e.mu must be
+// held.
+func (e *Engine) deleteKey(tenantID, key string, id int32) {
+	if keys := e.keys[tenantID]; keys != nil && keys[key] == id {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(e.keys, tenantID)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+// keysRepo keeps API keys of every tenant in a JSON file, rewritten on
+// every change. Keys are few and rarely change, so they do not need
+// segments.
+type keysRepo struct {
+	path string
+
+	mu     sync.RWMutex
+	lastID int32
+	// keys - keys of every tenant in id order
+	keys []*repo.APIKey
+}
+
+// NewKeysRepo opens API keys kept in dir.
+func NewKeysRepo(dir string) (repo.KeysRepo, error) {
+	r := &keysRepo{path: filepath.Join(dir, "keys.json")}
+
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %v", err)
+	}
+	if err := json.Unmarshal(data, &r.keys); err != nil {
+		return nil, fmt.Errorf("failed to parse keys: %v", err)
+	}
+	for _, key := range r.keys {
+		r.lastID = max(r.lastID, key.Id)
+	}
+	return r, nil
+}
+
+// save writes keys to the file. r.mu must be held.
+func (r *keysRepo) save(keys []*repo.APIKey) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode keys: %v", err)
+	}
+	if err := writeFile(r.path, data); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(r.path))
+}
+
+func (r *keysRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*repo.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			k := *key
+			return &k, nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+func (r *keysRepo) GetAPIKeys(ctx context.Context) ([]*repo.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	var keys []*repo.APIKey
+	for _, key := range r.keys {
+		if key.Tenant == tenantID {
+			k := *key
+			keys = append(keys, &k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *keysRepo) AddAPIKey(ctx context.Context, key *repo.APIKey) (int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if (k.Tenant == key.Tenant && k.Name == key.Name) || k.Hash == key.Hash {
+			return 0, database.ErrPKeyConflict
+		}
+	}
+
+	k := *key
+	k.Id = r.lastID + 1
+	k.RevokedAt = 0
+	keys := append(r.keys[:len(r.keys):len(r.keys)], &k)
+	if err := r.save(keys); err != nil {
+		return 0, fmt.Errorf("failed to add api key: %v", err)
+	}
+	r.keys = keys
+	r.lastID = k.Id
+
+	return k.Id, nil
+}
+
+func (r *keysRepo) RevokeAPIKey(ctx context.Context, id int32, revokedAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := tenant.FromContext(ctx)
+	for i, key := range r.keys {
+		if key.Id == id && key.RevokedAt == 0 && key.Tenant == tenantID {
+			k := *key
+			k.RevokedAt = revokedAt
+			keys := append([]*repo.APIKey(nil), r.keys...)
+			keys[i] = &k
+			if err := r.save(keys); err != nil {
+				return fmt.Errorf("failed to revoke api key: %v", err)
+			}
+			r.keys = keys
+			return nil
+		}
+	}
+
+	return database.ErrNotFound
+}
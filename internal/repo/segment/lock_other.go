@@ -0,0 +1,9 @@
+//go:build !unix
+
+package segment
+
+// File locks are not available, so the directory is not protected from
+// another server.
+func lockDir(dir string) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,361 @@
+package segment
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+// RunInTx runs f. Changes made by f are not rolled back on error.
+func (e *Engine) RunInTx(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := f(ctx); err != nil {
+		return fmt.Errorf("failed to invoke func: %v", err)
+	}
+	return nil
+}
+
+func (e *Engine) GetLog(ctx context.Context, id int32) (*repo.Log, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	i := sort.Search(len(e.head), func(i int) bool { return *e.head[i].log.Id >= id })
+	if i < len(e.head) && *e.head[i].log.Id == id {
+		if e.head[i].tenant != tenantID {
+			return nil, database.ErrNotFound
+		}
+		return e.head[i].log.Clone(), nil
+	}
+
+	for _, s := range e.segments[tenantID] {
+		if id < s.minID || id > s.maxID {
+			continue
+		}
+		var blocks []int
+		for i, b := range s.blocks {
+			if id >= b.minID && id <= b.maxID {
+				blocks = append(blocks, i)
+			}
+		}
+		logs, err := s.readLogs(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get log: %v", err)
+		}
+		for _, l := range logs {
+			if *l.Id == id {
+				return l, nil
+			}
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+func (e *Engine) GetLogs(ctx context.Context, filter repo.Filter) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	if filter.Limit > 0 {
+		// the first logs are read like a page, without reading every block
+		logs, err := e.GetLogsPage(ctx, filter, repo.Page{Limit: filter.Limit})
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) == 0 {
+			return nil, database.ErrNotFound
+		}
+		return logs, nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	var logs []*repo.Log
+	for _, s := range e.segments[tenantID] {
+		if s.maxTime < filter.StartTime || s.minTime > filter.EndTime {
+			continue
+		}
+		var blocks []int
+		for _, i := range s.sources[filter.Source] {
+			if b := s.blocks[i]; b.maxTime >= filter.StartTime && b.minTime <= filter.EndTime {
+				blocks = append(blocks, i)
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		segmentLogs, err := s.readLogs(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs: %v", err)
+		}
+		for _, l := range segmentLogs {
+			if filter.Match(l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+
+	for _, en := range e.head {
+		if en.tenant == tenantID && filter.Match(en.log) {
+			logs = append(logs, en.log.Clone())
+		}
+	}
+
+	if len(logs) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	return filter.Sort(logs), nil
+}
+
+// GetLogsPage reads blocks in the filter order until the following blocks
+// may only hold logs after a full page.
+func (e *Engine) GetLogsPage(ctx context.Context, filter repo.Filter, page repo.Page) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+	if page.Limit <= 0 {
+		return nil, nil
+	}
+	if page.After != nil && filter.Desc {
+		filter.EndTime = min(filter.EndTime, page.After.CreatedAt)
+	} else if page.After != nil {
+		filter.StartTime = max(filter.StartTime, page.After.CreatedAt)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	type blockRef struct {
+		s *segment
+		i int
+	}
+	var blocks []blockRef
+	for _, s := range e.segments[tenantID] {
+		if s.maxTime < filter.StartTime || s.minTime > filter.EndTime {
+			continue
+		}
+		for _, i := range s.sources[filter.Source] {
+			if b := s.blocks[i]; b.maxTime >= filter.StartTime && b.minTime <= filter.EndTime {
+				blocks = append(blocks, blockRef{s: s, i: i})
+			}
+		}
+	}
+	// blocks are read starting with the first logs in the filter order
+	slices.SortFunc(blocks, func(a, b blockRef) int {
+		if filter.Desc {
+			return cmp.Compare(b.s.blocks[b.i].maxTime, a.s.blocks[a.i].maxTime)
+		}
+		return cmp.Compare(a.s.blocks[a.i].minTime, b.s.blocks[b.i].minTime)
+	})
+
+	var logs []*repo.Log
+	for _, en := range e.head {
+		if en.tenant == tenantID && filter.Match(en.log) && page.Match(&filter, en.log) {
+			logs = append(logs, en.log.Clone())
+		}
+	}
+
+	for _, b := range blocks {
+		if len(logs) >= page.Limit {
+			logs = repo.Paginate(logs, &filter, page)
+			last := logs[len(logs)-1].CreatedAt
+			if block := b.s.blocks[b.i]; filter.Desc && block.maxTime < last || !filter.Desc && block.minTime > last {
+				break
+			}
+		}
+
+		blockLogs, err := b.s.readLogs([]int{b.i})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs: %v", err)
+		}
+		for _, l := range blockLogs {
+			if filter.Match(l) && page.Match(&filter, l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+
+	return repo.Paginate(logs, &filter, page), nil
+}
+
+func (e *Engine) AddLog(ctx context.Context, log *repo.Log) (int32, error) {
+	if log.Level > 2 {
+		return 0, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	ids, err := e.add(tenant.FromContext(ctx), []*repo.Log{log})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add log: %v", err)
+	}
+	return ids[0], nil
+}
+
+func (e *Engine) AddLogs(ctx context.Context, logs []*repo.Log) ([]int32, error) {
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no logs to add")
+	}
+
+	ids, err := e.add(tenant.FromContext(ctx), logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add logs: %v", err)
+	}
+	return ids, nil
+}
+
+// add appends logs to the WAL and flushes them to segments once there are
+// enough of them. Logs with saved idempotency keys are not added again.
+func (e *Engine) add(tenantID string, logs []*repo.Log) ([]int32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	ids := make([]int32, len(logs))
+	entries := make([]*entry, 0, len(logs))
+	// keys - idempotency keys added by the batch
+	keys := make(map[string]int32)
+	lastID := e.lastID
+	for i, log := range logs {
+		if key := log.IdempotencyKey; key != "" {
+			id, ok := e.keys[tenantID][key]
+			if !ok {
+				id, ok = keys[key]
+			}
+			if ok {
+				ids[i] = id
+				continue
+			}
+		}
+
+		l := log.Clone()
+		lastID++
+		id := lastID
+		l.Id = &id
+		entries = append(entries, &entry{tenant: tenantID, log: l})
+		if l.IdempotencyKey != "" {
+			keys[l.IdempotencyKey] = id
+		}
+		ids[i] = id
+	}
+	if len(entries) == 0 {
+		return ids, nil
+	}
+
+	enc := encoder{buf: []byte{recordAdd}}
+	enc.string(tenantID)
+	enc.uvarint(uint64(len(entries)))
+	for _, en := range entries {
+		enc.log(en.log)
+	}
+	if err := e.wal.append(enc.buf); err != nil {
+		// the WAL may end with a partial record now, appending after it
+		// would lose the following records on replay
+		e.err = err
+		return nil, err
+	}
+	e.lastID = lastID
+	e.head = append(e.head, entries...)
+	for key, id := range keys {
+		e.setKey(tenantID, key, id)
+	}
+
+	if len(e.head) >= e.opts.FlushLogs {
+		// logs are durable in the WAL, the flush is retried with the next ones
+		if err := e.flush(); err != nil {
+			slog.Error("failed to flush segments", slog.String("dir", e.dir), slog.Any("error", err))
+		}
+	}
+	return ids, nil
+}
+
+func (e *Engine) GetTenants(ctx context.Context) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var tenants []string
+	for tenantID := range e.segments {
+		tenants = append(tenants, tenantID)
+	}
+	for _, en := range e.head {
+		if !slices.Contains(tenants, en.tenant) {
+			tenants = append(tenants, en.tenant)
+		}
+	}
+	slices.Sort(tenants)
+
+	return tenants, nil
+}
+
+// GetSources merges stats of segments of the tenant with logs of the head.
+// Segments are rewritten on delete, so the stats are exact.
+func (e *Engine) GetSources(ctx context.Context, prefix string) ([]*repo.Source, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	tenantID := tenant.FromContext(ctx)
+
+	sources := make(map[string]*repo.Source)
+	source := func(name string) *repo.Source {
+		s, ok := sources[name]
+		if !ok {
+			s = &repo.Source{Name: name}
+			sources[name] = s
+		}
+		return s
+	}
+	for _, s := range e.segments[tenantID] {
+		for name, stats := range s.stats {
+			source(name).Merge(stats)
+		}
+	}
+	for _, en := range e.head {
+		if en.tenant == tenantID {
+			source(en.log.Source).Add(en.log)
+		}
+	}
+
+	return repo.SortSources(sources, prefix), nil
+}
+
+func (e *Engine) DeleteLogs(ctx context.Context, before int64) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	tenantID := tenant.FromContext(ctx)
+
+	// logs of the WAL are deleted on replay too
+	enc := encoder{buf: []byte{recordDelete}}
+	enc.string(tenantID)
+	enc.varint(before)
+	if err := e.wal.append(enc.buf); err != nil {
+		e.err = err
+		return 0, fmt.Errorf("failed to delete logs: %v", err)
+	}
+
+	n, err := e.delete(tenantID, before)
+	if err != nil {
+		return n, fmt.Errorf("failed to delete logs: %v", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,400 @@
+package segment
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"logstream/internal/repo"
+)
+
+const (
+	segmentMagic = "LSEG"
+	// footerSize - index offset, index CRC32 and magic
+	footerSize = 16
+	// blockLogs - max number of logs compressed together
+	blockLogs = 1024
+)
+
+// block - compressed logs of a segment in id order
+type block struct {
+	offset  int64
+	size    int64
+	crc     uint32
+	count   int
+	minID   int32
+	maxID   int32
+	minTime int64
+	maxTime int64
+}
+
+// segment - immutable file holding logs of one tenant created within one
+// time bucket. Blocks are followed by the index, holding ranges of blocks,
+// blocks and stats of every source and idempotency keys, and the footer:
+//
+//	magic | block... | index | index offset | index crc | magic
+type segment struct {
+	path   string
+	tenant string
+	gen    uint64
+	blocks []block
+	// sources - blocks holding logs of every source
+	sources map[string][]int
+	// stats - times and counts of logs of every source
+	stats map[string]*repo.Source
+	// keys - ids of logs having idempotency key, nil once they are
+	// expired
+	keys map[string]int32
+
+	count   int
+	minID   int32
+	maxID   int32
+	minTime int64
+	maxTime int64
+}
+
+func segmentName(bucket int64, gen uint64) string {
+	return fmt.Sprintf("%d-%016d.seg", bucket, gen)
+}
+
+// parseSegmentName returns generation of segment file name.
+func parseSegmentName(name string) (uint64, bool) {
+	name, ok := strings.CutSuffix(name, ".seg")
+	if !ok {
+		return 0, false
+	}
+	i := strings.LastIndexByte(name, '-')
+	if i <= 0 {
+		return 0, false
+	}
+	if _, err := strconv.ParseInt(name[:i], 10, 64); err != nil {
+		return 0, false
+	}
+	gen, err := strconv.ParseUint(name[i+1:], 10, 64)
+	return gen, err == nil
+}
+
+// writeSegment writes logs sorted by id to a new file at path. The file is
+// written aside and renamed, so readers never see it partially written.
+func writeSegment(path, tenant string, gen uint64, logs []*repo.Log) (*segment, error) {
+	s := &segment{
+		path:    path,
+		tenant:  tenant,
+		gen:     gen,
+		sources: make(map[string][]int),
+		stats:   make(map[string]*repo.Source),
+		keys:    make(map[string]int32),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(segmentMagic)
+
+	zw, err := flate.NewWriter(nil, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(logs); start += blockLogs {
+		chunk := logs[start:min(start+blockLogs, len(logs))]
+
+		b := block{
+			offset:  int64(buf.Len()),
+			count:   len(chunk),
+			minID:   math.MaxInt32,
+			maxID:   math.MinInt32,
+			minTime: math.MaxInt64,
+			maxTime: math.MinInt64,
+		}
+		var enc encoder
+		enc.uvarint(uint64(len(chunk)))
+		for _, l := range chunk {
+			enc.log(l)
+			b.minID = min(b.minID, *l.Id)
+			b.maxID = max(b.maxID, *l.Id)
+			b.minTime = min(b.minTime, l.CreatedAt)
+			b.maxTime = max(b.maxTime, l.CreatedAt)
+			if blocks := s.sources[l.Source]; len(blocks) == 0 || blocks[len(blocks)-1] != len(s.blocks) {
+				s.sources[l.Source] = append(blocks, len(s.blocks))
+			}
+			stats, ok := s.stats[l.Source]
+			if !ok {
+				stats = &repo.Source{Name: l.Source}
+				s.stats[l.Source] = stats
+			}
+			stats.Add(l)
+			if l.IdempotencyKey != "" {
+				s.keys[l.IdempotencyKey] = *l.Id
+			}
+		}
+
+		zw.Reset(&buf)
+		if _, err := zw.Write(enc.buf); err != nil {
+			return nil, fmt.Errorf("failed to compress block: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress block: %v", err)
+		}
+		b.size = int64(buf.Len()) - b.offset
+		b.crc = crc32.ChecksumIEEE(buf.Bytes()[b.offset:])
+		s.blocks = append(s.blocks, b)
+	}
+	s.summarize()
+
+	indexOffset := buf.Len()
+	buf.Write(s.encodeIndex())
+	footer := make([]byte, footerSize)
+	binary.LittleEndian.PutUint64(footer, uint64(indexOffset))
+	binary.LittleEndian.PutUint32(footer[8:], crc32.ChecksumIEEE(buf.Bytes()[indexOffset:]))
+	copy(footer[12:], segmentMagic)
+	buf.Write(footer)
+
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeFile durably replaces file at path with data.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename file: %v", err)
+	}
+	return nil
+}
+
+func (s *segment) summarize() {
+	s.count = 0
+	s.minID, s.maxID = math.MaxInt32, math.MinInt32
+	s.minTime, s.maxTime = math.MaxInt64, math.MinInt64
+	for _, b := range s.blocks {
+		s.count += b.count
+		s.minID = min(s.minID, b.minID)
+		s.maxID = max(s.maxID, b.maxID)
+		s.minTime = min(s.minTime, b.minTime)
+		s.maxTime = max(s.maxTime, b.maxTime)
+	}
+}
+
+func (s *segment) encodeIndex() []byte {
+	var enc encoder
+	enc.string(s.tenant)
+
+	enc.uvarint(uint64(len(s.blocks)))
+	for _, b := range s.blocks {
+		enc.uvarint(uint64(b.offset))
+		enc.uvarint(uint64(b.size))
+		enc.uvarint(uint64(b.crc))
+		enc.uvarint(uint64(b.count))
+		enc.uvarint(uint64(uint32(b.minID)))
+		enc.uvarint(uint64(uint32(b.maxID)))
+		enc.varint(b.minTime)
+		enc.varint(b.maxTime)
+	}
+
+	sources := make([]string, 0, len(s.sources))
+	for source := range s.sources {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	enc.uvarint(uint64(len(sources)))
+	for _, source := range sources {
+		enc.string(source)
+		blocks := s.sources[source]
+		enc.uvarint(uint64(len(blocks)))
+		for _, i := range blocks {
+			enc.uvarint(uint64(i))
+		}
+		stats := s.stats[source]
+		enc.varint(stats.FirstSeen)
+		enc.varint(stats.LastSeen)
+		for _, n := range stats.Logs {
+			enc.uvarint(uint64(n))
+		}
+	}
+
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	enc.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		enc.string(key)
+		enc.uvarint(uint64(uint32(s.keys[key])))
+	}
+	return enc.buf
+}
+
+// readSegment reads index of segment file at path. Idempotency keys are
+// read only if withKeys is set.
+func readSegment(path string, gen uint64, withKeys bool) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat segment: %v", err)
+	}
+	if info.Size() < int64(len(segmentMagic)+footerSize) {
+		return nil, fmt.Errorf("segment %s: %v", path, errCorrupted)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := f.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return nil, fmt.Errorf("failed to read segment: %v", err)
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(footer))
+	if string(footer[12:]) != segmentMagic || indexOffset < int64(len(segmentMagic)) || indexOffset > info.Size()-footerSize {
+		return nil, fmt.Errorf("segment %s: %v", path, errCorrupted)
+	}
+	index := make([]byte, info.Size()-footerSize-indexOffset)
+	if _, err := f.ReadAt(index, indexOffset); err != nil {
+		return nil, fmt.Errorf("failed to read segment: %v", err)
+	}
+	if crc32.ChecksumIEEE(index) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, fmt.Errorf("segment %s: %v", path, errCorrupted)
+	}
+
+	s := &segment{
+		path:    path,
+		gen:     gen,
+		sources: make(map[string][]int),
+		stats:   make(map[string]*repo.Source),
+		keys:    make(map[string]int32),
+	}
+	dec := decoder{buf: index}
+	s.tenant = dec.string()
+	s.blocks = make([]block, dec.count())
+	for i := range s.blocks {
+		s.blocks[i] = block{
+			offset:  int64(dec.uvarint()),
+			size:    int64(dec.uvarint()),
+			crc:     uint32(dec.uvarint()),
+			count:   int(dec.uvarint()),
+			minID:   int32(uint32(dec.uvarint())),
+			maxID:   int32(uint32(dec.uvarint())),
+			minTime: dec.varint(),
+			maxTime: dec.varint(),
+		}
+	}
+	for range dec.count() {
+		source := dec.string()
+		blocks := make([]int, dec.count())
+		for i := range blocks {
+			b := dec.uvarint()
+			if b >= uint64(len(s.blocks)) {
+				dec.err = errCorrupted
+				break
+			}
+			blocks[i] = int(b)
+		}
+		s.sources[source] = blocks
+		stats := &repo.Source{Name: source, FirstSeen: dec.varint(), LastSeen: dec.varint()}
+		for i := range stats.Logs {
+			stats.Logs[i] = int64(dec.uvarint())
+		}
+		s.stats[source] = stats
+	}
+	// keys are the last part of the index, they may be left unread
+	if withKeys {
+		for range dec.count() {
+			key := dec.string()
+			s.keys[key] = int32(uint32(dec.uvarint()))
+		}
+	} else {
+		s.keys = nil
+	}
+	if dec.err != nil {
+		return nil, fmt.Errorf("segment %s: %v", path, dec.err)
+	}
+	s.summarize()
+
+	return s, nil
+}
+
+// readBlock decompresses logs of i-th block from f, the segment file.
+func (s *segment) readBlock(f *os.File, i int) ([]*repo.Log, error) {
+	b := s.blocks[i]
+	data := make([]byte, b.size)
+	if _, err := f.ReadAt(data, b.offset); err != nil {
+		return nil, fmt.Errorf("failed to read segment: %v", err)
+	}
+	if crc32.ChecksumIEEE(data) != b.crc {
+		return nil, fmt.Errorf("segment %s: block %d: %v", s.path, i, errCorrupted)
+	}
+
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("segment %s: block %d: failed to decompress: %v", s.path, i, err)
+	}
+
+	dec := decoder{buf: raw}
+	logs := make([]*repo.Log, dec.count())
+	for i := range logs {
+		logs[i] = dec.log()
+	}
+	if dec.err != nil {
+		return nil, fmt.Errorf("segment %s: block %d: %v", s.path, i, dec.err)
+	}
+	return logs, nil
+}
+
+// readLogs returns logs of blocks, every block when blocks is nil.
+func (s *segment) readLogs(blocks []int) ([]*repo.Log, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment: %v", err)
+	}
+	defer f.Close()
+
+	if blocks == nil {
+		blocks = make([]int, len(s.blocks))
+		for i := range blocks {
+			blocks[i] = i
+		}
+	}
+
+	var logs []*repo.Log
+	for _, i := range blocks {
+		blockLogs, err := s.readBlock(f, i)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, blockLogs...)
+	}
+	return logs, nil
+}
+
+// segmentsDir returns directory of tenant segments. Tenant is hex encoded,
+// so any tenant id is a safe file name.
+func segmentsDir(dir, tenant string) string {
+	return filepath.Join(dir, "segments", hex.EncodeToString([]byte(tenant)))
+}
@@ -0,0 +1,423 @@
+package segment_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/repo/segment"
+	"logstream/internal/tenant"
+)
+
+func openEngine(t *testing.T, dir string, flushLogs int) *segment.Engine {
+	t.Helper()
+	e, err := segment.Open(dir, segment.Options{Bucket: 100 * time.Second, FlushLogs: flushLogs})
+	require.NoError(t, err)
+	return e
+}
+
+func ids(logs []*repo.Log) []int32 {
+	ids := make([]int32, len(logs))
+	for i, l := range logs {
+		ids[i] = *l.Id
+	}
+	return ids
+}
+
+func TestGetLogs(t *testing.T) {
+	ctx := context.Background()
+	// three logs make a segment, so the head and segments of several
+	// buckets are queried together
+	e := openEngine(t, t.TempDir(), 3)
+	defer e.Close()
+
+	added, err := e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Level: 2, Message: "disk is full", CreatedAt: 150, Attributes: repo.Attributes{"path": "/var"}},
+		{Source: "worker", Level: 2, Message: "disk is full", CreatedAt: 50},
+		{Source: "api", Level: 2, Message: "failed to write: Disk quota", CreatedAt: 250},
+		{Source: "api", Level: 2, Message: "not a disk error", CreatedAt: 20},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 2, 3, 4}, added)
+	_, err = e.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Level: 2, Message: "disk is full", CreatedAt: 150})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		filter      repo.Filter
+		expectedIds []int32
+		expectedErr string
+	}{
+		{
+			name:        "get logs",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, StartTime: 100, EndTime: 300},
+			expectedIds: []int32{1, 3},
+		},
+		{
+			name:        "logs of all buckets in time order",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000},
+			expectedIds: []int32{4, 1, 3},
+		},
+		{
+			name:        "newest logs",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Desc: true, Limit: 2},
+			expectedIds: []int32{3, 1},
+		},
+		{
+			name:        "oldest logs",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Limit: 2},
+			expectedIds: []int32{4, 1},
+		},
+		{
+			name:        "search message",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "disk FULL"},
+			expectedIds: []int32{1},
+		},
+		{
+			name:        "logs of tenant",
+			ctx:         tenant.WithTenant(ctx, "acme"),
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000},
+			expectedIds: []int32{5},
+		},
+		{
+			name:        "unknown source",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "db", Level: 2, EndTime: 1000},
+			expectedErr: database.ErrNotFound.Error(),
+		},
+		{
+			name:        "invalid level",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 3, EndTime: 1000},
+			expectedErr: "invalid log level",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := e.GetLogs(tc.ctx, tc.filter)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedIds, ids(logs))
+		})
+	}
+
+	log, err := e.GetLog(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repo.Attributes{"path": "/var"}, log.Attributes)
+	_, err = e.GetLog(ctx, 5)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	tenants, err := e.GetTenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme", "default"}, tenants)
+}
+
+func TestGetLogsPage(t *testing.T) {
+	ctx := context.Background()
+	e := openEngine(t, t.TempDir(), 3)
+	defer e.Close()
+
+	// flushed to segments of two buckets with interleaving ids, the last
+	// logs stay in the WAL
+	for _, createdAt := range []int64{150, 10, 250, 20, 160, 10, 30, 5} {
+		_, err := e.AddLog(ctx, &repo.Log{Source: "api", Message: "message", CreatedAt: createdAt})
+		require.NoError(t, err)
+	}
+
+	expected := []repo.Position{
+		{CreatedAt: 5, Id: 8},
+		{CreatedAt: 10, Id: 2},
+		{CreatedAt: 10, Id: 6},
+		{CreatedAt: 20, Id: 4},
+		{CreatedAt: 30, Id: 7},
+		{CreatedAt: 150, Id: 1},
+		{CreatedAt: 160, Id: 5},
+		{CreatedAt: 250, Id: 3},
+	}
+	for _, desc := range []bool{false, true} {
+		var positions []repo.Position
+		cursor := repo.NewCursor(e, repo.Filter{Source: "api", EndTime: 1000, Desc: desc}, 2)
+		for cursor.Next(ctx) {
+			positions = append(positions, cursor.Log().Position())
+		}
+		require.NoError(t, cursor.Err())
+		if desc {
+			slices.Reverse(positions)
+		}
+		assert.Equal(t, expected, positions)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	e := openEngine(t, dir, 2)
+	_, err := e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "flushed", CreatedAt: 10},
+		{Source: "api", Message: "flushed", CreatedAt: 20},
+		{Source: "api", Message: "in wal", CreatedAt: 30},
+	})
+	require.NoError(t, err)
+	require.NoError(t, e.Close())
+
+	e = openEngine(t, dir, 2)
+	defer e.Close()
+	logs, err := e.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 100})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 2, 3}, ids(logs))
+
+	id, err := e.AddLog(ctx, &repo.Log{Source: "api", Message: "after reopen", CreatedAt: 40})
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), id)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	e := openEngine(t, dir, 2)
+	got, err := e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "flushed", CreatedAt: 10, IdempotencyKey: "a"},
+		{Source: "api", Message: "retried", CreatedAt: 10, IdempotencyKey: "a"},
+		{Source: "api", Message: "flushed", CreatedAt: 20, IdempotencyKey: "b"},
+		{Source: "api", Message: "in wal", CreatedAt: 30, IdempotencyKey: "c"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 1, 2, 3}, got)
+	require.NoError(t, e.Close())
+
+	// keys are restored from segments and the WAL
+	e = openEngine(t, dir, 2)
+	defer e.Close()
+	got, err = e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "retried", CreatedAt: 10, IdempotencyKey: "a"},
+		{Source: "api", Message: "retried", CreatedAt: 30, IdempotencyKey: "c"},
+		{Source: "api", Message: "new", CreatedAt: 40, IdempotencyKey: "d"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 3, 4}, got)
+
+	_, err = e.DeleteLogs(ctx, 15)
+	require.NoError(t, err)
+	id, err := e.AddLog(ctx, &repo.Log{Source: "api", Message: "after delete", CreatedAt: 50, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), id)
+}
+
+func TestIdempotencyKeyWindow(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := segment.Options{Bucket: 100 * time.Second, FlushLogs: 1, KeyFlushes: 1}
+	add := func(e *segment.Engine, key string, createdAt int64) int32 {
+		t.Helper()
+		id, err := e.AddLog(ctx, &repo.Log{Source: "api", Message: "message", CreatedAt: createdAt, IdempotencyKey: key})
+		require.NoError(t, err)
+		return id
+	}
+
+	// every log is flushed to a segment of its own generation
+	e, err := segment.Open(dir, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), add(e, "a", 10))
+	assert.Equal(t, int32(1), add(e, "a", 10))
+	assert.Equal(t, int32(2), add(e, "", 10))
+
+	// the key is expired after KeyFlushes flushes
+	assert.Equal(t, int32(3), add(e, "a", 30))
+	require.NoError(t, e.Close())
+
+	// only keys of the last generations are read on open
+	e, err = segment.Open(dir, opts)
+	require.NoError(t, err)
+	defer e.Close()
+	assert.Equal(t, int32(3), add(e, "a", 30))
+
+	// deleting the expired log keeps the key of the later one
+	n, err := e.DeleteLogs(ctx, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, int32(3), add(e, "a", 30))
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	e := openEngine(t, dir, 100)
+	_, err := e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "first", CreatedAt: 10},
+		{Source: "api", Message: "second", CreatedAt: 20},
+	})
+	require.NoError(t, err)
+
+	// the WAL of a running engine is what a crash leaves behind
+	wals, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	require.Len(t, wals, 1)
+	data, err := os.ReadFile(wals[0])
+	require.NoError(t, err)
+	require.NoError(t, e.Close())
+
+	crashed := t.TempDir()
+	// crash during append leaves a torn record
+	data = append(data, 42, 0, 0, 0, 1, 2)
+	require.NoError(t, os.WriteFile(filepath.Join(crashed, filepath.Base(wals[0])), data, 0o644))
+	// crash during flush leaves a segment of the WAL generation
+	tenantDir := filepath.Join(crashed, "segments", "64656661756c74")
+	require.NoError(t, os.MkdirAll(tenantDir, 0o755))
+	leftover := filepath.Join(tenantDir, "0-"+filepath.Base(wals[0])[4:20]+".seg")
+	require.NoError(t, os.WriteFile(leftover, []byte("partial"), 0o644))
+
+	e = openEngine(t, crashed, 100)
+	defer e.Close()
+	_, err = os.Stat(leftover)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	logs, err := e.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 100})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 2}, ids(logs))
+
+	// the torn record is truncated, so new records are replayed too
+	id, err := e.AddLog(ctx, &repo.Log{Source: "api", Message: "third", CreatedAt: 30})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), id)
+}
+
+func TestDeleteLogs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	e := openEngine(t, dir, 3)
+	_, err := e.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "old bucket", CreatedAt: 10},
+		{Source: "api", Message: "overlapping bucket", CreatedAt: 110},
+		{Source: "api", Message: "overlapping bucket", CreatedAt: 190},
+		{Source: "api", Message: "head", CreatedAt: 120},
+		{Source: "api", Message: "head", CreatedAt: 220},
+	})
+	require.NoError(t, err)
+	_, err = e.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 10})
+	require.NoError(t, err)
+
+	n, err := e.DeleteLogs(ctx, 150)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	check := func(e *segment.Engine) {
+		logs, err := e.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, []int32{3, 5}, ids(logs))
+		logs, err = e.GetLogs(tenant.WithTenant(ctx, "acme"), repo.Filter{Source: "api", EndTime: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, []int32{6}, ids(logs))
+	}
+	check(e)
+	require.NoError(t, e.Close())
+
+	e = openEngine(t, dir, 3)
+	defer e.Close()
+	check(e)
+}
+
+func TestGetSources(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	dir := t.TempDir()
+
+	// the first logs are flushed to segments, the last stay in the head
+	r := openEngine(t, dir, 4)
+	_, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		{Source: "api", Level: 2, Message: "failed", CreatedAt: 30},
+		{Source: "api-gateway", Level: 1, Message: "slow", CreatedAt: 40},
+		{Source: "web", Level: 0, Message: "started", CreatedAt: 170},
+		{Source: "api", Level: 0, Message: "stopped", CreatedAt: 180},
+	})
+	require.NoError(t, err)
+	_, err = r.AddLog(acme, &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 10})
+	require.NoError(t, err)
+
+	// the retried log is counted once
+	sources, err := r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 30, LastSeen: 180, Logs: [3]int64{2, 0, 1}},
+		{Name: "api-gateway", FirstSeen: 40, LastSeen: 40, Logs: [3]int64{0, 1, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+
+	sources, err = r.GetSources(ctx, "api")
+	require.NoError(t, err)
+	assert.Len(t, sources, 2)
+	sources, err = r.GetSources(ctx, "db")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+
+	sources, err = r.GetSources(acme, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{{Name: "api", FirstSeen: 10, LastSeen: 10, Logs: [3]int64{1, 0, 0}}}, sources)
+
+	// sources without logs are gone, first seen moves to the oldest kept log
+	_, err = r.DeleteLogs(ctx, 50)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	// stats of segments are read back from their index
+	r = openEngine(t, dir, 4)
+	defer r.Close()
+	sources, err = r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 50, LastSeen: 180, Logs: [3]int64{2, 0, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+}
+
+func TestKeysRepo(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	r, err := segment.NewKeysRepo(dir)
+	require.NoError(t, err)
+	id, err := r.AddAPIKey(ctx, &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h1", Role: "writer"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), id)
+	_, err = r.AddAPIKey(ctx, &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h2"})
+	assert.ErrorIs(t, err, database.ErrPKeyConflict)
+	_, err = r.AddAPIKey(ctx, &repo.APIKey{Tenant: "acme", Name: "other", Hash: "h1"})
+	assert.ErrorIs(t, err, database.ErrPKeyConflict)
+	require.NoError(t, r.RevokeAPIKey(ctx, id, 100))
+	assert.ErrorIs(t, r.RevokeAPIKey(ctx, id, 200), database.ErrNotFound)
+
+	// keys are kept in the file
+	r, err = segment.NewKeysRepo(dir)
+	require.NoError(t, err)
+	key, err := r.GetAPIKeyByHash(ctx, "h1")
+	require.NoError(t, err)
+	assert.Equal(t, "writer", key.Role)
+	assert.Equal(t, int64(100), key.RevokedAt)
+	id, err = r.AddAPIKey(ctx, &repo.APIKey{Tenant: "default", Name: "deploy", Hash: "h3"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), id)
+}
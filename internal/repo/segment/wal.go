@@ -0,0 +1,117 @@
+package segment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WAL record types
+const (
+	recordAdd    byte = 1
+	recordDelete byte = 2
+)
+
+// walHeaderSize - payload length and CRC32 preceding every record
+const walHeaderSize = 8
+
+// wal is the write-ahead log of logs not yet written to segments. Every
+// append is fsynced before it is acknowledged.
+type wal struct {
+	f   *os.File
+	gen uint64
+}
+
+func walPath(dir string, gen uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("wal-%016d.log", gen))
+}
+
+func createWAL(dir string, gen uint64) (*wal, error) {
+	f, err := os.OpenFile(walPath(dir, gen), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wal: %v", err)
+	}
+	if err := syncDir(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &wal{f: f, gen: gen}, nil
+}
+
+// openWAL passes every record of WAL generation gen to apply and opens it
+// for appending. A torn record at the end, left by a crash during append,
+// is truncated: it was never acknowledged.
+func openWAL(dir string, gen uint64, apply func(payload []byte) error) (*wal, error) {
+	f, err := os.OpenFile(walPath(dir, gen), os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wal: %v", err)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read wal: %v", err)
+	}
+
+	var offset int
+	for len(data)-offset >= walHeaderSize {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		sum := binary.LittleEndian.Uint32(data[offset+4:])
+		end := offset + walHeaderSize + size
+		if size > len(data)-offset-walHeaderSize || crc32.ChecksumIEEE(data[offset+walHeaderSize:end]) != sum {
+			break
+		}
+		if err := apply(data[offset+walHeaderSize : end]); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to replay wal: %v", err)
+		}
+		offset = end
+	}
+
+	if offset < len(data) {
+		if err := f.Truncate(int64(offset)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to truncate wal: %v", err)
+		}
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek wal: %v", err)
+	}
+	return &wal{f: f, gen: gen}, nil
+}
+
+func (w *wal) append(payload []byte) error {
+	record := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	if _, err := w.f.Write(record); err != nil {
+		return fmt.Errorf("failed to write wal: %v", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync wal: %v", err)
+	}
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
+
+// syncDir makes created, renamed and removed files of dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open dir: %v", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dir: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"slices"
+	"strings"
+
+	pb "logstream/pkg/api/logstream"
+)
+
+// Source - source of stored logs of a tenant
+type Source struct {
+	Name string
+	// FirstSeen - time of the oldest log
+	FirstSeen int64
+	// LastSeen - time of the newest log
+	LastSeen int64
+	// Logs - number of logs by level
+	Logs [3]int64
+}
+
+// Total returns number of logs of all levels.
+func (s *Source) Total() int64 {
+	return s.Logs[0] + s.Logs[1] + s.Logs[2]
+}
+
+// Add counts log of the source.
+func (s *Source) Add(log *Log) {
+	if s.Total() == 0 {
+		s.FirstSeen, s.LastSeen = log.CreatedAt, log.CreatedAt
+	}
+	s.FirstSeen = min(s.FirstSeen, log.CreatedAt)
+	s.LastSeen = max(s.LastSeen, log.CreatedAt)
+	s.Logs[log.Level]++
+}
+
+// Remove uncounts removed log of the source. Logs are removed about oldest
+// first, so first seen time is moved to the removed log; storages knowing
+// the oldest remaining log set it after.
+func (s *Source) Remove(log *Log) {
+	s.Logs[log.Level]--
+	s.FirstSeen = max(s.FirstSeen, log.CreatedAt)
+}
+
+// Merge adds logs of other of the same source.
+func (s *Source) Merge(other *Source) {
+	if other.Total() == 0 {
+		return
+	}
+	if s.Total() == 0 {
+		s.FirstSeen, s.LastSeen = other.FirstSeen, other.LastSeen
+	}
+	s.FirstSeen = min(s.FirstSeen, other.FirstSeen)
+	s.LastSeen = max(s.LastSeen, other.LastSeen)
+	for i := range s.Logs {
+		s.Logs[i] += other.Logs[i]
+	}
+}
+
+func (s *Source) ToPbSource() *pb.Source {
+	return &pb.Source{
+		Name:      s.Name,
+		FirstSeen: s.FirstSeen,
+		LastSeen:  s.LastSeen,
+		InfoLogs:  s.Logs[pb.Level_LEVEL_INFO],
+		WarnLogs:  s.Logs[pb.Level_LEVEL_WARN],
+		ErrorLogs: s.Logs[pb.Level_LEVEL_ERROR],
+	}
+}
+
+// SortSources returns copies of sources having logs and name prefix sorted
+// by name.
+func SortSources(sources map[string]*Source, prefix string) []*Source {
+	var sorted []*Source
+	for name, s := range sources {
+		if s.Total() > 0 && strings.HasPrefix(name, prefix) {
+			c := *s
+			sorted = append(sorted, &c)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b *Source) int { return strings.Compare(a.Name, b.Name) })
+	return sorted
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type keysRepo struct {
+	db *sql.DB
+}
+
+func NewKeysRepo(db *sql.DB) repo.KeysRepo {
+	return &keysRepo{
+		db: db,
+	}
+}
+
+const apiKeyColumns = "id, tenant_id, name, prefix, key_hash, role, created_at, expires_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*repo.APIKey, error) {
+	var key repo.APIKey
+	err := row.Scan(&key.Id, &key.Tenant, &key.Name, &key.Prefix, &key.Hash, &key.Role, &key.CreatedAt, &key.ExpiresAt, &key.RevokedAt)
+	return &key, err
+}
+
+func (r *keysRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*repo.APIKey, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = ?"
+	key, err := scanAPIKey(db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %v", err)
+	}
+
+	return key, nil
+}
+
+func (r *keysRepo) GetAPIKeys(ctx context.Context) ([]*repo.APIKey, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE tenant_id = ? ORDER BY id"
+	rows, err := db.QueryContext(ctx, query, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []*repo.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (r *keysRepo) AddAPIKey(ctx context.Context, key *repo.APIKey) (int32, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var id int32
+	query := "INSERT INTO api_keys (tenant_id, name, prefix, key_hash, role, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id"
+	if err := db.QueryRowContext(ctx, query, key.Tenant, key.Name, key.Prefix, key.Hash, key.Role, key.CreatedAt, key.ExpiresAt).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, database.ErrPKeyConflict
+		}
+		return 0, fmt.Errorf("failed to add api key: %v", err)
+	}
+
+	return id, nil
+}
+
+func (r *keysRepo) RevokeAPIKey(ctx context.Context, id int32, revokedAt int64) error {
+	db := database.FromContext(ctx, r.db)
+
+	query := "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at = 0 AND tenant_id = ?"
+	res, err := db.ExecContext(ctx, query, revokedAt, id, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %v", err)
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    source TEXT NOT NULL,
+    lvl INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    attributes TEXT NOT NULL DEFAULT '{}'
+);
+-- +goose StatementEnd
+
+CREATE INDEX IF NOT EXISTS logs_tenant_source_idx ON logs (tenant_id, source, lvl, created_at);
+CREATE INDEX IF NOT EXISTS logs_tenant_created_at_idx ON logs (tenant_id, created_at);
+
+-- messages are indexed with the same word rules as repo.Words
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(message, content='logs', content_rowid='id', tokenize='unicode61 remove_diacritics 0');
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+    INSERT INTO logs_fts (rowid, message) VALUES (new.id, new.message);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+    INSERT INTO logs_fts (logs_fts, rowid, message) VALUES ('delete', old.id, old.message);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL DEFAULT 0,
+    revoked_at INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (tenant_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
+DROP TRIGGER IF EXISTS logs_fts_delete;
+DROP TRIGGER IF EXISTS logs_fts_insert;
+DROP TABLE IF EXISTS logs_fts;
+DROP TABLE IF EXISTS logs;
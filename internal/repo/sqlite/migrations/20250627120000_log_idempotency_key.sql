@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE logs ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS logs_idempotency_key_idx ON logs (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS logs_idempotency_key_idx;
+ALTER TABLE logs DROP COLUMN idempotency_key;
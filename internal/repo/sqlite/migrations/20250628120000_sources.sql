@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sources (
+    tenant_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    lvl INTEGER NOT NULL,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+    logs INTEGER NOT NULL,
+    PRIMARY KEY (tenant_id, source, lvl)
+);
+-- +goose StatementEnd
+
+-- AFTER INSERT does not fire when a saved idempotency key updates the row
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS logs_sources_insert AFTER INSERT ON logs BEGIN
+    INSERT INTO sources (tenant_id, source, lvl, first_seen, last_seen, logs)
+    VALUES (new.tenant_id, new.source, new.lvl, new.created_at, new.created_at, 1)
+    ON CONFLICT (tenant_id, source, lvl) DO UPDATE SET
+        first_seen = min(first_seen, excluded.first_seen),
+        last_seen = max(last_seen, excluded.last_seen),
+        logs = logs + 1;
+END;
+-- +goose StatementEnd
+
+-- first seen time is read from logs_tenant_source_idx
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS logs_sources_delete AFTER DELETE ON logs BEGIN
+    UPDATE sources SET
+        first_seen = coalesce((
+            SELECT min(created_at) FROM logs
+            WHERE tenant_id = old.tenant_id AND source = old.source AND lvl = old.lvl
+        ), first_seen),
+        logs = logs - 1
+    WHERE tenant_id = old.tenant_id AND source = old.source AND lvl = old.lvl;
+    DELETE FROM sources
+    WHERE tenant_id = old.tenant_id AND source = old.source AND lvl = old.lvl AND logs <= 0;
+END;
+-- +goose StatementEnd
+
+INSERT OR IGNORE INTO sources (tenant_id, source, lvl, first_seen, last_seen, logs)
+SELECT tenant_id, source, lvl, min(created_at), max(created_at), count(*)
+FROM logs
+GROUP BY tenant_id, source, lvl;
+
+-- +goose Down
+DROP TRIGGER IF EXISTS logs_sources_delete;
+DROP TRIGGER IF EXISTS logs_sources_insert;
+DROP TABLE IF EXISTS sources;
@@ -0,0 +1,289 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type logsRepo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) repo.Repo {
+	return &logsRepo{
+		db: db,
+	}
+}
+
+func (r *logsRepo) RunInTx(ctx context.Context, f func(ctx context.Context) error) error {
+	return database.RunInTx(ctx, r.db, f)
+}
+
+func (r *logsRepo) GetLog(ctx context.Context, id int32) (*repo.Log, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var log repo.Log
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = ? AND tenant_id = ?"
+	if err := db.QueryRowContext(ctx, query, id, tenant.FromContext(ctx)).Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get log: %v", err)
+	}
+
+	return &log, nil
+}
+
+func (r *logsRepo) GetLogs(ctx context.Context, filter repo.Filter) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = ? AND lvl = ? AND created_at >= ? AND created_at <= ? AND tenant_id = ?"
+	args := []any{filter.Source, filter.Level, filter.StartTime, filter.EndTime, tenant.FromContext(ctx)}
+	if words := repo.Words(filter.Query); len(words) > 0 {
+		query += " AND id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)"
+		args = append(args, matchQuery(words))
+	}
+	query += orderBy(filter)
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer rows.Close()
+
+	var logs []*repo.Log
+	for rows.Next() {
+		var log repo.Log
+		if err := rows.Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to scan log: %v", err)
+		}
+		logs = append(logs, &log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	if len(logs) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	return logs, nil
+}
+
+func (r *logsRepo) GetLogsPage(ctx context.Context, filter repo.Filter, page repo.Page) ([]*repo.Log, error) {
+	if filter.Level > 2 {
+		return nil, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = ? AND lvl = ? AND created_at >= ? AND created_at <= ? AND tenant_id = ?"
+	args := []any{filter.Source, filter.Level, filter.StartTime, filter.EndTime, tenant.FromContext(ctx)}
+	if words := repo.Words(filter.Query); len(words) > 0 {
+		query += " AND id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)"
+		args = append(args, matchQuery(words))
+	}
+	if page.After != nil {
+		if filter.Desc {
+			query += " AND (created_at, id) < (?, ?)"
+		} else {
+			query += " AND (created_at, id) > (?, ?)"
+		}
+		args = append(args, page.After.CreatedAt, page.After.Id)
+	}
+	query += orderBy(filter) + " LIMIT ?"
+	args = append(args, page.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %v", err)
+	}
+	defer rows.Close()
+
+	var logs []*repo.Log
+	for rows.Next() {
+		var log repo.Log
+		if err := rows.Scan(&log.Id, &log.Source, &log.Level, &log.Message, &log.CreatedAt, &log.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to scan log: %v", err)
+		}
+		logs = append(logs, &log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return logs, nil
+}
+
+// orderBy returns ORDER BY clause of the filter order.
+func orderBy(filter repo.Filter) string {
+	if filter.Desc {
+		return " ORDER BY created_at DESC, id DESC"
+	}
+	return " ORDER BY created_at, id"
+}
+
+// matchQuery returns FTS5 query matching messages containing every word.
+// Words are quoted, so they are not read as operators like NOT or NEAR.
+func matchQuery(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = `"` + word + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (r *logsRepo) AddLog(ctx context.Context, log *repo.Log) (int32, error) {
+	if log.Level > 2 {
+		return 0, fmt.Errorf("invalid log level: should be 0 (INFO), 1 (WARN), 2 (ERROR)")
+	}
+
+	db := database.FromContext(ctx, r.db)
+
+	var id int32
+	err := db.QueryRowContext(ctx, insertLog, log.Source, log.Level, log.Message, log.CreatedAt, log.Attributes, tenant.FromContext(ctx), log.IdempotencyKey).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add log: %v", err)
+	}
+
+	return id, nil
+}
+
+// insertLog inserts log, or returns id of the log saved with the same
+// idempotency key, like onKeyConflict of postgres.
+const insertLog = "INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''))" +
+	" ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = excluded.idempotency_key RETURNING id"
+
+// AddLogs inserts logs one by one in a transaction: SQLite does not
+// guarantee order of rows returned by a multi-row insert, and single
+// inserts of an embedded database are cheap.
+func (r *logsRepo) AddLogs(ctx context.Context, logs []*repo.Log) ([]int32, error) {
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no logs to add")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tx: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add logs: %v", err)
+	}
+	defer stmt.Close()
+
+	tenantID := tenant.FromContext(ctx)
+
+	ids := make([]int32, 0, len(logs))
+	for _, log := range logs {
+		var id int32
+		if err := stmt.QueryRowContext(ctx, log.Source, log.Level, log.Message, log.CreatedAt, log.Attributes, tenantID, log.IdempotencyKey).Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to add logs: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %v", err)
+	}
+
+	return ids, nil
+}
+
+func (r *logsRepo) GetTenants(ctx context.Context) ([]string, error) {
+	db := database.FromContext(ctx, r.db)
+
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT tenant_id FROM logs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenants: %v", err)
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %v", err)
+		}
+		tenants = append(tenants, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return tenants, nil
+}
+
+func (r *logsRepo) GetSources(ctx context.Context, prefix string) ([]*repo.Source, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := "SELECT source, lvl, first_seen, last_seen, logs FROM sources WHERE tenant_id = ? AND substr(source, 1, length(?)) = ? AND logs > 0 ORDER BY source, lvl"
+	rows, err := db.QueryContext(ctx, query, tenant.FromContext(ctx), prefix, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sources: %v", err)
+	}
+	defer rows.Close()
+
+	var sources []*repo.Source
+	for rows.Next() {
+		var (
+			s     repo.Source
+			level int
+			logs  int64
+		)
+		if err := rows.Scan(&s.Name, &level, &s.FirstSeen, &s.LastSeen, &logs); err != nil {
+			return nil, fmt.Errorf("failed to scan source: %v", err)
+		}
+		if level < 0 || level >= len(s.Logs) {
+			return nil, fmt.Errorf("invalid level %d of source %s", level, s.Name)
+		}
+		s.Logs[level] = logs
+
+		if n := len(sources); n > 0 && sources[n-1].Name == s.Name {
+			sources[n-1].Merge(&s)
+			continue
+		}
+		sources = append(sources, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return sources, nil
+}
+
+func (r *logsRepo) DeleteLogs(ctx context.Context, before int64) (int64, error) {
+	db := database.FromContext(ctx, r.db)
+
+	res, err := db.ExecContext(ctx, "DELETE FROM logs WHERE created_at < ? AND tenant_id = ?", before, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %v", err)
+	}
+
+	return n, nil
+}
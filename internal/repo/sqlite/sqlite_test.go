@@ -0,0 +1,320 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/repo/sqlite"
+	"logstream/internal/tenant"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "logstream.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logstream.db")
+	ctx := context.Background()
+
+	db, err := sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	var mode string
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+	_, err = sqlite.NewRepo(db).AddLog(ctx, &repo.Log{Source: "api", Message: "kept", CreatedAt: 1})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// applied migrations are skipped on reopen
+	db, err = sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	log, err := sqlite.NewRepo(db).GetLog(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "kept", log.Message)
+}
+
+func TestGetLogs(t *testing.T) {
+	ctx := context.Background()
+	r := sqlite.NewRepo(openDB(t))
+
+	ids, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Level: 2, Message: "disk is full", CreatedAt: 100, Attributes: repo.Attributes{"path": "/var"}},
+		{Source: "api", Level: 2, Message: "failed to write: Disk quota", CreatedAt: 200},
+		{Source: "api", Level: 2, Message: "not a disk error, mail ops@example.com", CreatedAt: 300},
+		{Source: "worker", Level: 2, Message: "disk is full", CreatedAt: 100},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 2, 3, 4}, ids)
+	_, err = r.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Level: 2, Message: "disk is full", CreatedAt: 100})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		filter      repo.Filter
+		expectedIds []int32
+		expectedErr string
+	}{
+		{
+			name:        "get logs",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, StartTime: 100, EndTime: 200},
+			expectedIds: []int32{1, 2},
+		},
+		{
+			name:        "search message",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "DISK"},
+			expectedIds: []int32{1, 2, 3},
+		},
+		{
+			name:        "every word matches",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "disk full"},
+			expectedIds: []int32{1},
+		},
+		{
+			name:        "operators are words",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: `not "disk`},
+			expectedIds: []int32{3},
+		},
+		{
+			name:        "emails are split into words",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "example"},
+			expectedIds: []int32{3},
+		},
+		{
+			name:        "tenant",
+			ctx:         tenant.WithTenant(ctx, "acme"),
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "disk"},
+			expectedIds: []int32{5},
+		},
+		{
+			name:        "logs not found",
+			ctx:         ctx,
+			filter:      repo.Filter{Source: "api", Level: 2, EndTime: 1000, Query: "memory"},
+			expectedErr: "record not found",
+		},
+		{
+			name:        "invalid log level",
+			ctx:         ctx,
+			filter:      repo.Filter{Level: 1000},
+			expectedErr: "invalid log level",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := r.GetLogs(tc.ctx, tc.filter)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				ids := make([]int32, 0, len(logs))
+				for _, l := range logs {
+					ids = append(ids, *l.Id)
+				}
+				assert.Equal(t, tc.expectedIds, ids)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				assert.Nil(t, logs)
+			}
+		})
+	}
+
+	log, err := r.GetLog(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repo.Attributes{"path": "/var"}, log.Attributes)
+}
+
+func TestGetLogsPage(t *testing.T) {
+	ctx := context.Background()
+	r := sqlite.NewRepo(openDB(t))
+	_, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "disk full 3", CreatedAt: 30},
+		{Source: "api", Message: "disk full 1", CreatedAt: 10},
+		{Source: "api", Message: "disk full 2a", CreatedAt: 20},
+		{Source: "api", Message: "disk full 2b", CreatedAt: 20},
+		{Source: "api", Message: "other", CreatedAt: 20},
+	})
+	require.NoError(t, err)
+
+	var messages []string
+	cursor := repo.NewCursor(r, repo.Filter{Source: "api", EndTime: 100, Query: "disk"}, 2)
+	for cursor.Next(ctx) {
+		messages = append(messages, cursor.Log().Message)
+	}
+	require.NoError(t, cursor.Err())
+	assert.Equal(t, []string{"disk full 1", "disk full 2a", "disk full 2b", "disk full 3"}, messages)
+
+	logs, err := r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 100, Desc: true, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "disk full 3", logs[0].Message)
+	assert.Equal(t, "other", logs[1].Message)
+}
+
+func TestDeleteLogs(t *testing.T) {
+	ctx := context.Background()
+	r := sqlite.NewRepo(openDB(t))
+
+	_, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "old", CreatedAt: 1},
+		{Source: "api", Message: "new", CreatedAt: 10},
+	})
+	require.NoError(t, err)
+	_, err = r.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Message: "old", CreatedAt: 1})
+	require.NoError(t, err)
+
+	tenants, err := r.GetTenants(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"default", "acme"}, tenants)
+
+	n, err := r.DeleteLogs(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// deleted logs are removed from the search index too
+	_, err = r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 10, Query: "old"})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	logs, err := r.GetLogs(tenant.WithTenant(ctx, "acme"), repo.Filter{Source: "api", EndTime: 10, Query: "old"})
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	r := sqlite.NewRepo(openDB(t))
+
+	ids, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Message: "first", CreatedAt: 1, IdempotencyKey: "a"},
+		{Source: "api", Message: "retried", CreatedAt: 1, IdempotencyKey: "a"},
+		{Source: "api", Message: "no key", CreatedAt: 1},
+		{Source: "api", Message: "no key", CreatedAt: 1},
+	})
+	require.NoError(t, err)
+	// like sequences of postgres, conflicting inserts leave gaps in ids
+	assert.Equal(t, ids[0], ids[1])
+	assert.Len(t, slices.Compact(ids), 3)
+
+	id, err := r.AddLog(ctx, &repo.Log{Source: "api", Message: "retried", CreatedAt: 1, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], id)
+
+	id, err = r.AddLog(tenant.WithTenant(ctx, "acme"), &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 1, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	assert.NotEqual(t, ids[0], id)
+
+	logs, err := r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 10})
+	require.NoError(t, err)
+	assert.Len(t, logs, 3)
+}
+
+func TestGetSources(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	r := sqlite.NewRepo(openDB(t))
+
+	_, err := r.AddLogs(ctx, []*repo.Log{
+		{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		{Source: "api", Level: 0, Message: "started", CreatedAt: 50, IdempotencyKey: "a"},
+		{Source: "api", Level: 2, Message: "failed", CreatedAt: 30},
+		{Source: "api-gateway", Level: 1, Message: "slow", CreatedAt: 40},
+		{Source: "web", Level: 0, Message: "started", CreatedAt: 170},
+		{Source: "api", Level: 0, Message: "stopped", CreatedAt: 180},
+	})
+	require.NoError(t, err)
+	_, err = r.AddLog(acme, &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 10})
+	require.NoError(t, err)
+	_, err = r.AddLog(ctx, &repo.Log{Source: "api", Message: "started", CreatedAt: 50, IdempotencyKey: "a"})
+	require.NoError(t, err)
+
+	// the retried log is counted once
+	sources, err := r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 30, LastSeen: 180, Logs: [3]int64{2, 0, 1}},
+		{Name: "api-gateway", FirstSeen: 40, LastSeen: 40, Logs: [3]int64{0, 1, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+
+	sources, err = r.GetSources(ctx, "api")
+	require.NoError(t, err)
+	assert.Len(t, sources, 2)
+	sources, err = r.GetSources(ctx, "db")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+
+	sources, err = r.GetSources(acme, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{{Name: "api", FirstSeen: 10, LastSeen: 10, Logs: [3]int64{1, 0, 0}}}, sources)
+
+	// sources without logs are gone, first seen moves to the oldest kept log
+	_, err = r.DeleteLogs(ctx, 50)
+	require.NoError(t, err)
+	sources, err = r.GetSources(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Source{
+		{Name: "api", FirstSeen: 50, LastSeen: 180, Logs: [3]int64{2, 0, 0}},
+		{Name: "web", FirstSeen: 170, LastSeen: 170, Logs: [3]int64{1, 0, 0}},
+	}, sources)
+}
+
+func TestKeysRepo(t *testing.T) {
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	r := sqlite.NewKeysRepo(openDB(t))
+
+	id, err := r.AddAPIKey(ctx, &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h1", Role: "writer"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		key         *repo.APIKey
+		expectedErr error
+	}{
+		{
+			name: "same name of other tenant",
+			key:  &repo.APIKey{Tenant: "acme", Name: "ci", Hash: "h2", Role: "writer"},
+		},
+		{
+			name:        "duplicate name",
+			key:         &repo.APIKey{Tenant: "default", Name: "ci", Hash: "h3", Role: "writer"},
+			expectedErr: database.ErrPKeyConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := r.AddAPIKey(ctx, tc.key)
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+
+	keys, err := r.GetAPIKeys(acme)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "h2", keys[0].Hash)
+
+	assert.ErrorIs(t, r.RevokeAPIKey(acme, id, 100), database.ErrNotFound)
+	require.NoError(t, r.RevokeAPIKey(ctx, id, 100))
+	assert.ErrorIs(t, r.RevokeAPIKey(ctx, id, 200), database.ErrNotFound)
+
+	key, err := r.GetAPIKeyByHash(ctx, "h1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), key.RevokedAt)
+}
@@ -0,0 +1,112 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"logstream/internal/config"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+const defaultInterval = time.Hour
+
+// Worker periodically deletes logs older than retention of their tenant.
+type Worker struct {
+	r      repo.Repo
+	policy atomic.Pointer[policy]
+
+	now func() time.Time
+}
+
+type policy struct {
+	interval time.Duration
+	maxAge   time.Duration
+	tenants  map[string]time.Duration
+}
+
+// New creates worker. Nil config keeps logs forever.
+func New(r repo.Repo, cfg *config.RetentionConfig) (*Worker, error) {
+	if cfg == nil {
+		cfg = &config.RetentionConfig{}
+	}
+
+	p := &policy{
+		interval: cfg.Interval,
+		maxAge:   cfg.MaxAge,
+		tenants:  make(map[string]time.Duration, len(cfg.Tenants)),
+	}
+	if p.interval <= 0 {
+		p.interval = defaultInterval
+	}
+	for i, t := range cfg.Tenants {
+		if err := tenant.Validate(t.Tenant); err != nil {
+			return nil, fmt.Errorf("retention.tenants[%d]: %v", i, err)
+		}
+		p.tenants[t.Tenant] = t.MaxAge
+	}
+
+	w := &Worker{
+		r:   r,
+		now: time.Now,
+	}
+	w.policy.Store(p)
+	return w, nil
+}
+
+// Update applies retention of worker from, new interval is used after the
+// next run.
+func (w *Worker) Update(from *Worker) {
+	w.policy.Store(from.policy.Load())
+}
+
+// Run applies retention every interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		if err := w.Apply(ctx); err != nil {
+			slog.Error("failed to apply retention", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.policy.Load().interval):
+		}
+	}
+}
+
+// Apply deletes old logs of every tenant once.
+func (w *Worker) Apply(ctx context.Context) error {
+	tenants, err := w.r.GetTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	p := w.policy.Load()
+	now := w.now()
+	for _, id := range tenants {
+		maxAge, ok := p.tenants[id]
+		if !ok {
+			maxAge = p.maxAge
+		}
+		if maxAge <= 0 {
+			continue
+		}
+
+		n, err := w.r.DeleteLogs(tenant.WithTenant(ctx, id), now.Add(-maxAge).Unix())
+		if err != nil {
+			return fmt.Errorf("tenant %s: %v", id, err)
+		}
+		if n > 0 {
+			slog.Info("retention deleted old logs",
+				slog.String("tenant", id),
+				slog.Int64("deleted", n),
+				slog.Duration("max_age", maxAge),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/config"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+type logsRepo struct {
+	repo.Repo
+
+	tenants []string
+	deleted map[string]int64
+}
+
+func (r *logsRepo) GetTenants(ctx context.Context) ([]string, error) {
+	return r.tenants, nil
+}
+
+func (r *logsRepo) DeleteLogs(ctx context.Context, before int64) (int64, error) {
+	r.deleted[tenant.FromContext(ctx)] = before
+	return 1, nil
+}
+
+func TestApply(t *testing.T) {
+	now := time.Unix(1749108957, 0)
+
+	testCases := []struct {
+		name            string
+		cfg             *config.RetentionConfig
+		expectedDeleted map[string]int64
+	}{
+		{
+			name: "default max age",
+			cfg:  &config.RetentionConfig{MaxAge: time.Hour},
+			expectedDeleted: map[string]int64{
+				"default": now.Unix() - 3600,
+				"acme":    now.Unix() - 3600,
+				"globex":  now.Unix() - 3600,
+			},
+		},
+		{
+			name: "tenant overrides",
+			cfg: &config.RetentionConfig{
+				MaxAge: time.Hour,
+				Tenants: []*config.TenantRetentionConfig{
+					{Tenant: "acme", MaxAge: 24 * time.Hour},
+					{Tenant: "globex"},
+				},
+			},
+			expectedDeleted: map[string]int64{
+				"default": now.Unix() - 3600,
+				"acme":    now.Unix() - 86400,
+			},
+		},
+		{
+			name: "keep forever by default",
+			cfg: &config.RetentionConfig{
+				Tenants: []*config.TenantRetentionConfig{
+					{Tenant: "acme", MaxAge: time.Minute},
+				},
+			},
+			expectedDeleted: map[string]int64{
+				"acme": now.Unix() - 60,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &logsRepo{
+				tenants: []string{"default", "acme", "globex"},
+				deleted: make(map[string]int64),
+			}
+			w, err := New(r, tc.cfg)
+			require.NoError(t, err)
+			w.now = func() time.Time { return now }
+
+			require.NoError(t, w.Apply(context.Background()))
+			assert.Equal(t, tc.expectedDeleted, r.deleted)
+		})
+	}
+}
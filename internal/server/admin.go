@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/auth"
+	"logstream/internal/database"
+	"logstream/internal/logging"
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+	pb "logstream/pkg/api/logstream"
+)
+
+// AdminServer implements pb.AdminServiceServer
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+
+	keys   repo.KeysRepo
+	roles  auth.Roles
+	reload ReloadFunc
+	now    func() time.Time
+}
+
+// ReloadFunc reloads server config and returns changed sections applied
+// only after restart.
+type ReloadFunc func() ([]string, error)
+
+func NewAdminServer(keys repo.KeysRepo, roles auth.Roles, reload ReloadFunc) *AdminServer {
+	return &AdminServer{
+		keys:   keys,
+		roles:  roles,
+		reload: reload,
+		now:    time.Now,
+	}
+}
+
+func (s *AdminServer) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	now := s.now().Unix()
+	if err := validateCreateAPIKeyRequest(req, s.roles, now); err != nil {
+		return nil, err
+	}
+	if err := auth.CheckGrant(ctx, s.roles[req.GetRole()]); err != nil {
+		return nil, err
+	}
+
+	key, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	apiKey := &repo.APIKey{
+		Tenant:    tenant.FromContext(ctx),
+		Name:      req.GetName(),
+		Prefix:    prefix,
+		Hash:      hash,
+		Role:      req.GetRole(),
+		CreatedAt: now,
+		ExpiresAt: req.GetExpiresAt(),
+	}
+	apiKey.Id, err = s.keys.AddAPIKey(ctx, apiKey)
+	if err != nil {
+		if errors.Is(err, database.ErrPKeyConflict) {
+			return nil, status.Error(codes.AlreadyExists, "api key with this name already exists")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		logging.FromContext(ctx).Info("api key created",
+			slog.String("principal", p.Name),
+			slog.Int("id", int(apiKey.Id)),
+			slog.String("name", apiKey.Name),
+			slog.String("role", apiKey.Role),
+			slog.String("tenant", apiKey.Tenant),
+		)
+	}
+
+	return &pb.CreateAPIKeyResponse{
+		ApiKey: apiKey.ToPbAPIKey(),
+		Key:    key,
+	}, nil
+}
+
+func (s *AdminServer) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	keys, err := s.keys.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.ListAPIKeysResponse{
+		ApiKeys: make([]*pb.APIKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, key.ToPbAPIKey())
+	}
+	return resp, nil
+}
+
+func (s *AdminServer) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	if err := validateRevokeAPIKeyRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.keys.RevokeAPIKey(ctx, req.GetId(), s.now().Unix()); err != nil {
+		if database.IsRecordNotFoundError(err) {
+			return nil, status.Error(codes.NotFound, "active api key not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if p, ok := auth.FromContext(ctx); ok {
+		logging.FromContext(ctx).Info("api key revoked",
+			slog.String("principal", p.Name),
+			slog.Int("id", int(req.GetId())),
+			slog.String("tenant", tenant.FromContext(ctx)),
+		)
+	}
+
+	return &pb.RevokeAPIKeyResponse{}, nil
+}
+
+func (s *AdminServer) ReloadConfig(ctx context.Context, req *pb.ReloadConfigRequest) (*pb.ReloadConfigResponse, error) {
+	p, ok := auth.FromContext(ctx)
+	// config is shared by all tenants
+	if ok && p.Tenant != "" {
+		return nil, status.Error(codes.PermissionDenied, "config is reloaded only with a key not bound to a tenant")
+	}
+	if s.reload == nil {
+		return nil, status.Error(codes.Unimplemented, "config reload is not supported")
+	}
+
+	restartRequired, err := s.reload()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if ok {
+		logging.FromContext(ctx).Info("config reloaded by request", slog.String("principal", p.Name))
+	}
+
+	return &pb.ReloadConfigResponse{
+		RestartRequired: restartRequired,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/auth"
+	"logstream/internal/config"
+	"logstream/internal/repo/memory"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestCreateAPIKeyRole(t *testing.T) {
+	roles, err := auth.NewRoles([]*config.RoleConfig{
+		{Name: "billing-admin", Permissions: []string{auth.PermissionRead, auth.PermissionWrite, auth.PermissionAdmin}, Sources: []string{"billing-*"}},
+		{Name: "billing-reader", Permissions: []string{auth.PermissionRead}, Sources: []string{"billing-eu"}},
+	})
+	require.NoError(t, err)
+	s := NewAdminServer(memory.NewKeysRepo(), roles, nil)
+
+	scoped := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "ops", Role: roles["billing-admin"], Tenant: "default"})
+
+	testCases := []struct {
+		name         string
+		role         string
+		expectedCode codes.Code
+	}{
+		{name: "built-in admin", role: auth.RoleAdmin, expectedCode: codes.PermissionDenied},
+		{name: "built-in reader", role: auth.RoleReader, expectedCode: codes.PermissionDenied},
+		{name: "narrower role", role: "billing-reader", expectedCode: codes.OK},
+		{name: "own role", role: "billing-admin", expectedCode: codes.OK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.CreateAPIKey(scoped, &pb.CreateAPIKeyRequest{Name: tc.name, Role: tc.role})
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+
+	keys, err := s.ListAPIKeys(scoped, &pb.ListAPIKeysRequest{})
+	require.NoError(t, err)
+	assert.Len(t, keys.GetApiKeys(), 2)
+}
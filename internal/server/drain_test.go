@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"logstream/internal/repo"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestDrain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().Unix()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
+		WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", now, "{}", "default", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	srv := NewServer(repo.NewRepo(db))
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterLogsServiceServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := pb.NewLogsServiceClient(conn).SaveLogStream(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&pb.SaveLogRequest{Log: &pb.Log{
+		Source:    "test-source",
+		Level:     pb.Level_LEVEL_INFO,
+		Message:   "test message",
+		Timestamp: now,
+	}}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.GetId())
+
+	// the stream is closed once the server drains, GracefulStop does not wait for clients
+	srv.Drain()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		t.Fatal("server did not stop")
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"logstream/internal/ratelimit"
+)
+
+// logSize - size of log accounted by daily bytes quota and metrics
+func logSize(source, message string, attributes map[string]string) int {
+	size := len(source) + len(message)
+	for key, value := range attributes {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+func resourceExhausted(d ratelimit.Decision) error {
+	st, err := status.New(codes.ResourceExhausted, d.Reason).
+		WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(d.RetryAfter),
+		})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"logstream/internal/metrics"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/redact"
+	"logstream/internal/spool"
+)
+
+type Option func(s *Server)
+
+// WithPipeline sets processor applied to logs between validation and saving.
+func WithPipeline(p pipeline.Processor) Option {
+	return func(s *Server) {
+		s.pipeline = p
+	}
+}
+
+// WithRedactor sets redactor applied to logs after pipeline, right before
+// saving.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = r
+	}
+}
+
+// WithLimiter sets rate limits and quotas checked before processing.
+func WithLimiter(l *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// WithMetrics enables ingestion and repo metrics.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+		s.r = m.InstrumentRepo(s.r)
+	}
+}
+
+// WithSpool makes logs that fail to be saved go to sp, they are
+// acknowledged and saved when the storage is back.
+func WithSpool(sp *spool.Spool) Option {
+	return func(s *Server) {
+		s.spool = sp
+	}
+}
+
+// WithMaxListLogs caps logs returned by ListLogs and ListLogsStream. Requests
+// without a limit get n logs at most; 0 means no cap.
+func WithMaxListLogs(n int) Option {
+	return func(s *Server) {
+		s.maxListLogs = n
+	}
+}
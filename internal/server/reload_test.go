@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"logstream/internal/auth"
+	"logstream/internal/config"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/repo/memory"
+	pb "logstream/pkg/api/logstream"
+)
+
+func TestReload(t *testing.T) {
+	limiter, err := ratelimit.FromConfig(&config.LimitsConfig{
+		Default: &config.LimitConfig{DailyLogs: 1},
+	})
+	require.NoError(t, err)
+	s := NewServer(memory.NewRepo(0), WithLimiter(limiter))
+
+	ctx := context.Background()
+	l := &pb.Log{Source: "api", Level: pb.Level_LEVEL_INFO, Message: "error: disk is full", Timestamp: 1}
+
+	_, err = s.prepare(ctx, l)
+	require.NoError(t, err)
+	_, err = s.prepare(ctx, l)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	p, err := pipeline.FromConfig(&config.PipelineConfig{
+		Processors: []*config.ProcessorConfig{{Type: "drop", When: &config.ConditionConfig{Message: "^debug"}}},
+	})
+	require.NoError(t, err)
+	s.Reload(p, nil, nil)
+
+	log, err := s.prepare(ctx, l)
+	require.NoError(t, err)
+	assert.NotNil(t, log)
+
+	log, err = s.prepare(ctx, &pb.Log{Source: "api", Level: pb.Level_LEVEL_INFO, Message: "debug: cache miss", Timestamp: 1})
+	require.NoError(t, err)
+	assert.Nil(t, log)
+}
+
+func TestReloadConfig(t *testing.T) {
+	reloaded := 0
+	s := &AdminServer{
+		reload: func() ([]string, error) {
+			reloaded++
+			return []string{"db"}, nil
+		},
+	}
+
+	bootstrap := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "bootstrap"})
+	resp, err := s.ReloadConfig(bootstrap, &pb.ReloadConfigRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db"}, resp.GetRestartRequired())
+
+	tenantAdmin := auth.WithPrincipal(context.Background(), &auth.Principal{Name: "ops", Tenant: "acme"})
+	_, err = s.ReloadConfig(tenantAdmin, &pb.ReloadConfigRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, 1, reloaded)
+}
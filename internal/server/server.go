@@ -2,43 +2,246 @@ package server
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"io"
-	logger "log"
+	"sync"
+	"sync/atomic"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"logstream/internal/auth"
 	"logstream/internal/database"
+	"logstream/internal/loglevel"
+	"logstream/internal/metrics"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/redact"
 	"logstream/internal/repo"
+	"logstream/internal/spool"
+	"logstream/internal/tenant"
 	pb "logstream/pkg/api/logstream"
 )
 
 type Server struct {
 	pb.UnimplementedLogsServiceServer
 
-	r repo.Repo
+	r       repo.Repo
+	metrics *metrics.Metrics
+	spool   *spool.Spool
+	// maxListLogs - cap of listed logs, 0 means no cap
+	maxListLogs int
+
+	// mu guards ingestion settings replaced by Reload
+	mu       sync.RWMutex
+	pipeline pipeline.Processor
+	redactor *redact.Redactor
+	limiter  *ratelimit.Limiter
+
+	draining  chan struct{}
+	drainOnce sync.Once
+
+	// backlog - logs being written to the database
+	backlog atomic.Int64
+}
+
+func NewServer(r repo.Repo, opts ...Option) *Server {
+	s := &Server{
+		r:        r,
+		pipeline: pipeline.New(),
+		draining: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reload replaces ingestion pipeline, redaction rules and limits, nil
+// redactor or limiter disables them. Usage of limits and redaction counts
+// are kept while they stay enabled.
+func (s *Server) Reload(p pipeline.Processor, r *redact.Redactor, l *ratelimit.Limiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pipeline = p
+	if s.redactor != nil && r != nil {
+		s.redactor.Update(r)
+	} else {
+		s.redactor = r
+	}
+	if s.limiter != nil && l != nil {
+		s.limiter.Update(l)
+	} else {
+		s.limiter = l
+	}
+}
+
+// RedactionCounts returns number of redactions per rule, nil if redaction
+// is disabled.
+func (s *Server) RedactionCounts() map[string]uint64 {
+	s.mu.RLock()
+	r := s.redactor
+	s.mu.RUnlock()
+
+	if r == nil {
+		return nil
+	}
+	return r.Counts()
+}
+
+// Drain makes open client streams return codes.Unavailable once the message
+// being handled is answered, so clients reconnect to another instance and
+// resend unanswered messages. Unary calls and new calls are not affected.
+func (s *Server) Drain() {
+	s.drainOnce.Do(func() {
+		close(s.draining)
+	})
+}
+
+// receive reads stream messages in background, so handlers can stop reading
+// when the server drains.
+func receive[T any](ctx context.Context, recv func() (T, error)) (<-chan T, <-chan error) {
+	msgs := make(chan T)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return msgs, errs
+}
+
+// listLimit returns limit of listed logs for validated request limit.
+func (s *Server) listLimit(limit int32) int {
+	if limit == 0 {
+		return s.maxListLogs
+	}
+	return int(limit)
+}
+
+// listLogsStreamPage - logs read from repo at once by ListLogsStream
+const listLogsStreamPage = 1000
+
+var errDraining = status.Error(codes.Unavailable, "server is shutting down")
+
+// prepare checks limits and runs ingestion pipeline and redaction on
+// validated log. Nil log means the log was dropped and must not be saved.
+func (s *Server) prepare(ctx context.Context, l *pb.Log) (*repo.Log, error) {
+	if err := auth.CheckWrite(ctx, l.GetSource()); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	p, redactor, limiter := s.pipeline, s.redactor, s.limiter
+	s.mu.RUnlock()
+
+	if limiter != nil {
+		d := limiter.Allow(tenant.FromContext(ctx), l.GetSource(), logSize(l.GetSource(), l.GetMessage(), l.GetAttributes()))
+		if d.Reject {
+			return nil, resourceExhausted(d)
+		}
+		if !d.Allowed {
+			s.metrics.ObserveDrop("limit")
+			return nil, nil
+		}
+	}
+
+	log, err := p.Process(ctx, repo.FromPbLog(l))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if log == nil {
+		s.metrics.ObserveDrop("pipeline")
+		return nil, nil
+	}
+
+	if redactor != nil {
+		if log, err = redactor.Process(ctx, log); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if err := validateProcessedLog(log); err != nil {
+		return nil, err
+	}
+	// pipeline may rename source
+	if log.Source != l.GetSource() {
+		if err := auth.CheckWrite(ctx, log.Source); err != nil {
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// Backlog returns number of accepted logs being written to the database.
+// Spooled logs are not counted.
+func (s *Server) Backlog() int {
+	return int(s.backlog.Load())
 }
 
-func NewServer(db *sql.DB) *Server {
-	r := repo.NewRepo(db)
-	return &Server{
-		r: r,
+// save saves prepared log. With spool, logs the storage fails to save are
+// spooled and their id is zero.
+func (s *Server) save(ctx context.Context, log *repo.Log) (int32, error) {
+	// logs follow spooled ones until they are replayed, so order is kept
+	if s.spool != nil && s.spool.Len() > 0 {
+		return 0, s.spoolLog(ctx, log)
 	}
+
+	s.backlog.Add(1)
+	id, err := s.r.AddLog(ctx, log)
+	s.backlog.Add(-1)
+	if err != nil {
+		if s.spool == nil || ctx.Err() != nil {
+			return 0, status.Error(codes.Aborted, err.Error())
+		}
+		return 0, s.spoolLog(ctx, log)
+	}
+
+	s.metrics.ObserveIngest(log.Source, loglevel.Name(pb.Level(log.Level)), logSize(log.Source, log.Message, log.Attributes))
+	return id, nil
+}
+
+// spoolLog writes log to the spool.
+func (s *Server) spoolLog(ctx context.Context, log *repo.Log) error {
+	if err := s.spool.Append(tenant.FromContext(ctx), log); err != nil {
+		if errors.Is(err, spool.ErrFull) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	s.metrics.ObserveIngest(log.Source, loglevel.Name(pb.Level(log.Level)), logSize(log.Source, log.Message, log.Attributes))
+	return nil
 }
 
 // SaveLog implements pb.LogsServiceServer
 func (s *Server) SaveLog(ctx context.Context, req *pb.SaveLogRequest) (*pb.SaveLogResponse, error) {
-	logger.Println("SaveLog: received")
-
 	if err := validateSaveLogRequest(req); err != nil {
 		return nil, err
 	}
 
-	id, err := s.r.AddLog(ctx, repo.FromPbLog(req.Log))
+	log, err := s.prepare(ctx, req.GetLog())
 	if err != nil {
-		return nil, status.Error(codes.Aborted, err.Error())
+		return nil, err
+	}
+	if log == nil {
+		return &pb.SaveLogResponse{}, nil
+	}
+	log.IdempotencyKey = req.GetIdempotencyKey()
+
+	id, err := s.save(ctx, log)
+	if err != nil {
+		return nil, err
 	}
 
 	return &pb.SaveLogResponse{
@@ -48,28 +251,41 @@ func (s *Server) SaveLog(ctx context.Context, req *pb.SaveLogRequest) (*pb.SaveL
 
 // SaveLogStream implements pb.LogsServiceServer
 func (s *Server) SaveLogStream(stream pb.LogsService_SaveLogStreamServer) error {
-	logger.Println("SaveLogsStream: received")
+	var saved int
+	defer func() {
+		s.metrics.ObserveBatch("save_log_stream", saved)
+	}()
 
+	reqs, errs := receive(stream.Context(), stream.Recv)
 	for {
 		select {
 		case <-stream.Context().Done():
 			return status.Errorf(codes.Canceled, "client context is done")
-		default:
-			req, err := stream.Recv()
+		case <-s.draining:
+			return errDraining
+		case err := <-errs:
 			if errors.Is(err, io.EOF) {
 				return nil
 			}
-			if err != nil {
-				return status.Error(codes.Internal, err.Error())
-			}
-
+			return status.Error(codes.Internal, err.Error())
+		case req := <-reqs:
 			if err := validateSaveLogRequest(req); err != nil {
 				return err
 			}
 
-			id, err := s.r.AddLog(stream.Context(), repo.FromPbLog(req.GetLog()))
+			log, err := s.prepare(stream.Context(), req.GetLog())
 			if err != nil {
-				return status.Error(codes.Aborted, err.Error())
+				return err
+			}
+
+			var id int32
+			if log != nil {
+				log.IdempotencyKey = req.GetIdempotencyKey()
+				id, err = s.save(stream.Context(), log)
+				if err != nil {
+					return err
+				}
+				saved++
 			}
 
 			resp := &pb.SaveLogResponse{
@@ -84,8 +300,6 @@ func (s *Server) SaveLogStream(stream pb.LogsService_SaveLogStreamServer) error
 
 // ListLog implements pb.LogsServiceServer
 func (s *Server) ListLog(ctx context.Context, req *pb.ListLogRequest) (*pb.ListLogResponse, error) {
-	logger.Println("ListLog: received")
-
 	if err := validateListLogRequest(req); err != nil {
 		return nil, err
 	}
@@ -97,6 +311,10 @@ func (s *Server) ListLog(ctx context.Context, req *pb.ListLogRequest) (*pb.ListL
 		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	// logs out of caller scope look like missing ones
+	if !auth.CanRead(ctx, log.Source) {
+		return nil, status.Error(codes.NotFound, database.ErrNotFound.Error())
+	}
 
 	return &pb.ListLogResponse{
 		Log: log.ToPbLog(),
@@ -104,21 +322,19 @@ func (s *Server) ListLog(ctx context.Context, req *pb.ListLogRequest) (*pb.ListL
 }
 
 func (s *Server) ListLogStream(stream pb.LogsService_ListLogStreamServer) error {
-	logger.Println("ListLogStream: received")
-
+	reqs, errs := receive(stream.Context(), stream.Recv)
 	for {
 		select {
 		case <-stream.Context().Done():
 			return status.Errorf(codes.Canceled, "client context is done")
-		default:
-			req, err := stream.Recv()
+		case <-s.draining:
+			return errDraining
+		case err := <-errs:
 			if errors.Is(err, io.EOF) {
 				return nil
 			}
-			if err != nil {
-				return status.Error(codes.Internal, err.Error())
-			}
-
+			return status.Error(codes.Internal, err.Error())
+		case req := <-reqs:
 			if err := validateListLogRequest(req); err != nil {
 				return err
 			}
@@ -130,6 +346,9 @@ func (s *Server) ListLogStream(stream pb.LogsService_ListLogStreamServer) error
 				}
 				return status.Error(codes.Internal, err.Error())
 			}
+			if !auth.CanRead(stream.Context(), log.Source) {
+				return status.Error(codes.NotFound, database.ErrNotFound.Error())
+			}
 
 			resp := &pb.ListLogResponse{
 				Log: log.ToPbLog(),
@@ -143,13 +362,22 @@ func (s *Server) ListLogStream(stream pb.LogsService_ListLogStreamServer) error
 
 // ListLogs implements pb.LogsServiceServer
 func (s *Server) ListLogs(ctx context.Context, req *pb.ListLogsRequest) (*pb.ListLogsResponse, error) {
-	logger.Println("ListLogs: received")
-
-	if err := validateListLogsRequest(req); err != nil {
+	if err := validateListLogsRequest(req, s.maxListLogs); err != nil {
+		return nil, err
+	}
+	if err := auth.CheckRead(ctx, req.GetSource()); err != nil {
 		return nil, err
 	}
 
-	logs, err := s.r.GetLogs(ctx, req.GetSource(), int32(req.GetLevel()), req.GetStartTime(), req.GetEndTime())
+	logs, err := s.r.GetLogs(ctx, repo.Filter{
+		Source:    req.GetSource(),
+		Level:     int32(req.GetLevel()),
+		StartTime: req.GetStartTime(),
+		EndTime:   req.GetEndTime(),
+		Query:     req.GetQuery(),
+		Desc:      req.GetOrder() == pb.SortOrder_SORT_ORDER_NEWEST_FIRST,
+		Limit:     s.listLimit(req.GetLimit()),
+	})
 	if err != nil {
 		if database.IsRecordNotFoundError(err) {
 			return nil, status.Error(codes.NotFound, err.Error())
@@ -169,28 +397,69 @@ func (s *Server) ListLogs(ctx context.Context, req *pb.ListLogsRequest) (*pb.Lis
 
 // ListLogsStream implements pb.LogsServiceServer
 func (s *Server) ListLogsStream(req *pb.ListLogsStreamRequest, stream pb.LogsService_ListLogsStreamServer) error {
-	logger.Println("ListLogsStream: received")
-
-	if err := validateListLogsStreamRequest(req); err != nil {
+	if err := validateListLogsStreamRequest(req, s.maxListLogs); err != nil {
 		return err
 	}
-
-	logs, err := s.r.GetLogs(stream.Context(), req.GetSource(), int32(req.GetLevel()), req.GetStartTime(), req.GetEndTime())
-	if err != nil {
-		if database.IsRecordNotFoundError(err) {
-			return status.Error(codes.NotFound, err.Error())
-		}
-		return status.Error(codes.Internal, err.Error())
+	if err := auth.CheckRead(stream.Context(), req.GetSource()); err != nil {
+		return err
 	}
 
-	for _, log := range logs {
+	// logs are read page by page while sending, Send blocks until the client
+	// is ready to receive more
+	ctx := stream.Context()
+	cursor := repo.NewCursor(s.r, repo.Filter{
+		Source:    req.GetSource(),
+		Level:     int32(req.GetLevel()),
+		StartTime: req.GetStartTime(),
+		EndTime:   req.GetEndTime(),
+		Query:     req.GetQuery(),
+		Desc:      req.GetOrder() == pb.SortOrder_SORT_ORDER_NEWEST_FIRST,
+		Limit:     s.listLimit(req.GetLimit()),
+	}, listLogsStreamPage)
+
+	var sent int
+	for cursor.Next(ctx) {
 		resp := &pb.ListLogsStreamResponse{
-			Log: log.ToPbLog(),
+			Log: cursor.Log().ToPbLog(),
 		}
 		if err := stream.Send(resp); err != nil {
 			return status.Error(codes.Internal, err.Error())
 		}
+		sent++
+	}
+	if err := cursor.Err(); err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	if sent == 0 {
+		return status.Error(codes.NotFound, database.ErrNotFound.Error())
 	}
 
 	return nil
 }
+
+// ListSources implements pb.LogsServiceServer
+func (s *Server) ListSources(ctx context.Context, req *pb.ListSourcesRequest) (*pb.ListSourcesResponse, error) {
+	if err := validateListSourcesRequest(req); err != nil {
+		return nil, err
+	}
+
+	sources, err := s.r.GetSources(ctx, req.GetPrefix())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// sources out of the caller's scope are left out rather than denied
+	respSources := make([]*pb.Source, 0, len(sources))
+	for _, source := range sources {
+		if auth.CanRead(ctx, source.Name) {
+			respSources = append(respSources, source.ToPbSource())
+		}
+	}
+
+	return &pb.ListSourcesResponse{
+		Sources: respSources,
+	}, nil
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,8 +14,17 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"logstream/internal/auth"
+	"logstream/internal/pipeline"
+	"logstream/internal/ratelimit"
+	"logstream/internal/repo"
+	"logstream/internal/spool"
+	"logstream/internal/tenant"
 	pb "logstream/pkg/api/logstream"
 )
 
@@ -33,7 +45,7 @@ func (s *Suite) SetupSuite() {
 	s.db, s.mock, err = sqlmock.New()
 	require.NoError(s.T(), err)
 
-	s.server = NewServer(s.db)
+	s.server = NewServer(repo.NewRepo(s.db))
 }
 
 func (s *Suite) AfterTest(suiteName, testName string) {
@@ -64,14 +76,49 @@ func (s *Suite) TestSaveLog() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`INSERT INTO logs (source, lvl, message, created_at) VALUES ($1, $2, $3, $4) RETURNING id`)).
-					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix()).
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
+					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}", "default", "").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 			},
 			expectedResp: &pb.SaveLogResponse{
 				Id: 1,
 			},
 		},
+		{
+			name: "save log with idempotency key",
+			req: &pb.SaveLogRequest{
+				Log: &pb.Log{
+					Source:    "test-source",
+					Level:     pb.Level_LEVEL_INFO,
+					Message:   "test message",
+					Timestamp: time.Now().Unix(),
+				},
+				IdempotencyKey: "agent-1",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`INSERT INTO logs (source, lvl, message, created_at, attributes, tenant_id, idempotency_key) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) ON CONFLICT (tenant_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key RETURNING id`)).
+					WithArgs("test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}", "default", "agent-1").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			expectedResp: &pb.SaveLogResponse{
+				Id: 1,
+			},
+		},
+		{
+			name: "invalid request idempotency key",
+			req: &pb.SaveLogRequest{
+				Log: &pb.Log{
+					Source:    "test-source",
+					Level:     pb.Level_LEVEL_INFO,
+					Message:   "test message",
+					Timestamp: time.Now().Unix(),
+				},
+				IdempotencyKey: strings.Repeat("k", 129),
+			},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: codes.InvalidArgument.String(),
+		},
 		{
 			name:        "invalid request log",
 			req:         &pb.SaveLogRequest{},
@@ -149,6 +196,164 @@ func (s *Suite) TestSaveLog() {
 	}
 }
 
+func (s *Suite) TestSaveLogPipeline() {
+	server := NewServer(repo.NewRepo(s.db), WithPipeline(pipeline.New(
+		pipeline.ProcessorFunc(func(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+			switch log.Source {
+			case "drop":
+				return nil, nil
+			case "clear":
+				log.Message = ""
+			}
+			return log, nil
+		}),
+	)))
+
+	testCases := []struct {
+		name         string
+		source       string
+		expectedResp *pb.SaveLogResponse
+		expectedErr  string
+	}{
+		{
+			name:         "dropped log",
+			source:       "drop",
+			expectedResp: &pb.SaveLogResponse{},
+		},
+		{
+			name:        "empty message after processing",
+			source:      "clear",
+			expectedErr: codes.InvalidArgument.String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			resp, err := server.SaveLog(t.Context(), &pb.SaveLogRequest{
+				Log: &pb.Log{
+					Source:    tc.source,
+					Level:     pb.Level_LEVEL_INFO,
+					Message:   "test message",
+					Timestamp: time.Now().Unix(),
+				},
+			})
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.True(t, reflect.DeepEqual(tc.expectedResp, resp))
+			} else {
+				assert.Nil(t, resp)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *Suite) TestSaveLogLimits() {
+	limiter, err := ratelimit.New(ratelimit.ModeReject, 0, ratelimit.Limit{DailyLogs: 1}, nil)
+	require.NoError(s.T(), err)
+	server := NewServer(repo.NewRepo(s.db), WithLimiter(limiter), WithPipeline(pipeline.New(
+		pipeline.ProcessorFunc(func(ctx context.Context, log *repo.Log) (*repo.Log, error) {
+			return nil, nil
+		}),
+	)))
+
+	req := &pb.SaveLogRequest{
+		Log: &pb.Log{
+			Source:    "test-source",
+			Level:     pb.Level_LEVEL_INFO,
+			Message:   "test message",
+			Timestamp: time.Now().Unix(),
+		},
+	}
+
+	_, err = server.SaveLog(s.T().Context(), req)
+	require.NoError(s.T(), err)
+
+	_, err = server.SaveLog(s.T().Context(), req)
+	st := status.Convert(err)
+	assert.Equal(s.T(), codes.ResourceExhausted, st.Code())
+	require.Len(s.T(), st.Details(), 1)
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(s.T(), ok)
+	assert.Positive(s.T(), retryInfo.GetRetryDelay().AsDuration())
+}
+
+func (s *Suite) TestSaveLogSpool() {
+	sp, err := spool.Open(s.T().TempDir(), spool.Options{})
+	require.NoError(s.T(), err)
+	defer sp.Close()
+	server := NewServer(repo.NewRepo(s.db), WithSpool(sp))
+
+	req := &pb.SaveLogRequest{
+		Log: &pb.Log{
+			Source:    "test-source",
+			Level:     pb.Level_LEVEL_INFO,
+			Message:   "test message",
+			Timestamp: time.Now().Unix(),
+		},
+	}
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO logs`)).
+		WillReturnError(errors.New("connection refused"))
+	resp, err := server.SaveLog(s.T().Context(), req)
+	require.NoError(s.T(), err)
+	assert.Zero(s.T(), resp.GetId())
+	assert.Equal(s.T(), 1, sp.Len())
+
+	// logs wait for the spooled ones without trying the database
+	resp, err = server.SaveLog(s.T().Context(), req)
+	require.NoError(s.T(), err)
+	assert.Zero(s.T(), resp.GetId())
+	assert.Equal(s.T(), 2, sp.Len())
+}
+
+func (s *Suite) TestSourceScope() {
+	ctx := auth.WithPrincipal(s.T().Context(), &auth.Principal{
+		Name: "dashboard",
+		Role: &auth.Role{Name: "billing", Permissions: []string{auth.PermissionRead}, Sources: []string{"billing-*"}},
+	})
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+			AddRow(1, "api", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}"))
+
+	_, err := s.server.ListLog(ctx, &pb.ListLogRequest{Id: 1})
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
+
+	_, err = s.server.ListLogs(ctx, &pb.ListLogsRequest{
+		Source:    "api",
+		Level:     pb.Level_LEVEL_INFO,
+		StartTime: time.Now().Unix() - 60,
+		EndTime:   time.Now().Unix(),
+	})
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+
+	_, err = s.server.SaveLog(ctx, &pb.SaveLogRequest{
+		Log: &pb.Log{
+			Source:    "billing-worker",
+			Level:     pb.Level_LEVEL_INFO,
+			Message:   "test message",
+			Timestamp: time.Now().Unix(),
+		},
+	})
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+}
+
+func (s *Suite) TestTenantIsolation() {
+	ctx := tenant.WithTenant(s.T().Context(), "acme")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+		WithArgs(1, "acme").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := s.server.ListLog(ctx, &pb.ListLogRequest{Id: 1})
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
+}
+
 func (s *Suite) TestSaveLogStream() {}
 
 func (s *Suite) TestListLog() {
@@ -166,10 +371,10 @@ func (s *Suite) TestListLog() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE id = $1`)).
-					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at"}).
-						AddRow(1, "test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix()))
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+					WithArgs(1, "default").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(1, "test-source", pb.Level_LEVEL_INFO, "test message", time.Now().Unix(), "{}"))
 			},
 			expectedResp: &pb.ListLogResponse{
 				Log: &pb.Log{
@@ -188,8 +393,8 @@ func (s *Suite) TestListLog() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE id = $1`)).
-					WithArgs(42).
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE id = $1 AND tenant_id = $2`)).
+					WithArgs(42, "default").
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectedErr: codes.NotFound.String(),
@@ -241,11 +446,11 @@ func (s *Suite) TestListLogs() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4`)).
-					WithArgs("test-source", 1, 10000, 1000000).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at"}).
-						AddRow(1, "test-source", pb.Level_LEVEL_WARN, "test message 1", 10000).
-						AddRow(2, "test-source", pb.Level_LEVEL_WARN, "test message 2", 10001))
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(1, "test-source", pb.Level_LEVEL_WARN, "test message 1", 10000, "{}").
+						AddRow(2, "test-source", pb.Level_LEVEL_WARN, "test message 2", 10001, "{}"))
 			},
 			expectedResp: &pb.ListLogsResponse{
 				Logs: []*pb.Log{
@@ -276,12 +481,63 @@ func (s *Suite) TestListLogs() {
 			},
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectQuery(regexp.QuoteMeta(
-					`SELECT id, source, lvl, message, created_at FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4`)).
-					WithArgs("test-source", 1, 10000, 1000000).
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default").
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectedErr: codes.NotFound.String(),
 		},
+		{
+			name: "newest logs up to limit",
+			req: &pb.ListLogsRequest{
+				Source:    "test-source",
+				Level:     pb.Level_LEVEL_WARN,
+				StartTime: 10000,
+				EndTime:   1000000,
+				Order:     pb.SortOrder_SORT_ORDER_NEWEST_FIRST,
+				Limit:     1,
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					`SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5 ORDER BY created_at DESC, id DESC LIMIT $6`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+						AddRow(2, "test-source", pb.Level_LEVEL_WARN, "test message 2", 10001, "{}"))
+			},
+			expectedResp: &pb.ListLogsResponse{
+				Logs: []*pb.Log{
+					{
+						Id:        func() *int32 { id := int32(2); return &id }(),
+						Source:    "test-source",
+						Level:     pb.Level_LEVEL_WARN,
+						Message:   "test message 2",
+						Timestamp: 10001,
+					},
+				},
+			},
+		},
+		{
+			name: "invalid request order",
+			req: &pb.ListLogsRequest{
+				Source:    "test-source",
+				StartTime: 10000,
+				EndTime:   1000000,
+				Order:     5,
+			},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: codes.InvalidArgument.String(),
+		},
+		{
+			name: "invalid request limit",
+			req: &pb.ListLogsRequest{
+				Source:    "test-source",
+				StartTime: 10000,
+				EndTime:   1000000,
+				Limit:     -1,
+			},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: codes.InvalidArgument.String(),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -301,4 +557,224 @@ func (s *Suite) TestListLogs() {
 	}
 }
 
-func (s *Suite) TestListLogsStream() {}
+func (s *Suite) TestListLogsCap() {
+	server := NewServer(repo.NewRepo(s.db), WithMaxListLogs(100))
+	req := &pb.ListLogsRequest{
+		Source:    "test-source",
+		StartTime: 10000,
+		EndTime:   1000000,
+		Limit:     101,
+	}
+
+	_, err := server.ListLogs(s.T().Context(), req)
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+
+	// requests without limit get the cap
+	s.mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY created_at, id LIMIT $6`)).
+		WithArgs("test-source", 0, 10000, 1000000, "default", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source", "lvl", "message", "created_at", "attributes"}).
+			AddRow(1, "test-source", pb.Level_LEVEL_INFO, "test message", 10000, "{}"))
+	req.Limit = 0
+	resp, err := server.ListLogs(s.T().Context(), req)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), resp.GetLogs(), 1)
+}
+
+// listStream collects sent logs. Its context is canceled once cancelAfter
+// logs are sent.
+type listStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelAfter int
+	logs        []*pb.Log
+}
+
+func (s *listStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *listStream) Send(resp *pb.ListLogsStreamResponse) error {
+	s.logs = append(s.logs, resp.GetLog())
+	if len(s.logs) == s.cancelAfter {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *Suite) TestListLogsStream() {
+	const query = `SELECT id, source, lvl, message, created_at, attributes FROM logs WHERE source = $1 AND lvl = $2 AND created_at >= $3 AND created_at <= $4 AND tenant_id = $5`
+	columns := []string{"id", "source", "lvl", "message", "created_at", "attributes"}
+	fullPage := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows(columns)
+		for i := 1; i <= listLogsStreamPage; i++ {
+			rows.AddRow(i, "test-source", pb.Level_LEVEL_WARN, "test message", 10000+i, "{}")
+		}
+		return rows
+	}
+
+	testCases := []struct {
+		name         string
+		cancelAfter  int
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedLogs int
+		expectedErr  string
+	}{
+		{
+			name: "logs are read by pages",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query+` ORDER BY created_at, id LIMIT $6`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", listLogsStreamPage).
+					WillReturnRows(fullPage())
+				mock.ExpectQuery(regexp.QuoteMeta(query+` AND (created_at, id) > ($6, $7) ORDER BY created_at, id LIMIT $8`)).
+					WithArgs("test-source", 1, 10000, 1000000, "default", 10000+listLogsStreamPage, listLogsStreamPage, listLogsStreamPage).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1001, "test-source", pb.Level_LEVEL_WARN, "last message", 20000, "{}"))
+			},
+			expectedLogs: listLogsStreamPage + 1,
+		},
+		{
+			name:        "client canceled stream",
+			cancelAfter: listLogsStreamPage,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnRows(fullPage())
+			},
+			expectedLogs: listLogsStreamPage,
+			expectedErr:  codes.Canceled.String(),
+		},
+		{
+			name: "logs not found",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectedErr: codes.NotFound.String(),
+		},
+		{
+			name: "storage error",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnError(errors.New("connection reset"))
+			},
+			expectedErr: codes.Internal.String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			stream := &listStream{ctx: ctx, cancel: cancel, cancelAfter: tc.cancelAfter}
+			err := s.server.ListLogsStream(&pb.ListLogsStreamRequest{
+				Source:    "test-source",
+				Level:     pb.Level_LEVEL_WARN,
+				StartTime: 10000,
+				EndTime:   1000000,
+			}, stream)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedErr, status.Code(err).String())
+			}
+			assert.Len(t, stream.logs, tc.expectedLogs)
+		})
+	}
+}
+
+func (s *Suite) TestListSources() {
+	const query = `SELECT source, lvl, first_seen, last_seen, logs FROM sources WHERE tenant_id = $1 AND starts_with(source, $2) AND logs > 0 ORDER BY source, lvl`
+	columns := []string{"source", "lvl", "first_seen", "last_seen", "logs"}
+	billing := auth.WithPrincipal(s.T().Context(), &auth.Principal{
+		Name: "dashboard",
+		Role: &auth.Role{Name: "billing", Permissions: []string{auth.PermissionRead}, Sources: []string{"billing-*"}},
+	})
+
+	testCases := []struct {
+		name         string
+		ctx          context.Context
+		req          *pb.ListSourcesRequest
+		mockSetup    func(mock sqlmock.Sqlmock)
+		expectedResp *pb.ListSourcesResponse
+		expectedErr  string
+	}{
+		{
+			name: "list sources",
+			ctx:  s.T().Context(),
+			req:  &pb.ListSourcesRequest{Prefix: "api"},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("default", "api").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("api", pb.Level_LEVEL_INFO, 10000, 10005, 3).
+						AddRow("api", pb.Level_LEVEL_ERROR, 10001, 10002, 1))
+			},
+			expectedResp: &pb.ListSourcesResponse{Sources: []*pb.Source{
+				{Name: "api", FirstSeen: 10000, LastSeen: 10005, InfoLogs: 3, ErrorLogs: 1},
+			}},
+		},
+		{
+			name: "sources out of scope are left out",
+			ctx:  billing,
+			req:  &pb.ListSourcesRequest{},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("default", "").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("api", pb.Level_LEVEL_INFO, 10000, 10000, 1).
+						AddRow("billing-worker", pb.Level_LEVEL_WARN, 10000, 10000, 2))
+			},
+			expectedResp: &pb.ListSourcesResponse{Sources: []*pb.Source{
+				{Name: "billing-worker", FirstSeen: 10000, LastSeen: 10000, WarnLogs: 2},
+			}},
+		},
+		{
+			name: "no sources",
+			ctx:  s.T().Context(),
+			req:  &pb.ListSourcesRequest{},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("default", "").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectedResp: &pb.ListSourcesResponse{Sources: []*pb.Source{}},
+		},
+		{
+			name:        "invalid request prefix",
+			ctx:         s.T().Context(),
+			req:         &pb.ListSourcesRequest{Prefix: strings.Repeat("a", 256)},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: codes.InvalidArgument.String(),
+		},
+		{
+			name: "db error",
+			ctx:  s.T().Context(),
+			req:  &pb.ListSourcesRequest{},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("default", "").
+					WillReturnError(errors.New("connection reset"))
+			},
+			expectedErr: codes.Internal.String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			tc.mockSetup(s.mock)
+
+			resp, err := s.server.ListSources(tc.ctx, tc.req)
+
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.True(t, reflect.DeepEqual(tc.expectedResp, resp))
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedErr, status.Code(err).String())
+			}
+		})
+	}
+}
@@ -1,13 +1,24 @@
 package server
 
 import (
+	"fmt"
+
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"logstream/internal/auth"
+	"logstream/internal/repo"
 	pb "logstream/pkg/api/logstream"
 )
 
+// maxIdempotencyKeyLen - max length of idempotency key, the database
+// column is VARCHAR(128)
+const maxIdempotencyKeyLen = 128
+
+// maxSourceLen - max length of source, the database column is VARCHAR(255)
+const maxSourceLen = 255
+
 func validateSaveLogRequest(req *pb.SaveLogRequest) error {
 	var violations []*errdetails.BadRequest_FieldViolation
 
@@ -56,6 +67,45 @@ func validateSaveLogRequest(req *pb.SaveLogRequest) error {
 		})
 	}
 
+	if key := req.GetIdempotencyKey(); len(key) > maxIdempotencyKeyLen {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "idempotency_key",
+			Description: "too long",
+		})
+	}
+
+	if len(violations) > 0 {
+		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
+			WithDetails(&errdetails.BadRequest{
+				FieldViolations: violations,
+			})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return st.Err()
+	}
+
+	return nil
+}
+
+// validateProcessedLog checks fields which processors may have changed.
+func validateProcessedLog(log *repo.Log) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if len(log.Source) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "log.source",
+			Description: "empty after processing",
+		})
+	}
+
+	if len(log.Message) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "log.message",
+			Description: "empty after processing",
+		})
+	}
+
 	if len(violations) > 0 {
 		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
 			WithDetails(&errdetails.BadRequest{
@@ -94,7 +144,7 @@ func validateListLogRequest(req *pb.ListLogRequest) error {
 	return nil
 }
 
-func validateListLogsRequest(req *pb.ListLogsRequest) error {
+func validateListLogsRequest(req *pb.ListLogsRequest, maxLogs int) error {
 	var violations []*errdetails.BadRequest_FieldViolation
 
 	if level := req.GetLevel(); level > 2 {
@@ -118,6 +168,8 @@ func validateListLogsRequest(req *pb.ListLogsRequest) error {
 		})
 	}
 
+	violations = append(violations, validateListOptions(req.GetOrder(), req.GetLimit(), maxLogs)...)
+
 	if len(violations) > 0 {
 		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
 			WithDetails(&errdetails.BadRequest{
@@ -132,7 +184,7 @@ func validateListLogsRequest(req *pb.ListLogsRequest) error {
 	return nil
 }
 
-func validateListLogsStreamRequest(req *pb.ListLogsStreamRequest) error {
+func validateListLogsStreamRequest(req *pb.ListLogsStreamRequest, maxLogs int) error {
 	var violations []*errdetails.BadRequest_FieldViolation
 
 	if level := req.GetLevel(); level > 2 {
@@ -156,6 +208,32 @@ func validateListLogsStreamRequest(req *pb.ListLogsStreamRequest) error {
 		})
 	}
 
+	violations = append(violations, validateListOptions(req.GetOrder(), req.GetLimit(), maxLogs)...)
+
+	if len(violations) > 0 {
+		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
+			WithDetails(&errdetails.BadRequest{
+				FieldViolations: violations,
+			})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return st.Err()
+	}
+
+	return nil
+}
+
+func validateListSourcesRequest(req *pb.ListSourcesRequest) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if prefix := req.GetPrefix(); len(prefix) > maxSourceLen {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "prefix",
+			Description: "too long",
+		})
+	}
+
 	if len(violations) > 0 {
 		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
 			WithDetails(&errdetails.BadRequest{
@@ -169,3 +247,102 @@ func validateListLogsStreamRequest(req *pb.ListLogsStreamRequest) error {
 
 	return nil
 }
+
+func validateCreateAPIKeyRequest(req *pb.CreateAPIKeyRequest, roles auth.Roles, now int64) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if len(req.GetName()) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "name",
+			Description: "empty",
+		})
+	} else if len(req.GetName()) > 255 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "name",
+			Description: "too long",
+		})
+	}
+
+	if len(req.GetRole()) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "role",
+			Description: "empty",
+		})
+	} else if _, ok := roles[req.GetRole()]; !ok {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "role",
+			Description: "unknown role",
+		})
+	}
+
+	if expiresAt := req.GetExpiresAt(); expiresAt != 0 && expiresAt <= now {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "expires_at",
+			Description: "in the past",
+		})
+	}
+
+	if len(violations) > 0 {
+		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
+			WithDetails(&errdetails.BadRequest{
+				FieldViolations: violations,
+			})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return st.Err()
+	}
+
+	return nil
+}
+
+func validateRevokeAPIKeyRequest(req *pb.RevokeAPIKeyRequest) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if id := req.GetId(); id == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "id",
+			Description: "empty",
+		})
+	}
+
+	if len(violations) > 0 {
+		st, err := status.New(codes.InvalidArgument, codes.InvalidArgument.String()).
+			WithDetails(&errdetails.BadRequest{
+				FieldViolations: violations,
+			})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return st.Err()
+	}
+
+	return nil
+}
+
+// validateListOptions checks sort order and limit of list requests, limit
+// may not exceed maxLogs unless it is 0.
+func validateListOptions(order pb.SortOrder, limit int32, maxLogs int) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if _, ok := pb.SortOrder_name[int32(order)]; !ok {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "order",
+			Description: "invalid value",
+		})
+	}
+
+	if limit < 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "limit",
+			Description: "negative",
+		})
+	} else if maxLogs > 0 && int(limit) > maxLogs {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "limit",
+			Description: fmt.Sprintf("exceeds max %d", maxLogs),
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,72 @@
+package spool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"logstream/internal/repo"
+	"logstream/internal/tenant"
+)
+
+// Run replays spooled logs into r whenever ready reports the storage is
+// available, until ctx is done.
+func (s *Spool) Run(ctx context.Context, r repo.Repo, ready func(ctx context.Context) error) {
+	ticker := time.NewTicker(s.opts.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.Len() > 0 && ready(ctx) == nil {
+			n, err := s.Replay(ctx, r)
+			if n > 0 {
+				slog.Info("spooled logs are replayed", slog.Int("logs", n), slog.Int("left", s.Len()))
+			}
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("failed to replay spool", slog.Any("error", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Replay saves spooled logs into r in order until the spool is empty. It
+// returns number of replayed logs.
+//
+// The replay position is moved past a batch only after it is saved. A
+// batch saved before a crash or an ambiguous error is just saved again:
+// every spooled log has an idempotency key, so it is not stored twice.
+func (s *Spool) Replay(ctx context.Context, r repo.Repo) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	var replayed int
+	for s.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+
+		b, err := s.read(s.opts.BatchSize)
+		if err != nil {
+			return replayed, err
+		}
+		if len(b.logs) == 0 {
+			return replayed, nil
+		}
+
+		if _, err := r.AddLogs(tenant.WithTenant(ctx, b.tenant), b.logs); err != nil {
+			return replayed, fmt.Errorf("failed to save spooled logs: %v", err)
+		}
+		if err := s.commit(b); err != nil {
+			return replayed, err
+		}
+		replayed += len(b.logs)
+		s.replayed.Add(uint64(len(b.logs)))
+	}
+	return replayed, nil
+}
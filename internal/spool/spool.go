@@ -0,0 +1,558 @@
+// Package spool keeps accepted logs on local disk while the storage is
+// unavailable and replays them into it, in order, once it is back.
+//
+// Logs are appended to spool files as length and CRC32 prefixed JSON
+// records, every append is fsynced before it is acknowledged. The position
+// of the first record not yet replayed is kept in a state file, spool
+// files before it are removed. Records of logs without idempotency key get
+// one, so a batch replayed again after a crash is not saved twice.
+package spool
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logstream/internal/repo"
+)
+
+const (
+	defaultMaxSize        = 1 << 30
+	defaultBatchSize      = 1000
+	defaultReplayInterval = time.Second
+
+	// headerSize - payload length and CRC32 preceding every record
+	headerSize = 8
+	// fileSize - size after which records are appended to the next file,
+	// so replayed records are removed with their files
+	fileSize = 16 << 20
+
+	stateFile = "state.json"
+)
+
+// ErrFull is returned by Append when the spool reached its max size.
+var ErrFull = errors.New("spool is full")
+
+// Options configures Spool.
+type Options struct {
+	// MaxSize - max bytes of records not yet replayed, 1GiB by default
+	MaxSize int64
+	// BatchSize - max logs replayed in one AddLogs call, 1000 by default
+	BatchSize int
+	// ReplayInterval - how often the storage is checked while there are
+	// spooled logs, 1s by default
+	ReplayInterval time.Duration
+}
+
+type position struct {
+	File   uint64 `json:"file"`
+	Offset int64  `json:"offset"`
+}
+
+type state struct {
+	position
+}
+
+type record struct {
+	Tenant     string            `json:"tenant"`
+	Source     string            `json:"source"`
+	Level      int32             `json:"level"`
+	Message    string            `json:"message"`
+	CreatedAt  int64             `json:"created_at"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Key        string            `json:"idempotency_key,omitempty"`
+}
+
+// Stats - spool usage
+type Stats struct {
+	// Logs - logs not yet replayed
+	Logs int
+	// Bytes - size of records not yet replayed
+	Bytes int64
+	// Spooled - logs appended since start
+	Spooled uint64
+	// Replayed - logs replayed since start
+	Replayed uint64
+	// Rejected - logs rejected since start because the spool is full
+	Rejected uint64
+}
+
+// Spool - on-disk queue of logs waiting for the storage
+type Spool struct {
+	dir  string
+	opts Options
+	// id - random prefix of idempotency keys given to spooled logs, so
+	// keys differ from those of a removed spool reusing file names
+	id string
+
+	// replayMu serializes replays, so a batch is never saved twice
+	replayMu sync.Mutex
+
+	mu    sync.Mutex
+	state state
+	// files - sequence numbers of spool files in order, the last one is
+	// appended to
+	files []uint64
+	w     *os.File
+	wSize int64
+	count int
+	size  int64
+	// err - failed append that could not be undone, the spool rejects
+	// appends after it
+	err error
+
+	spooled  atomic.Uint64
+	replayed atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// Open opens spool in dir, creating it if needed. Records torn by a crash
+// during append were never acknowledged and are removed.
+func Open(dir string, opts Options) (*Spool, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultMaxSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.ReplayInterval <= 0 {
+		opts.ReplayInterval = defaultReplayInterval
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %v", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate spool id: %v", err)
+	}
+	s := &Spool{dir: dir, opts: opts, id: hex.EncodeToString(id)}
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read spool state: %v", err)
+	default:
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("failed to parse spool state: %v", err)
+		}
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Spool) filePath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("spool-%016d.log", seq))
+}
+
+// load removes replayed files, counts records not yet replayed and opens
+// the last file for appending.
+func (s *Spool) load() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "spool-*.log"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "spool-"), ".log"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected spool file %s", name)
+		}
+		if seq < s.state.File {
+			if err := os.Remove(name); err != nil {
+				return fmt.Errorf("failed to remove spool file: %v", err)
+			}
+			continue
+		}
+		s.files = append(s.files, seq)
+	}
+
+	if len(s.files) == 0 {
+		s.files = []uint64{s.state.File + 1}
+		s.state = state{position: position{File: s.state.File + 1}}
+		return s.openWriter(true)
+	}
+	if s.files[0] != s.state.File {
+		// the state file is older than removal of the files it points to
+		s.state = state{position: position{File: s.files[0]}}
+	}
+
+	for i, seq := range s.files {
+		last := i == len(s.files)-1
+		var offset int64
+		if seq == s.state.File {
+			offset = s.state.Offset
+		}
+		end, err := s.scan(seq, offset, last)
+		if err != nil {
+			return err
+		}
+		if last {
+			s.wSize = end
+		}
+	}
+	return s.openWriter(false)
+}
+
+// scan counts records of file seq from offset and returns end of the last
+// complete record. A torn tail of the last file is truncated.
+func (s *Spool) scan(seq uint64, offset int64, last bool) (int64, error) {
+	path := s.filePath(seq)
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spool file: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat spool file: %v", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek spool file: %v", err)
+	}
+
+	br := bufio.NewReader(f)
+	end := offset
+	for {
+		_, n, err := readRecord(br)
+		if err != nil {
+			break
+		}
+		end += n
+		s.count++
+		s.size += n
+	}
+
+	if end < info.Size() {
+		if !last {
+			return 0, fmt.Errorf("spool file %s is corrupted at offset %d", path, end)
+		}
+		if err := os.Truncate(path, end); err != nil {
+			return 0, fmt.Errorf("failed to truncate spool file: %v", err)
+		}
+	}
+	return end, nil
+}
+
+func (s *Spool) openWriter(create bool) error {
+	flags := os.O_WRONLY | os.O_APPEND
+	if create {
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(s.filePath(s.files[len(s.files)-1]), flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %v", err)
+	}
+	if create {
+		if err := syncDir(s.dir); err != nil {
+			f.Close()
+			return err
+		}
+		s.wSize = 0
+	}
+	s.w = f
+	return nil
+}
+
+// readRecord reads one record and returns its payload and size.
+func readRecord(r io.Reader) ([]byte, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header)
+	if size > fileSize {
+		return nil, 0, errors.New("corrupted record")
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, errors.New("corrupted record")
+	}
+	return payload, int64(headerSize + size), nil
+}
+
+// Append durably writes log of tenant to the spool. Log without
+// idempotency key is given one unique to its record.
+func (s *Spool) Append(tenantID string, log *repo.Log) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.wSize >= fileSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	key := log.IdempotencyKey
+	if key == "" {
+		key = fmt.Sprintf("spool-%s-%d-%d", s.id, s.files[len(s.files)-1], s.wSize)
+	}
+	payload, err := json.Marshal(record{
+		Tenant:     tenantID,
+		Source:     log.Source,
+		Level:      log.Level,
+		Message:    log.Message,
+		CreatedAt:  log.CreatedAt,
+		Attributes: log.Attributes,
+		Key:        key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode log: %v", err)
+	}
+	data := make([]byte, headerSize, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(data, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(data[4:], crc32.ChecksumIEEE(payload))
+	data = append(data, payload...)
+
+	if s.size+int64(len(data)) > s.opts.MaxSize {
+		s.rejected.Add(1)
+		return ErrFull
+	}
+
+	_, err = s.w.Write(data)
+	if err == nil {
+		err = s.w.Sync()
+	}
+	if err != nil {
+		// a partial record would hide the following ones from replay
+		if truncErr := s.w.Truncate(s.wSize); truncErr != nil {
+			s.err = fmt.Errorf("failed to undo spool write: %v", truncErr)
+		}
+		return fmt.Errorf("failed to write spool: %v", err)
+	}
+
+	s.wSize += int64(len(data))
+	s.size += int64(len(data))
+	s.count++
+	s.spooled.Add(1)
+	return nil
+}
+
+// rotate starts the next spool file. s.mu must be held.
+func (s *Spool) rotate() error {
+	prev := s.w
+	s.files = append(s.files, s.files[len(s.files)-1]+1)
+	if err := s.openWriter(true); err != nil {
+		s.files = s.files[:len(s.files)-1]
+		return err
+	}
+	return prev.Close()
+}
+
+// Len returns number of logs not yet replayed.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Stats returns spool usage.
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Logs:     s.count,
+		Bytes:    s.size,
+		Spooled:  s.spooled.Load(),
+		Replayed: s.replayed.Load(),
+		Rejected: s.rejected.Load(),
+	}
+}
+
+// Check returns reason the spool does not accept logs, nil if it does.
+func (s *Spool) Check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if s.size >= s.opts.MaxSize {
+		return ErrFull
+	}
+	return nil
+}
+
+// Close closes the spool, logs not yet replayed are replayed after open.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+// batch - consecutive spooled logs of one tenant
+type batch struct {
+	tenant string
+	logs   []*repo.Log
+	size   int64
+	end    position
+}
+
+// read reads up to max logs of one tenant from the replay position.
+func (s *Spool) read(max int) (*batch, error) {
+	s.mu.Lock()
+	pos := s.state.position
+	files := append([]uint64(nil), s.files...)
+	wSize := s.wSize
+	s.mu.Unlock()
+
+	b := &batch{end: pos}
+	for i, seq := range files {
+		if seq < pos.File {
+			continue
+		}
+		last := i == len(files)-1
+		limit := wSize
+		if !last {
+			info, err := os.Stat(s.filePath(seq))
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat spool file: %v", err)
+			}
+			limit = info.Size()
+		}
+		full, err := s.readFile(b, seq, limit, max)
+		if err != nil {
+			return nil, err
+		}
+		if full || last {
+			break
+		}
+		// the file is replayed, the batch continues in the next one
+		b.end = position{File: files[i+1]}
+	}
+	return b, nil
+}
+
+// readFile reads records of file seq from b.end to limit into b. It
+// reports whether b is complete: it has max logs or the next log belongs
+// to another tenant.
+func (s *Spool) readFile(b *batch, seq uint64, limit int64, max int) (bool, error) {
+	f, err := os.Open(s.filePath(seq))
+	if err != nil {
+		return false, fmt.Errorf("failed to open spool file: %v", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(io.NewSectionReader(f, b.end.Offset, limit-b.end.Offset))
+	for {
+		if len(b.logs) >= max {
+			return true, nil
+		}
+		payload, n, err := readRecord(br)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read spool file: %v", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return false, fmt.Errorf("failed to decode spooled log: %v", err)
+		}
+		if len(b.logs) > 0 && rec.Tenant != b.tenant {
+			return true, nil
+		}
+		b.tenant = rec.Tenant
+		b.logs = append(b.logs, &repo.Log{
+			Source:         rec.Source,
+			Level:          rec.Level,
+			Message:        rec.Message,
+			CreatedAt:      rec.CreatedAt,
+			Attributes:     rec.Attributes,
+			IdempotencyKey: rec.Key,
+		})
+		b.size += n
+		b.end.Offset += n
+	}
+}
+
+// commit durably moves the replay position past b and removes replayed
+// files.
+func (s *Spool) commit(b *batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := state{position: b.end}
+	if err := s.saveState(st); err != nil {
+		return err
+	}
+	s.state = st
+	s.count -= len(b.logs)
+	s.size -= b.size
+
+	for len(s.files) > 1 && s.files[0] < st.File {
+		if err := os.Remove(s.filePath(s.files[0])); err != nil {
+			// it is removed on open
+			break
+		}
+		s.files = s.files[1:]
+	}
+	return nil
+}
+
+// saveState durably replaces the state file. s.mu must be held.
+func (s *Spool) saveState(st state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool state: %v", err)
+	}
+
+	path := filepath.Join(s.dir, stateFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool state: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spool state: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync spool state: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close spool state: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename spool state: %v", err)
+	}
+	return syncDir(s.dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open dir: %v", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dir: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package spool_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"logstream/internal/repo"
+	"logstream/internal/repo/memory"
+	"logstream/internal/spool"
+	"logstream/internal/tenant"
+)
+
+// failingRepo fails AddLogs, after saving logs if saveFirst is set, like a
+// connection lost before the commit is acknowledged.
+type failingRepo struct {
+	repo.Repo
+	saveFirst bool
+}
+
+func (r *failingRepo) AddLogs(ctx context.Context, logs []*repo.Log) ([]int32, error) {
+	if r.saveFirst {
+		if _, err := r.Repo.AddLogs(ctx, logs); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("connection reset by peer")
+}
+
+func messages(t *testing.T, ctx context.Context, r repo.Repo) []string {
+	t.Helper()
+	logs, err := r.GetLogs(ctx, repo.Filter{Source: "api", EndTime: 1000})
+	require.NoError(t, err)
+	var messages []string
+	for _, l := range logs {
+		messages = append(messages, l.Message)
+	}
+	return messages
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, spool.Options{BatchSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "first", CreatedAt: 10, Attributes: repo.Attributes{"k": "v"}}))
+	require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "second", CreatedAt: 20}))
+	require.NoError(t, sp.Append("acme", &repo.Log{Source: "api", Message: "other tenant", CreatedAt: 30}))
+	require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "third", CreatedAt: 40}))
+	require.NoError(t, sp.Close())
+
+	// spooled logs survive restart
+	sp, err = spool.Open(dir, spool.Options{BatchSize: 2})
+	require.NoError(t, err)
+	defer sp.Close()
+	assert.Equal(t, 4, sp.Len())
+
+	r := memory.NewRepo(0)
+	n, err := sp.Replay(ctx, r)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 0, sp.Len())
+	assert.Equal(t, []string{"first", "second", "third"}, messages(t, ctx, r))
+	assert.Equal(t, []string{"other tenant"}, messages(t, tenant.WithTenant(ctx, "acme"), r))
+
+	log, err := r.GetLog(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repo.Attributes{"k": "v"}, log.Attributes)
+
+	stats := sp.Stats()
+	assert.Equal(t, uint64(4), stats.Replayed)
+	assert.Zero(t, stats.Bytes)
+}
+
+func TestReplayIsIdempotent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		saveFirst bool
+		keys      [2]string
+		// saved - identical logs saved before, e.g. repeated heartbeats
+		saved bool
+	}{
+		{
+			name: "batch is not saved",
+		},
+		{
+			name:      "batch is saved but not acknowledged",
+			saveFirst: true,
+		},
+		{
+			name:      "batch with idempotency keys is saved but not acknowledged",
+			saveFirst: true,
+			keys:      [2]string{"a", "b"},
+		},
+		{
+			name:      "identical logs are saved already",
+			saveFirst: true,
+			saved:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			r := memory.NewRepo(0)
+			expected := []string{"first", "second"}
+			if tc.saved {
+				_, err := r.AddLogs(ctx, []*repo.Log{
+					{Source: "api", Message: "first", CreatedAt: 10},
+					{Source: "api", Message: "second", CreatedAt: 20},
+				})
+				require.NoError(t, err)
+				expected = []string{"first", "first", "second", "second"}
+			}
+
+			sp, err := spool.Open(dir, spool.Options{})
+			require.NoError(t, err)
+			require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "first", CreatedAt: 10, IdempotencyKey: tc.keys[0]}))
+			require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "second", CreatedAt: 20, IdempotencyKey: tc.keys[1]}))
+
+			_, err = sp.Replay(ctx, &failingRepo{Repo: r, saveFirst: tc.saveFirst})
+			require.Error(t, err)
+			assert.Equal(t, 2, sp.Len())
+			require.NoError(t, sp.Close())
+
+			// the batch is saved again after restart
+			sp, err = spool.Open(dir, spool.Options{})
+			require.NoError(t, err)
+			defer sp.Close()
+			_, err = sp.Replay(ctx, r)
+			require.NoError(t, err)
+			assert.Equal(t, 0, sp.Len())
+			assert.Equal(t, expected, messages(t, ctx, r))
+		})
+	}
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+	require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "kept", CreatedAt: 10}))
+	require.NoError(t, sp.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "spool-*.log"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{200, 0, 0, 0, 1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	sp, err = spool.Open(dir, spool.Options{})
+	require.NoError(t, err)
+	defer sp.Close()
+	assert.Equal(t, 1, sp.Len())
+	require.NoError(t, sp.Append("default", &repo.Log{Source: "api", Message: "appended", CreatedAt: 20}))
+
+	r := memory.NewRepo(0)
+	_, err = sp.Replay(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept", "appended"}, messages(t, context.Background(), r))
+}
+
+func TestFull(t *testing.T) {
+	sp, err := spool.Open(t.TempDir(), spool.Options{MaxSize: 300})
+	require.NoError(t, err)
+	defer sp.Close()
+
+	log := &repo.Log{Source: "api", Message: "message", CreatedAt: 10}
+	require.NoError(t, sp.Append("default", log))
+	require.NoError(t, sp.Append("default", log))
+	assert.ErrorIs(t, sp.Append("default", log), spool.ErrFull)
+	assert.Equal(t, uint64(1), sp.Stats().Rejected)
+
+	// replay frees the space
+	_, err = sp.Replay(context.Background(), memory.NewRepo(0))
+	require.NoError(t, err)
+	require.NoError(t, sp.Check())
+	require.NoError(t, sp.Append("default", log))
+}
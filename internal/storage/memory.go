@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+
+	"logstream/internal/repo"
+	"logstream/internal/repo/memory"
+)
+
+// Memory keeps logs and API keys in memory, they are lost on restart.
+type Memory struct {
+	logs repo.Repo
+	keys repo.KeysRepo
+}
+
+// NewMemory creates storage keeping at most maxLogs logs, zero means no
+// limit.
+func NewMemory(maxLogs int) *Memory {
+	return &Memory{
+		logs: memory.NewRepo(maxLogs),
+		keys: memory.NewKeysRepo(),
+	}
+}
+
+func (m *Memory) Repo() repo.Repo {
+	return m.logs
+}
+
+func (m *Memory) KeysRepo() repo.KeysRepo {
+	return m.keys
+}
+
+// Check always succeeds, memory is always available.
+func (m *Memory) Check(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"logstream/internal/database"
+	"logstream/internal/repo"
+)
+
+// Postgres keeps logs and API keys in postgres database.
+type Postgres struct {
+	db   *sql.DB
+	logs repo.Repo
+	keys repo.KeysRepo
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{
+		db:   db,
+		logs: repo.NewRepo(db),
+		keys: repo.NewKeysRepo(db),
+	}
+}
+
+// DB returns database connection pool, e.g. to migrate it.
+func (p *Postgres) DB() *sql.DB {
+	return p.db
+}
+
+func (p *Postgres) Repo() repo.Repo {
+	return p.logs
+}
+
+func (p *Postgres) KeysRepo() repo.KeysRepo {
+	return p.keys
+}
+
+// Check returns error if database is unavailable or migrations are not
+// applied.
+func (p *Postgres) Check(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unavailable: %v", err)
+	}
+
+	version, err := database.AppliedVersion(ctx, p.db)
+	if err != nil {
+		return err
+	}
+	if version < database.SchemaVersion {
+		return fmt.Errorf("database schema version %d is older than %d, migrations are not applied", version, database.SchemaVersion)
+	}
+	return nil
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
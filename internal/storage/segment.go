@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"logstream/internal/repo"
+	"logstream/internal/repo/segment"
+)
+
+// Segment keeps logs in append-only segment files and API keys in a file
+// of the same directory.
+type Segment struct {
+	engine *segment.Engine
+	keys   repo.KeysRepo
+}
+
+// NewSegment opens segment storage in dir.
+func NewSegment(dir string, opts segment.Options) (*Segment, error) {
+	engine, err := segment.Open(dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segments: %v", err)
+	}
+	keys, err := segment.NewKeysRepo(dir)
+	if err != nil {
+		return nil, errors.Join(err, engine.Close())
+	}
+	return &Segment{
+		engine: engine,
+		keys:   keys,
+	}, nil
+}
+
+func (s *Segment) Repo() repo.Repo {
+	return s.engine
+}
+
+func (s *Segment) KeysRepo() repo.KeysRepo {
+	return s.keys
+}
+
+// Check returns error if the WAL can not be written.
+func (s *Segment) Check(ctx context.Context) error {
+	if err := s.engine.Err(); err != nil {
+		return fmt.Errorf("segment storage is unavailable: %v", err)
+	}
+	return nil
+}
+
+// Close flushes logs of the WAL to segments.
+func (s *Segment) Close() error {
+	return s.engine.Close()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"logstream/internal/repo"
+	"logstream/internal/repo/sqlite"
+)
+
+// SQLite keeps logs and API keys in an embedded database file.
+type SQLite struct {
+	db   *sql.DB
+	logs repo.Repo
+	keys repo.KeysRepo
+}
+
+// NewSQLite opens database file at path and applies its migrations.
+func NewSQLite(ctx context.Context, path string) (*SQLite, error) {
+	db, err := sqlite.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{
+		db:   db,
+		logs: sqlite.NewRepo(db),
+		keys: sqlite.NewKeysRepo(db),
+	}, nil
+}
+
+// DB returns database connection pool.
+func (s *SQLite) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLite) Repo() repo.Repo {
+	return s.logs
+}
+
+func (s *SQLite) KeysRepo() repo.KeysRepo {
+	return s.keys
+}
+
+// Check returns error if database file is unavailable.
+func (s *SQLite) Check(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unavailable: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,67 @@
+// Package storage selects backend keeping logs and API keys.
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"logstream/internal/config"
+	"logstream/internal/database"
+	"logstream/internal/repo"
+	"logstream/internal/repo/segment"
+)
+
+// Storage - backend keeping logs and API keys
+type Storage interface {
+	// Repo - logs repo
+	Repo() repo.Repo
+
+	// KeysRepo - API keys repo
+	KeysRepo() repo.KeysRepo
+
+	// Check returns reason the storage is not ready, nil if it is
+	Check(ctx context.Context) error
+
+	// Close releases resources of the storage
+	Close() error
+}
+
+// Open opens storage selected by cfg.StorageConfig, postgres by default.
+func Open(ctx context.Context, cfg *config.Config) (Storage, error) {
+	storageCfg := cfg.StorageConfig
+	if storageCfg == nil {
+		storageCfg = &config.StorageConfig{Type: "postgres"}
+	}
+
+	switch storageCfg.Type {
+	case "postgres":
+		db, err := database.NewDB(cfg.DBConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open db: %v", err)
+		}
+		return NewPostgres(db), nil
+	case "memory":
+		var maxLogs int
+		if storageCfg.Memory != nil {
+			maxLogs = storageCfg.Memory.MaxLogs
+		}
+		return NewMemory(maxLogs), nil
+	case "sqlite":
+		if storageCfg.SQLite == nil || storageCfg.SQLite.Path == "" {
+			return nil, fmt.Errorf("sqlite storage path is required")
+		}
+		return NewSQLite(ctx, storageCfg.SQLite.Path)
+	case "segment":
+		segmentCfg := storageCfg.Segment
+		if segmentCfg == nil || segmentCfg.Path == "" {
+			return nil, fmt.Errorf("segment storage path is required")
+		}
+		return NewSegment(segmentCfg.Path, segment.Options{
+			Bucket:     segmentCfg.Bucket,
+			FlushLogs:  segmentCfg.FlushLogs,
+			KeyFlushes: segmentCfg.KeyFlushes,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", storageCfg.Type)
+	}
+}
@@ -0,0 +1,106 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Default - tenant of requests and data without explicit tenant
+const Default = "default"
+
+// MetadataKey - metadata key selecting tenant
+const MetadataKey = "x-tenant-id"
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type contextKey string
+
+const tenantKey = contextKey("tenant")
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey, id)
+}
+
+// FromContext returns tenant of request, Default if it is not set.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return Default
+	}
+	if id, ok := ctx.Value(tenantKey).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Validate checks tenant id.
+func Validate(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant id %q: should match %s", id, idPattern)
+	}
+	return nil
+}
+
+// FromMetadata returns tenant requested in incoming metadata, empty if none.
+func FromMetadata(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return "", nil
+	}
+	if err := Validate(values[0]); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return values[0], nil
+}
+
+func fromMetadata(ctx context.Context) (context.Context, error) {
+	id, err := FromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return ctx, nil
+	}
+	return WithTenant(ctx, id), nil
+}
+
+// UnaryServerInterceptor takes tenant from metadata. It is used when
+// authentication is disabled, otherwise tenant comes from credentials.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := fromMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor takes tenant from metadata of streaming calls.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := fromMetadata(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -70,6 +70,53 @@ func (Level) EnumDescriptor() ([]byte, []int) {
 	return file_api_logstream_messages_proto_rawDescGZIP(), []int{0}
 }
 
+// SortOrder - order of listed logs by timestamp, then id
+type SortOrder int32
+
+const (
+	SortOrder_SORT_ORDER_OLDEST_FIRST SortOrder = 0
+	SortOrder_SORT_ORDER_NEWEST_FIRST SortOrder = 1
+)
+
+// Enum value maps for SortOrder.
+var (
+	SortOrder_name = map[int32]string{
+		0: "SORT_ORDER_OLDEST_FIRST",
+		1: "SORT_ORDER_NEWEST_FIRST",
+	}
+	SortOrder_value = map[string]int32{
+		"SORT_ORDER_OLDEST_FIRST": 0,
+		"SORT_ORDER_NEWEST_FIRST": 1,
+	}
+)
+
+func (x SortOrder) Enum() *SortOrder {
+	p := new(SortOrder)
+	*p = x
+	return p
+}
+
+func (x SortOrder) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortOrder) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_logstream_messages_proto_enumTypes[1].Descriptor()
+}
+
+func (SortOrder) Type() protoreflect.EnumType {
+	return &file_api_logstream_messages_proto_enumTypes[1]
+}
+
+func (x SortOrder) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortOrder.Descriptor instead.
+func (SortOrder) EnumDescriptor() ([]byte, []int) {
+	return file_api_logstream_messages_proto_rawDescGZIP(), []int{1}
+}
+
 type Log struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            *int32                 `protobuf:"varint,1,opt,name=id,proto3,oneof" json:"id,omitempty"`                      // log id
@@ -77,6 +124,7 @@ type Log struct {
 	Level         Level                  `protobuf:"varint,3,opt,name=level,proto3,enum=logstream.Level" json:"level,omitempty"` // log level (info, warn, error)
 	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
 	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Attributes    map[string]string      `protobuf:"bytes,6,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // structured fields, e.g. parsed from JSON or logfmt lines
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -146,11 +194,21 @@ func (x *Log) GetTimestamp() int64 {
 	return 0
 }
 
+func (x *Log) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
 type SaveLogRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Log           *Log                   `protobuf:"bytes,1,opt,name=log,proto3" json:"log,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Log   *Log                   `protobuf:"bytes,1,opt,name=log,proto3" json:"log,omitempty"`
+	// optional key unique per tenant; a request with a key already saved is
+	// not saved again and gets id of the saved log
+	IdempotencyKey string `protobuf:"bytes,2,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *SaveLogRequest) Reset() {
@@ -190,9 +248,16 @@ func (x *SaveLogRequest) GetLog() *Log {
 	return nil
 }
 
+func (x *SaveLogRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
 type SaveLogResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"` // saved log id, zero when log was dropped by ingestion pipeline
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -328,6 +393,9 @@ type ListLogsRequest struct {
 	Level         Level                  `protobuf:"varint,2,opt,name=level,proto3,enum=logstream.Level" json:"level,omitempty"`
 	StartTime     int64                  `protobuf:"varint,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
 	EndTime       int64                  `protobuf:"varint,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Query         string                 `protobuf:"bytes,5,opt,name=query,proto3" json:"query,omitempty"` // words the message must contain, case-insensitive; empty matches every message
+	Order         SortOrder              `protobuf:"varint,6,opt,name=order,proto3,enum=logstream.SortOrder" json:"order,omitempty"`
+	Limit         int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"` // max logs to return; 0 means the server cap
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -390,6 +458,27 @@ func (x *ListLogsRequest) GetEndTime() int64 {
 	return 0
 }
 
+func (x *ListLogsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListLogsRequest) GetOrder() SortOrder {
+	if x != nil {
+		return x.Order
+	}
+	return SortOrder_SORT_ORDER_OLDEST_FIRST
+}
+
+func (x *ListLogsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
 type ListLogsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Logs          []*Log                 `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
@@ -440,6 +529,9 @@ type ListLogsStreamRequest struct {
 	Level         Level                  `protobuf:"varint,2,opt,name=level,proto3,enum=logstream.Level" json:"level,omitempty"`
 	StartTime     int64                  `protobuf:"varint,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
 	EndTime       int64                  `protobuf:"varint,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Query         string                 `protobuf:"bytes,5,opt,name=query,proto3" json:"query,omitempty"` // words the message must contain, case-insensitive; empty matches every message
+	Order         SortOrder              `protobuf:"varint,6,opt,name=order,proto3,enum=logstream.SortOrder" json:"order,omitempty"`
+	Limit         int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"` // max logs to return; 0 means the server cap
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -502,6 +594,27 @@ func (x *ListLogsStreamRequest) GetEndTime() int64 {
 	return 0
 }
 
+func (x *ListLogsStreamRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListLogsStreamRequest) GetOrder() SortOrder {
+	if x != nil {
+		return x.Order
+	}
+	return SortOrder_SORT_ORDER_OLDEST_FIRST
+}
+
+func (x *ListLogsStreamRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
 type ListLogsStreamResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Log           *Log                   `protobuf:"bytes,1,opt,name=log,proto3" json:"log,omitempty"`
@@ -546,48 +659,250 @@ func (x *ListLogsStreamResponse) GetLog() *Log {
 	return nil
 }
 
+type ListSourcesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prefix        string                 `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"` // source name prefix; empty matches every source
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSourcesRequest) Reset() {
+	*x = ListSourcesRequest{}
+	mi := &file_api_logstream_messages_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSourcesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSourcesRequest) ProtoMessage() {}
+
+func (x *ListSourcesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_logstream_messages_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSourcesRequest.ProtoReflect.Descriptor instead.
+func (*ListSourcesRequest) Descriptor() ([]byte, []int) {
+	return file_api_logstream_messages_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListSourcesRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+// Source - source having logs with per-level counts
+type Source struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	FirstSeen     int64                  `protobuf:"varint,2,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"` // timestamp of the oldest log
+	LastSeen      int64                  `protobuf:"varint,3,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`    // timestamp of the newest log
+	InfoLogs      int64                  `protobuf:"varint,4,opt,name=info_logs,json=infoLogs,proto3" json:"info_logs,omitempty"`
+	WarnLogs      int64                  `protobuf:"varint,5,opt,name=warn_logs,json=warnLogs,proto3" json:"warn_logs,omitempty"`
+	ErrorLogs     int64                  `protobuf:"varint,6,opt,name=error_logs,json=errorLogs,proto3" json:"error_logs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Source) Reset() {
+	*x = Source{}
+	mi := &file_api_logstream_messages_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Source) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Source) ProtoMessage() {}
+
+func (x *Source) ProtoReflect() protoreflect.Message {
+	mi := &file_api_logstream_messages_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Source.ProtoReflect.Descriptor instead.
+func (*Source) Descriptor() ([]byte, []int) {
+	return file_api_logstream_messages_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Source) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Source) GetFirstSeen() int64 {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return 0
+}
+
+func (x *Source) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *Source) GetInfoLogs() int64 {
+	if x != nil {
+		return x.InfoLogs
+	}
+	return 0
+}
+
+func (x *Source) GetWarnLogs() int64 {
+	if x != nil {
+		return x.WarnLogs
+	}
+	return 0
+}
+
+func (x *Source) GetErrorLogs() int64 {
+	if x != nil {
+		return x.ErrorLogs
+	}
+	return 0
+}
+
+type ListSourcesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sources       []*Source              `protobuf:"bytes,1,rep,name=sources,proto3" json:"sources,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSourcesResponse) Reset() {
+	*x = ListSourcesResponse{}
+	mi := &file_api_logstream_messages_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSourcesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSourcesResponse) ProtoMessage() {}
+
+func (x *ListSourcesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_logstream_messages_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSourcesResponse.ProtoReflect.Descriptor instead.
+func (*ListSourcesResponse) Descriptor() ([]byte, []int) {
+	return file_api_logstream_messages_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListSourcesResponse) GetSources() []*Source {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
 var File_api_logstream_messages_proto protoreflect.FileDescriptor
 
 const file_api_logstream_messages_proto_rawDesc = "" +
 	"\n" +
-	"\x1capi/logstream/messages.proto\x12\tlogstream\"\x99\x01\n" +
+	"\x1capi/logstream/messages.proto\x12\tlogstream\"\x98\x02\n" +
 	"\x03Log\x12\x13\n" +
 	"\x02id\x18\x01 \x01(\x05H\x00R\x02id\x88\x01\x01\x12\x16\n" +
 	"\x06source\x18\x02 \x01(\tR\x06source\x12&\n" +
 	"\x05level\x18\x03 \x01(\x0e2\x10.logstream.LevelR\x05level\x12\x18\n" +
 	"\amessage\x18\x04 \x01(\tR\amessage\x12\x1c\n" +
-	"\ttimestamp\x18\x05 \x01(\x03R\ttimestampB\x05\n" +
-	"\x03_id\"2\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12>\n" +
+	"\n" +
+	"attributes\x18\x06 \x03(\v2\x1e.logstream.Log.AttributesEntryR\n" +
+	"attributes\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x05\n" +
+	"\x03_id\"[\n" +
 	"\x0eSaveLogRequest\x12 \n" +
-	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log\"!\n" +
+	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log\x12'\n" +
+	"\x0fidempotency_key\x18\x02 \x01(\tR\x0eidempotencyKey\"!\n" +
 	"\x0fSaveLogResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x05R\x02id\" \n" +
 	"\x0eListLogRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x05R\x02id\"3\n" +
 	"\x0fListLogResponse\x12 \n" +
-	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log\"\x8b\x01\n" +
+	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log\"\xe3\x01\n" +
 	"\x0fListLogsRequest\x12\x16\n" +
 	"\x06source\x18\x01 \x01(\tR\x06source\x12&\n" +
 	"\x05level\x18\x02 \x01(\x0e2\x10.logstream.LevelR\x05level\x12\x1d\n" +
 	"\n" +
 	"start_time\x18\x03 \x01(\x03R\tstartTime\x12\x19\n" +
-	"\bend_time\x18\x04 \x01(\x03R\aendTime\"6\n" +
+	"\bend_time\x18\x04 \x01(\x03R\aendTime\x12\x14\n" +
+	"\x05query\x18\x05 \x01(\tR\x05query\x12*\n" +
+	"\x05order\x18\x06 \x01(\x0e2\x14.logstream.SortOrderR\x05order\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\"6\n" +
 	"\x10ListLogsResponse\x12\"\n" +
-	"\x04logs\x18\x01 \x03(\v2\x0e.logstream.LogR\x04logs\"\x91\x01\n" +
+	"\x04logs\x18\x01 \x03(\v2\x0e.logstream.LogR\x04logs\"\xe9\x01\n" +
 	"\x15ListLogsStreamRequest\x12\x16\n" +
 	"\x06source\x18\x01 \x01(\tR\x06source\x12&\n" +
 	"\x05level\x18\x02 \x01(\x0e2\x10.logstream.LevelR\x05level\x12\x1d\n" +
 	"\n" +
 	"start_time\x18\x03 \x01(\x03R\tstartTime\x12\x19\n" +
-	"\bend_time\x18\x04 \x01(\x03R\aendTime\":\n" +
+	"\bend_time\x18\x04 \x01(\x03R\aendTime\x12\x14\n" +
+	"\x05query\x18\x05 \x01(\tR\x05query\x12*\n" +
+	"\x05order\x18\x06 \x01(\x0e2\x14.logstream.SortOrderR\x05order\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\":\n" +
 	"\x16ListLogsStreamResponse\x12 \n" +
-	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log*8\n" +
+	"\x03log\x18\x01 \x01(\v2\x0e.logstream.LogR\x03log\",\n" +
+	"\x12ListSourcesRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"\xb1\x01\n" +
+	"\x06Source\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"first_seen\x18\x02 \x01(\x03R\tfirstSeen\x12\x1b\n" +
+	"\tlast_seen\x18\x03 \x01(\x03R\blastSeen\x12\x1b\n" +
+	"\tinfo_logs\x18\x04 \x01(\x03R\binfoLogs\x12\x1b\n" +
+	"\twarn_logs\x18\x05 \x01(\x03R\bwarnLogs\x12\x1d\n" +
+	"\n" +
+	"error_logs\x18\x06 \x01(\x03R\terrorLogs\"B\n" +
+	"\x13ListSourcesResponse\x12+\n" +
+	"\asources\x18\x01 \x03(\v2\x11.logstream.SourceR\asources*8\n" +
 	"\x05Level\x12\x0e\n" +
 	"\n" +
 	"LEVEL_INFO\x10\x00\x12\x0e\n" +
 	"\n" +
 	"LEVEL_WARN\x10\x01\x12\x0f\n" +
-	"\vLEVEL_ERROR\x10\x02B'Z%logstream/pkg/api/logstream;logstreamb\x06proto3"
+	"\vLEVEL_ERROR\x10\x02*E\n" +
+	"\tSortOrder\x12\x1b\n" +
+	"\x17SORT_ORDER_OLDEST_FIRST\x10\x00\x12\x1b\n" +
+	"\x17SORT_ORDER_NEWEST_FIRST\x10\x01B'Z%logstream/pkg/api/logstream;logstreamb\x06proto3"
 
 var (
 	file_api_logstream_messages_proto_rawDescOnce sync.Once
@@ -601,33 +916,42 @@ func file_api_logstream_messages_proto_rawDescGZIP() []byte {
 	return file_api_logstream_messages_proto_rawDescData
 }
 
-var file_api_logstream_messages_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_api_logstream_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_api_logstream_messages_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_api_logstream_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_api_logstream_messages_proto_goTypes = []any{
 	(Level)(0),                     // 0: logstream.Level
-	(*Log)(nil),                    // 1: logstream.Log
-	(*SaveLogRequest)(nil),         // 2: logstream.SaveLogRequest
-	(*SaveLogResponse)(nil),        // 3: logstream.SaveLogResponse
-	(*ListLogRequest)(nil),         // 4: logstream.ListLogRequest
-	(*ListLogResponse)(nil),        // 5: logstream.ListLogResponse
-	(*ListLogsRequest)(nil),        // 6: logstream.ListLogsRequest
-	(*ListLogsResponse)(nil),       // 7: logstream.ListLogsResponse
-	(*ListLogsStreamRequest)(nil),  // 8: logstream.ListLogsStreamRequest
-	(*ListLogsStreamResponse)(nil), // 9: logstream.ListLogsStreamResponse
+	(SortOrder)(0),                 // 1: logstream.SortOrder
+	(*Log)(nil),                    // 2: logstream.Log
+	(*SaveLogRequest)(nil),         // 3: logstream.SaveLogRequest
+	(*SaveLogResponse)(nil),        // 4: logstream.SaveLogResponse
+	(*ListLogRequest)(nil),         // 5: logstream.ListLogRequest
+	(*ListLogResponse)(nil),        // 6: logstream.ListLogResponse
+	(*ListLogsRequest)(nil),        // 7: logstream.ListLogsRequest
+	(*ListLogsResponse)(nil),       // 8: logstream.ListLogsResponse
+	(*ListLogsStreamRequest)(nil),  // 9: logstream.ListLogsStreamRequest
+	(*ListLogsStreamResponse)(nil), // 10: logstream.ListLogsStreamResponse
+	(*ListSourcesRequest)(nil),     // 11: logstream.ListSourcesRequest
+	(*Source)(nil),                 // 12: logstream.Source
+	(*ListSourcesResponse)(nil),    // 13: logstream.ListSourcesResponse
+	nil,                            // 14: logstream.Log.AttributesEntry
 }
 var file_api_logstream_messages_proto_depIdxs = []int32{
-	0, // 0: logstream.Log.level:type_name -> logstream.Level
-	1, // 1: logstream.SaveLogRequest.log:type_name -> logstream.Log
-	1, // 2: logstream.ListLogResponse.log:type_name -> logstream.Log
-	0, // 3: logstream.ListLogsRequest.level:type_name -> logstream.Level
-	1, // 4: logstream.ListLogsResponse.logs:type_name -> logstream.Log
-	0, // 5: logstream.ListLogsStreamRequest.level:type_name -> logstream.Level
-	1, // 6: logstream.ListLogsStreamResponse.log:type_name -> logstream.Log
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	0,  // 0: logstream.Log.level:type_name -> logstream.Level
+	14, // 1: logstream.Log.attributes:type_name -> logstream.Log.AttributesEntry
+	2,  // 2: logstream.SaveLogRequest.log:type_name -> logstream.Log
+	2,  // 3: logstream.ListLogResponse.log:type_name -> logstream.Log
+	0,  // 4: logstream.ListLogsRequest.level:type_name -> logstream.Level
+	1,  // 5: logstream.ListLogsRequest.order:type_name -> logstream.SortOrder
+	2,  // 6: logstream.ListLogsResponse.logs:type_name -> logstream.Log
+	0,  // 7: logstream.ListLogsStreamRequest.level:type_name -> logstream.Level
+	1,  // 8: logstream.ListLogsStreamRequest.order:type_name -> logstream.SortOrder
+	2,  // 9: logstream.ListLogsStreamResponse.log:type_name -> logstream.Log
+	12, // 10: logstream.ListSourcesResponse.sources:type_name -> logstream.Source
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_api_logstream_messages_proto_init() }
@@ -641,8 +965,8 @@ func file_api_logstream_messages_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_logstream_messages_proto_rawDesc), len(file_api_logstream_messages_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   9,
+			NumEnums:      2,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -24,24 +24,27 @@ var File_api_logstream_service_proto protoreflect.FileDescriptor
 
 const file_api_logstream_service_proto_rawDesc = "" +
 	"\n" +
-	"\x1bapi/logstream/service.proto\x12\tlogstream\x1a\x1capi/logstream/messages.proto2\xc7\x03\n" +
+	"\x1bapi/logstream/service.proto\x12\tlogstream\x1a\x1capi/logstream/messages.proto2\x95\x04\n" +
 	"\vLogsService\x12@\n" +
 	"\aSaveLog\x12\x19.logstream.SaveLogRequest\x1a\x1a.logstream.SaveLogResponse\x12J\n" +
 	"\rSaveLogStream\x12\x19.logstream.SaveLogRequest\x1a\x1a.logstream.SaveLogResponse(\x010\x01\x12@\n" +
 	"\aListLog\x12\x19.logstream.ListLogRequest\x1a\x1a.logstream.ListLogResponse\x12J\n" +
 	"\rListLogStream\x12\x19.logstream.ListLogRequest\x1a\x1a.logstream.ListLogResponse(\x010\x01\x12C\n" +
 	"\bListLogs\x12\x1a.logstream.ListLogsRequest\x1a\x1b.logstream.ListLogsResponse\x12W\n" +
-	"\x0eListLogsStream\x12 .logstream.ListLogsStreamRequest\x1a!.logstream.ListLogsStreamResponse0\x01B'Z%logstream/pkg/api/logstream;logstreamb\x06proto3"
+	"\x0eListLogsStream\x12 .logstream.ListLogsStreamRequest\x1a!.logstream.ListLogsStreamResponse0\x01\x12L\n" +
+	"\vListSources\x12\x1d.logstream.ListSourcesRequest\x1a\x1e.logstream.ListSourcesResponseB'Z%logstream/pkg/api/logstream;logstreamb\x06proto3"
 
 var file_api_logstream_service_proto_goTypes = []any{
 	(*SaveLogRequest)(nil),         // 0: logstream.SaveLogRequest
 	(*ListLogRequest)(nil),         // 1: logstream.ListLogRequest
 	(*ListLogsRequest)(nil),        // 2: logstream.ListLogsRequest
 	(*ListLogsStreamRequest)(nil),  // 3: logstream.ListLogsStreamRequest
-	(*SaveLogResponse)(nil),        // 4: logstream.SaveLogResponse
-	(*ListLogResponse)(nil),        // 5: logstream.ListLogResponse
-	(*ListLogsResponse)(nil),       // 6: logstream.ListLogsResponse
-	(*ListLogsStreamResponse)(nil), // 7: logstream.ListLogsStreamResponse
+	(*ListSourcesRequest)(nil),     // 4: logstream.ListSourcesRequest
+	(*SaveLogResponse)(nil),        // 5: logstream.SaveLogResponse
+	(*ListLogResponse)(nil),        // 6: logstream.ListLogResponse
+	(*ListLogsResponse)(nil),       // 7: logstream.ListLogsResponse
+	(*ListLogsStreamResponse)(nil), // 8: logstream.ListLogsStreamResponse
+	(*ListSourcesResponse)(nil),    // 9: logstream.ListSourcesResponse
 }
 var file_api_logstream_service_proto_depIdxs = []int32{
 	0, // 0: logstream.LogsService.SaveLog:input_type -> logstream.SaveLogRequest
@@ -50,14 +53,16 @@ var file_api_logstream_service_proto_depIdxs = []int32{
 	1, // 3: logstream.LogsService.ListLogStream:input_type -> logstream.ListLogRequest
 	2, // 4: logstream.LogsService.ListLogs:input_type -> logstream.ListLogsRequest
 	3, // 5: logstream.LogsService.ListLogsStream:input_type -> logstream.ListLogsStreamRequest
-	4, // 6: logstream.LogsService.SaveLog:output_type -> logstream.SaveLogResponse
-	4, // 7: logstream.LogsService.SaveLogStream:output_type -> logstream.SaveLogResponse
-	5, // 8: logstream.LogsService.ListLog:output_type -> logstream.ListLogResponse
-	5, // 9: logstream.LogsService.ListLogStream:output_type -> logstream.ListLogResponse
-	6, // 10: logstream.LogsService.ListLogs:output_type -> logstream.ListLogsResponse
-	7, // 11: logstream.LogsService.ListLogsStream:output_type -> logstream.ListLogsStreamResponse
-	6, // [6:12] is the sub-list for method output_type
-	0, // [0:6] is the sub-list for method input_type
+	4, // 6: logstream.LogsService.ListSources:input_type -> logstream.ListSourcesRequest
+	5, // 7: logstream.LogsService.SaveLog:output_type -> logstream.SaveLogResponse
+	5, // 8: logstream.LogsService.SaveLogStream:output_type -> logstream.SaveLogResponse
+	6, // 9: logstream.LogsService.ListLog:output_type -> logstream.ListLogResponse
+	6, // 10: logstream.LogsService.ListLogStream:output_type -> logstream.ListLogResponse
+	7, // 11: logstream.LogsService.ListLogs:output_type -> logstream.ListLogsResponse
+	8, // 12: logstream.LogsService.ListLogsStream:output_type -> logstream.ListLogsStreamResponse
+	9, // 13: logstream.LogsService.ListSources:output_type -> logstream.ListSourcesResponse
+	7, // [7:14] is the sub-list for method output_type
+	0, // [0:7] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -25,6 +25,7 @@ const (
 	LogsService_ListLogStream_FullMethodName  = "/logstream.LogsService/ListLogStream"
 	LogsService_ListLogs_FullMethodName       = "/logstream.LogsService/ListLogs"
 	LogsService_ListLogsStream_FullMethodName = "/logstream.LogsService/ListLogsStream"
+	LogsService_ListSources_FullMethodName    = "/logstream.LogsService/ListSources"
 )
 
 // LogsServiceClient is the client API for LogsService service.
@@ -43,6 +44,8 @@ type LogsServiceClient interface {
 	ListLogs(ctx context.Context, in *ListLogsRequest, opts ...grpc.CallOption) (*ListLogsResponse, error)
 	// ListLogsStream - list logs in stream
 	ListLogsStream(ctx context.Context, in *ListLogsStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListLogsStreamResponse], error)
+	// ListSources - list sources having logs
+	ListSources(ctx context.Context, in *ListSourcesRequest, opts ...grpc.CallOption) (*ListSourcesResponse, error)
 }
 
 type logsServiceClient struct {
@@ -128,6 +131,16 @@ func (c *logsServiceClient) ListLogsStream(ctx context.Context, in *ListLogsStre
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LogsService_ListLogsStreamClient = grpc.ServerStreamingClient[ListLogsStreamResponse]
 
+func (c *logsServiceClient) ListSources(ctx context.Context, in *ListSourcesRequest, opts ...grpc.CallOption) (*ListSourcesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSourcesResponse)
+	err := c.cc.Invoke(ctx, LogsService_ListSources_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LogsServiceServer is the server API for LogsService service.
 // All implementations must embed UnimplementedLogsServiceServer
 // for forward compatibility.
@@ -144,6 +157,8 @@ type LogsServiceServer interface {
 	ListLogs(context.Context, *ListLogsRequest) (*ListLogsResponse, error)
 	// ListLogsStream - list logs in stream
 	ListLogsStream(*ListLogsStreamRequest, grpc.ServerStreamingServer[ListLogsStreamResponse]) error
+	// ListSources - list sources having logs
+	ListSources(context.Context, *ListSourcesRequest) (*ListSourcesResponse, error)
 	mustEmbedUnimplementedLogsServiceServer()
 }
 
@@ -172,6 +187,9 @@ func (UnimplementedLogsServiceServer) ListLogs(context.Context, *ListLogsRequest
 func (UnimplementedLogsServiceServer) ListLogsStream(*ListLogsStreamRequest, grpc.ServerStreamingServer[ListLogsStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ListLogsStream not implemented")
 }
+func (UnimplementedLogsServiceServer) ListSources(context.Context, *ListSourcesRequest) (*ListSourcesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSources not implemented")
+}
 func (UnimplementedLogsServiceServer) mustEmbedUnimplementedLogsServiceServer() {}
 func (UnimplementedLogsServiceServer) testEmbeddedByValue()                     {}
 
@@ -272,6 +290,24 @@ func _LogsService_ListLogsStream_Handler(srv interface{}, stream grpc.ServerStre
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LogsService_ListLogsStreamServer = grpc.ServerStreamingServer[ListLogsStreamResponse]
 
+func _LogsService_ListSources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogsServiceServer).ListSources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogsService_ListSources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogsServiceServer).ListSources(ctx, req.(*ListSourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LogsService_ServiceDesc is the grpc.ServiceDesc for LogsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -291,6 +327,10 @@ var LogsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListLogs",
 			Handler:    _LogsService_ListLogs_Handler,
 		},
+		{
+			MethodName: "ListSources",
+			Handler:    _LogsService_ListSources_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{